@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultSocket          = "/var/run/iam-runtime.sock"
+	defaultShutdownTimeout = 10 * time.Second
+)
+
+// config represents the configuration file for the runtime.
+type config struct {
+	// Socket is the path of the unix socket the runtime listens on.
+	Socket string `yaml:"socket"`
+	// ShutdownTimeout is the amount of time to wait for in-flight requests to complete when the
+	// runtime is shutting down.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// RevocationsFile is the path of the file revoked credentials are stored in. If set, the
+	// Authentication service serves RevokeCredential and providers reject revoked credentials.
+	RevocationsFile string `yaml:"revocations_file"`
+	// MaxCredentialLifetime is the longest any credential the runtime accepts remains valid for.
+	// If set, revocations are discarded once every credential they match has expired.
+	MaxCredentialLifetime time.Duration `yaml:"max_credential_lifetime"`
+	// Authentication configures the provider for the Authentication service. It is required.
+	Authentication *providerConfig `yaml:"authentication"`
+	// Authorization configures the provider for the Authorization service, if any.
+	Authorization *providerConfig `yaml:"authorization"`
+	// Identity configures the provider for the Identity service, if any.
+	Identity *providerConfig `yaml:"identity"`
+	// Secrets configures the provider for the Secrets service, if any.
+	Secrets *providerConfig `yaml:"secrets"`
+	// Sessions configures the provider for the Sessions service, if any. Sessions are created from
+	// credentials accepted by the Authentication provider, and the Authentication service accepts
+	// the session tokens they issue as credentials of type CREDENTIAL_TYPE_SESSION.
+	Sessions *providerConfig `yaml:"sessions"`
+	// Audit configures the sink the Audit service writes recorded events to, if any.
+	Audit *providerConfig `yaml:"audit"`
+}
+
+// providerConfig selects a provider for a service and holds the provider's own configuration.
+type providerConfig struct {
+	// Provider is the name of the provider.
+	Provider string `yaml:"provider"`
+	// Config is the provider's configuration, which is decoded by the provider's factory.
+	Config yaml.Node `yaml:"config"`
+}
+
+// loadConfig reads and validates the configuration file at the given path.
+func loadConfig(path string) (*config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &config{
+		Socket:          defaultSocket,
+		ShutdownTimeout: defaultShutdownTimeout,
+	}
+
+	if err := decodeStrict(b, cfg); err != nil {
+		return nil, fmt.Errorf("error parsing config %s: %w", path, err)
+	}
+
+	if cfg.Socket == "" {
+		return nil, errors.New("socket is required")
+	}
+
+	if cfg.Authentication == nil {
+		return nil, errors.New("authentication is required")
+	}
+
+	return cfg, nil
+}
+
+// decode decodes the provider's configuration into out, rejecting unknown fields.
+func (c *providerConfig) decode(out any) error {
+	// An absent config section leaves out unchanged.
+	if c.Config.IsZero() {
+		return nil
+	}
+
+	// yaml.Node.Decode cannot reject unknown fields, so the node is re-encoded and decoded strictly.
+	b, err := yaml.Marshal(&c.Config)
+	if err != nil {
+		return err
+	}
+
+	if err := decodeStrict(b, out); err != nil {
+		return fmt.Errorf("error parsing %s provider config: %w", c.Provider, err)
+	}
+
+	return nil
+}
+
+func decodeStrict(b []byte, out any) error {
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+
+	return dec.Decode(out)
+}
@@ -0,0 +1,310 @@
+// Command iam-runtime is a reference IAM runtime which serves providers selected in a config file
+// on a unix socket.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/compat"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/audit"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
+	authorizationv1 "github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization"
+	authorization "github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization/v2"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/identity"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/policyadmin"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/runtimeinfo"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/secrets"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/sessions"
+	"github.com/metal-toolbox/iam-runtime/pkg/providers/chain"
+	"github.com/metal-toolbox/iam-runtime/pkg/revocation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	// readinessCheckTimeout is the maximum amount of time a single round of readiness checks may
+	// take.
+	readinessCheckTimeout = 10 * time.Second
+	// readinessRetryInterval is the amount of time to wait before retrying failed readiness checks.
+	readinessRetryInterval = 5 * time.Second
+)
+
+var configPath = flag.String("config", "/etc/iam-runtime/config.yaml", "Config file path")
+
+// newServices creates the services configured in cfg, adding the resources their providers hold
+// to res.
+func newServices(cfg *config, res *resources) ([]service, authentication.AuthenticationServer, error) {
+	if cfg.RevocationsFile != "" {
+		store, err := revocation.NewFileStore(cfg.RevocationsFile, cfg.MaxCredentialLifetime)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		res.revocations = store
+	}
+
+	newAuthn, err := lookupProvider("authentication", authenticationProviders, cfg.Authentication)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	authn, err := newAuthn(cfg.Authentication, res)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating authentication provider: %w", err)
+	}
+
+	var services []service
+
+	if cfg.Sessions != nil {
+		newSessions, err := lookupProvider("sessions", sessionsProviders, cfg.Sessions)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		sess, err := newSessions(cfg.Sessions, res, authn)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error creating sessions provider: %w", err)
+		}
+
+		// Session tokens are routed to the sessions provider, and every other credential to the
+		// Authentication provider as before.
+		authn, err = chain.NewServer(
+			chain.Provider{
+				Name:   cfg.Sessions.Provider,
+				Server: sess.Authentication(),
+			},
+			chain.Provider{
+				Name:   cfg.Authentication.Provider,
+				Server: authn,
+			},
+		)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error creating sessions provider: %w", err)
+		}
+
+		services = append(services, newService(&sessions.Sessions_ServiceDesc, sess))
+	}
+
+	if res.revocations != nil {
+		authn = newRevokingServer(authn, res.revocations)
+	}
+
+	services = append([]service{newService(&authentication.Authentication_ServiceDesc, authn)}, services...)
+
+	if cfg.Authorization != nil {
+		newAuthz, err := lookupProvider("authorization", authorizationProviders, cfg.Authorization)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		authz, err := newAuthz(cfg.Authorization, res, authn)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error creating authorization provider: %w", err)
+		}
+
+		authzSvc := newService(&authorization.Authorization_ServiceDesc, authz)
+		authzV1Svc := newCompatService(&authorizationv1.Authorization_ServiceDesc, compat.NewAuthorizationV1Server(authz), authzSvc)
+
+		services = append(services, authzSvc, authzV1Svc)
+
+		if admin, ok := authz.(policyAdminProvider); ok {
+			services = append(services, newService(&policyadmin.PolicyAdmin_ServiceDesc, admin.PolicyAdmin()))
+		}
+	}
+
+	if cfg.Identity != nil {
+		newIdentity, err := lookupProvider("identity", identityProviders, cfg.Identity)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		ident, err := newIdentity(cfg.Identity, res)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error creating identity provider: %w", err)
+		}
+
+		services = append(services, newService(&identity.Identity_ServiceDesc, ident))
+	}
+
+	if cfg.Secrets != nil {
+		newSecrets, err := lookupProvider("secrets", secretsProviders, cfg.Secrets)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		secretsSrv, err := newSecrets(cfg.Secrets, res)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error creating secrets provider: %w", err)
+		}
+
+		services = append(services, newService(&secrets.Secrets_ServiceDesc, secretsSrv))
+	}
+
+	if cfg.Audit != nil {
+		newAudit, err := lookupProvider("audit", auditProviders, cfg.Audit)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		auditSrv, err := newAudit(cfg.Audit, res)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error creating audit provider: %w", err)
+		}
+
+		services = append(services, newService(&audit.Audit_ServiceDesc, auditSrv))
+	}
+
+	return services, authn, nil
+}
+
+// listen listens on the unix socket at path, removing any stale socket left by a previous run.
+func listen(path string) (net.Listener, error) {
+	if info, err := os.Stat(path); err == nil {
+		if info.Mode().Type() != os.ModeSocket {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+
+		log.Printf("socket found at %s, unlinking", path)
+
+		if err := syscall.Unlink(path); err != nil {
+			return nil, fmt.Errorf("error unlinking socket: %w", err)
+		}
+	}
+
+	return net.Listen("unix", path)
+}
+
+func run(ctx context.Context, cfg *config) error {
+	res := &resources{}
+	defer res.close()
+
+	services, authn, err := newServices(cfg, res)
+	if err != nil {
+		return err
+	}
+
+	listener, err := listen(cfg.Socket)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+
+	// Report every service as not serving until all of them are registered and ready.
+	healthSrv := health.NewServer()
+	healthSrv.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	for _, svc := range services {
+		healthSrv.SetServingStatus(svc.desc.ServiceName, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, healthSrv)
+
+	for _, svc := range services {
+		srv.RegisterService(svc.desc, svc.impl)
+	}
+
+	runtimeinfo.RegisterRuntimeInfoServer(srv, newRuntimeInfoServer(services, authn))
+
+	serveErr := make(chan error, 1)
+
+	go func() {
+		serveErr <- srv.Serve(listener)
+	}()
+
+	log.Printf("runtime listening at %s", listener.Addr())
+
+	// Once the health server has been shut down, changes to serving status are ignored, so a
+	// shutdown while waiting for readiness leaves every service reported as not serving.
+	go func() {
+		if err := waitReady(ctx, res); err != nil {
+			return
+		}
+
+		for _, svc := range services {
+			healthSrv.SetServingStatus(svc.desc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+		}
+
+		healthSrv.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+
+		log.Printf("runtime ready")
+	}()
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("failed to serve: %w", err)
+	case <-ctx.Done():
+	}
+
+	log.Printf("shutting down")
+
+	// Tell clients watching health to stop sending requests before draining in-flight ones.
+	healthSrv.Shutdown()
+
+	stopped := make(chan struct{})
+
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(cfg.ShutdownTimeout):
+		log.Printf("timed out waiting for requests to complete, stopping")
+		srv.Stop()
+	}
+
+	if err := <-serveErr; err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return fmt.Errorf("failed to serve: %w", err)
+	}
+
+	return nil
+}
+
+// waitReady runs the readiness checks of providers until they all succeed, retrying failed checks
+// until ctx is done.
+func waitReady(ctx context.Context, res *resources) error {
+	for {
+		checkCtx, cancel := context.WithTimeout(ctx, readinessCheckTimeout)
+		err := res.ready(checkCtx)
+		cancel()
+
+		if err == nil {
+			return nil
+		}
+
+		log.Printf("providers not ready, retrying in %s: %v", readinessRetryInterval, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(readinessRetryInterval):
+		}
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, cfg); err != nil {
+		log.Fatalf("%v", err)
+	}
+}
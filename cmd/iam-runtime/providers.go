@@ -0,0 +1,451 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/audit"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
+	authorization "github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization/v2"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/identity"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/policyadmin"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/secrets"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/sessions"
+	"github.com/metal-toolbox/iam-runtime/pkg/providers/apikey"
+	"github.com/metal-toolbox/iam-runtime/pkg/providers/auditlog"
+	"github.com/metal-toolbox/iam-runtime/pkg/providers/celrules"
+	"github.com/metal-toolbox/iam-runtime/pkg/providers/chain"
+	"github.com/metal-toolbox/iam-runtime/pkg/providers/filesecrets"
+	"github.com/metal-toolbox/iam-runtime/pkg/providers/jwt"
+	"github.com/metal-toolbox/iam-runtime/pkg/providers/memsessions"
+	"github.com/metal-toolbox/iam-runtime/pkg/providers/policy"
+	"github.com/metal-toolbox/iam-runtime/pkg/providers/spicedb"
+	"github.com/metal-toolbox/iam-runtime/pkg/providers/static"
+	"github.com/metal-toolbox/iam-runtime/pkg/rebac"
+	"github.com/metal-toolbox/iam-runtime/pkg/relationships/postgres"
+	"github.com/metal-toolbox/iam-runtime/pkg/relationships/sqlite"
+	"github.com/metal-toolbox/iam-runtime/pkg/revocation"
+	"github.com/metal-toolbox/iam-runtime/pkg/roles"
+)
+
+type (
+	authenticationFactory func(cfg *providerConfig, res *resources) (authentication.AuthenticationServer, error)
+	authorizationFactory  func(cfg *providerConfig, res *resources, authn authentication.AuthenticationServer) (authorization.AuthorizationServer, error)
+	identityFactory       func(cfg *providerConfig, res *resources) (identity.IdentityServer, error)
+	secretsFactory        func(cfg *providerConfig, res *resources) (secrets.SecretsServer, error)
+	sessionsFactory       func(cfg *providerConfig, res *resources, authn authentication.AuthenticationServer) (sessionsServer, error)
+	auditFactory          func(cfg *providerConfig, res *resources) (audit.AuditServer, error)
+)
+
+// sessionsServer is a Sessions server which also validates the session tokens it issues.
+type sessionsServer interface {
+	sessions.SessionsServer
+
+	// Authentication returns an Authentication server which validates session tokens.
+	Authentication() authentication.AuthenticationServer
+}
+
+// policyAdminProvider is an Authorization server whose policies can be managed using PolicyAdmin.
+type policyAdminProvider interface {
+	// PolicyAdmin returns a PolicyAdmin server which manages the server's policies.
+	PolicyAdmin() policyadmin.PolicyAdminServer
+}
+
+// resources holds the resources shared by the providers of a runtime.
+type resources struct {
+	// revocations is the store of revoked credentials, if revocations are configured.
+	revocations *revocation.Store
+	// checks is the set of checks which must succeed before the runtime reports itself as serving.
+	checks []func(ctx context.Context) error
+	// closers is the set of functions which release resources held by providers, in the order the
+	// resources were acquired.
+	closers []func() error
+}
+
+// addCheck adds a readiness check.
+func (r *resources) addCheck(check func(ctx context.Context) error) {
+	r.checks = append(r.checks, check)
+}
+
+// addCloser adds a function which releases a resource when the runtime stops.
+func (r *resources) addCloser(closer func() error) {
+	r.closers = append(r.closers, closer)
+}
+
+// ready runs every readiness check, returning the first error.
+func (r *resources) ready(ctx context.Context) error {
+	for _, check := range r.checks {
+		if err := check(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// close releases every resource in the reverse of the order they were acquired.
+func (r *resources) close() {
+	for i := len(r.closers) - 1; i >= 0; i-- {
+		if err := r.closers[i](); err != nil {
+			log.Printf("error releasing provider resources: %v", err)
+		}
+	}
+}
+
+// authenticationProviders maps the name of each Authentication provider to its factory.
+var authenticationProviders = map[string]authenticationFactory{
+	"static": func(cfg *providerConfig, res *resources) (authentication.AuthenticationServer, error) {
+		var providerCfg static.AuthenticationConfig
+		if err := cfg.decode(&providerCfg); err != nil {
+			return nil, err
+		}
+
+		providerCfg.Revocations = res.revocations
+
+		return static.NewAuthenticationServer(providerCfg)
+	},
+	"jwt": func(cfg *providerConfig, res *resources) (authentication.AuthenticationServer, error) {
+		var providerCfg jwt.Config
+		if err := cfg.decode(&providerCfg); err != nil {
+			return nil, err
+		}
+
+		providerCfg.Revocations = res.revocations
+
+		srv, err := jwt.NewServer(providerCfg)
+		if err != nil {
+			return nil, err
+		}
+
+		res.addCheck(srv.Ready)
+
+		return srv, nil
+	},
+	"apikey": func(cfg *providerConfig, res *resources) (authentication.AuthenticationServer, error) {
+		var providerCfg apikey.Config
+		if err := cfg.decode(&providerCfg); err != nil {
+			return nil, err
+		}
+
+		providerCfg.Revocations = res.revocations
+
+		return apikey.NewServer(providerCfg)
+	},
+}
+
+func init() {
+	// The chain provider is registered separately since it creates other Authentication providers.
+	authenticationProviders["chain"] = newChainAuthentication
+}
+
+// chainConfig represents the configuration for the chain Authentication provider. Each provider in
+// the chain is configured like any other Authentication provider, along with the credentials which
+// are routed to it.
+type chainConfig struct {
+	Providers []chainProviderConfig `yaml:"providers"`
+}
+
+type chainProviderConfig struct {
+	providerConfig `yaml:",inline"`
+
+	// Prefixes is the set of prefixes of credentials routed to the provider.
+	Prefixes []string `yaml:"prefixes"`
+	// CredentialTypes is the set of types of credential routed to the provider, named as in the
+	// CredentialType enum, such as CREDENTIAL_TYPE_JWT.
+	CredentialTypes []string `yaml:"credential_types"`
+}
+
+func newChainAuthentication(cfg *providerConfig, res *resources) (authentication.AuthenticationServer, error) {
+	var chainCfg chainConfig
+	if err := cfg.decode(&chainCfg); err != nil {
+		return nil, err
+	}
+
+	providers := make([]chain.Provider, 0, len(chainCfg.Providers))
+
+	for i := range chainCfg.Providers {
+		providerCfg := &chainCfg.Providers[i]
+
+		newAuthn, err := lookupProvider("authentication", authenticationProviders, &providerCfg.providerConfig)
+		if err != nil {
+			return nil, fmt.Errorf("chain provider %d: %w", i, err)
+		}
+
+		srv, err := newAuthn(&providerCfg.providerConfig, res)
+		if err != nil {
+			return nil, fmt.Errorf("chain provider %d (%s): %w", i, providerCfg.Provider, err)
+		}
+
+		provider := chain.Provider{
+			Name:     providerCfg.Provider,
+			Server:   srv,
+			Prefixes: providerCfg.Prefixes,
+		}
+
+		for _, name := range providerCfg.CredentialTypes {
+			credType, ok := authentication.CredentialType_value[name]
+			if !ok {
+				return nil, fmt.Errorf("chain provider %d (%s): unknown credential type %q", i, providerCfg.Provider, name)
+			}
+
+			provider.CredentialTypes = append(provider.CredentialTypes, authentication.CredentialType(credType))
+		}
+
+		providers = append(providers, provider)
+	}
+
+	return chain.NewServer(providers...)
+}
+
+// authorizationProviders maps the name of each Authorization provider to its factory. Factories are
+// given the runtime's Authentication server for validating credentials.
+var authorizationProviders = map[string]authorizationFactory{
+	"static": func(cfg *providerConfig, res *resources, authn authentication.AuthenticationServer) (authorization.AuthorizationServer, error) {
+		var providerCfg static.AuthorizationConfig
+		if err := cfg.decode(&providerCfg); err != nil {
+			return nil, err
+		}
+
+		return static.NewAuthorizationServer(providerCfg, authn)
+	},
+	"rebac": newRebacAuthorization,
+	"policy": func(cfg *providerConfig, res *resources, authn authentication.AuthenticationServer) (authorization.AuthorizationServer, error) {
+		var providerCfg policy.Config
+		if err := cfg.decode(&providerCfg); err != nil {
+			return nil, err
+		}
+
+		return policy.NewServer(providerCfg, authn)
+	},
+	"cel": func(cfg *providerConfig, res *resources, authn authentication.AuthenticationServer) (authorization.AuthorizationServer, error) {
+		var providerCfg celrules.Config
+		if err := cfg.decode(&providerCfg); err != nil {
+			return nil, err
+		}
+
+		return celrules.NewServer(providerCfg, authn)
+	},
+	"spicedb": func(cfg *providerConfig, res *resources, authn authentication.AuthenticationServer) (authorization.AuthorizationServer, error) {
+		var providerCfg spicedb.Config
+		if err := cfg.decode(&providerCfg); err != nil {
+			return nil, err
+		}
+
+		srv, err := spicedb.NewServer(providerCfg, authn)
+		if err != nil {
+			return nil, err
+		}
+
+		res.addCheck(srv.Ready)
+		res.addCloser(srv.Close)
+
+		return srv, nil
+	},
+}
+
+// rebacConfig represents the configuration for the rebac Authorization provider.
+type rebacConfig struct {
+	// SchemaFile is the path of the schema file.
+	SchemaFile string `yaml:"schema_file"`
+	// DatabaseFile is the path of the SQLite database relationships are stored in.
+	DatabaseFile string `yaml:"database_file"`
+	// DatabaseURL is the connection string of the PostgreSQL database relationships are stored in.
+	DatabaseURL string `yaml:"database_url"`
+	// Separator separates the prefix of an ID from the rest of it.
+	Separator string `yaml:"separator"`
+	// Types maps each ID prefix to the type of resources with IDs with that prefix, such that
+	// relationships may be created on resources which are not registered.
+	Types map[string]string `yaml:"types"`
+	// Roles defines the roles which may be assigned on resources. Each role is assigned by creating
+	// a relationship with its relation, and its actions are the permissions that relation grants.
+	Roles []roles.Definition `yaml:"roles"`
+}
+
+// defaultRebacSeparator separates the prefix of an ID from the rest of it if no separator is
+// configured for the rebac provider.
+const defaultRebacSeparator = "-"
+
+func newRebacAuthorization(cfg *providerConfig, res *resources, authn authentication.AuthenticationServer) (authorization.AuthorizationServer, error) {
+	var providerCfg rebacConfig
+	if err := cfg.decode(&providerCfg); err != nil {
+		return nil, err
+	}
+
+	if providerCfg.SchemaFile == "" {
+		return nil, errors.New("schema_file is required")
+	}
+
+	b, err := os.ReadFile(providerCfg.SchemaFile)
+	if err != nil {
+		return nil, err
+	}
+
+	schema, err := rebac.ParseSchema(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing schema file %s: %w", providerCfg.SchemaFile, err)
+	}
+
+	// Without a database, relationships are stored in memory and lost when the runtime stops.
+	var store rebac.Store = rebac.NewMemoryStore()
+
+	switch {
+	case providerCfg.DatabaseFile != "" && providerCfg.DatabaseURL != "":
+		return nil, errors.New("only one of database_file and database_url may be set")
+	case providerCfg.DatabaseFile != "":
+		db, err := sqlite.Open(context.Background(), providerCfg.DatabaseFile)
+		if err != nil {
+			return nil, err
+		}
+
+		res.addCheck(db.Ping)
+		res.addCloser(db.Close)
+
+		store = db
+	case providerCfg.DatabaseURL != "":
+		db, err := postgres.Open(context.Background(), providerCfg.DatabaseURL)
+		if err != nil {
+			return nil, err
+		}
+
+		res.addCheck(db.Ping)
+		res.addCloser(func() error {
+			db.Close()
+
+			return nil
+		})
+
+		store = db
+	}
+
+	engineCfg := rebac.Config{
+		Schema: schema,
+		Store:  store,
+	}
+
+	if len(providerCfg.Types) > 0 {
+		engineCfg.ResourceType, err = prefixResourceType(schema, providerCfg.Separator, providerCfg.Types)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	engine, err := rebac.New(engineCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return rebac.NewServer(engine, authn, providerCfg.Roles...)
+}
+
+// prefixResourceType returns a function which determines the type of a resource from the prefix of
+// its ID, checking that every type is defined in the schema.
+func prefixResourceType(schema *rebac.Schema, separator string, types map[string]string) (func(id string) (string, bool), error) {
+	if separator == "" {
+		separator = defaultRebacSeparator
+	}
+
+	defined := schema.Types()
+
+	for prefix, typ := range types {
+		if !slices.Contains(defined, typ) {
+			return nil, fmt.Errorf("prefix %q maps to type %q, which is not defined in the schema", prefix, typ)
+		}
+	}
+
+	out := func(id string) (string, bool) {
+		prefix, _, ok := strings.Cut(id, separator)
+		if !ok {
+			return "", false
+		}
+
+		typ, ok := types[prefix]
+
+		return typ, ok
+	}
+
+	return out, nil
+}
+
+// identityProviders maps the name of each Identity provider to its factory.
+var identityProviders = map[string]identityFactory{
+	"static": func(cfg *providerConfig, res *resources) (identity.IdentityServer, error) {
+		var providerCfg static.IdentityConfig
+		if err := cfg.decode(&providerCfg); err != nil {
+			return nil, err
+		}
+
+		return static.NewIdentityServer(providerCfg)
+	},
+}
+
+// secretsProviders maps the name of each Secrets provider to its factory.
+var secretsProviders = map[string]secretsFactory{
+	"file": func(cfg *providerConfig, res *resources) (secrets.SecretsServer, error) {
+		var providerCfg filesecrets.Config
+		if err := cfg.decode(&providerCfg); err != nil {
+			return nil, err
+		}
+
+		return filesecrets.NewServer(providerCfg)
+	},
+}
+
+// sessionsProviders maps the name of each Sessions provider to its factory. Factories are given
+// the runtime's Authentication server for validating the credentials sessions are created from.
+var sessionsProviders = map[string]sessionsFactory{
+	"memory": func(cfg *providerConfig, res *resources, authn authentication.AuthenticationServer) (sessionsServer, error) {
+		var providerCfg memsessions.Config
+		if err := cfg.decode(&providerCfg); err != nil {
+			return nil, err
+		}
+
+		providerCfg.Revocations = res.revocations
+
+		return memsessions.NewServer(providerCfg, authn)
+	},
+}
+
+// auditProviders maps the name of each Audit provider to its factory.
+var auditProviders = map[string]auditFactory{
+	"stdout": func(cfg *providerConfig, res *resources) (audit.AuditServer, error) {
+		return auditlog.NewServer(os.Stdout), nil
+	},
+	"file": func(cfg *providerConfig, res *resources) (audit.AuditServer, error) {
+		var providerCfg auditlog.Config
+		if err := cfg.decode(&providerCfg); err != nil {
+			return nil, err
+		}
+
+		srv, err := auditlog.NewFileServer(providerCfg)
+		if err != nil {
+			return nil, err
+		}
+
+		res.addCloser(srv.Close)
+
+		return srv, nil
+	},
+}
+
+// lookupProvider returns the factory for the configured provider of the named service.
+func lookupProvider[F any](service string, factories map[string]F, cfg *providerConfig) (F, error) {
+	factory, ok := factories[cfg.Provider]
+	if !ok {
+		names := make([]string, 0, len(factories))
+		for name := range factories {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		return factory, fmt.Errorf("unknown %s provider %q (available: %s)", service, cfg.Provider, strings.Join(names, ", "))
+	}
+
+	return factory, nil
+}
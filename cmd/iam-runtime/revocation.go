@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
+	"github.com/metal-toolbox/iam-runtime/pkg/revocation"
+)
+
+// revokingServer is an Authentication server which serves RevokeCredential using a revocation
+// store, and every other RPC using the configured provider. Providers are given the same store, so
+// that they reject the credentials it revokes.
+type revokingServer struct {
+	authentication.AuthenticationServer
+
+	revocations *revocation.Store
+}
+
+func newRevokingServer(authn authentication.AuthenticationServer, revocations *revocation.Store) *revokingServer {
+	return &revokingServer{
+		AuthenticationServer: authn,
+		revocations:          revocations,
+	}
+}
+
+// RPCs returns the names of the RPCs the provider implements, along with RevokeCredential.
+func (s *revokingServer) RPCs() []string {
+	out := newService(&authentication.Authentication_ServiceDesc, s.AuthenticationServer).rpcs
+
+	return append(out, "RevokeCredential")
+}
+
+// CredentialTypes returns the types of credential the provider accepts, if it describes them.
+func (s *revokingServer) CredentialTypes() []authentication.CredentialType {
+	if lister, ok := s.AuthenticationServer.(credentialTypeLister); ok {
+		return lister.CredentialTypes()
+	}
+
+	return nil
+}
+
+// RevokeCredential stores a revocation for the credentials described by the request.
+func (s *revokingServer) RevokeCredential(ctx context.Context, req *authentication.RevokeCredentialRequest) (*authentication.RevokeCredentialResponse, error) {
+	return s.revocations.RevokeCredential(ctx, req)
+}
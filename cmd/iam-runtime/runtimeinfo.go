@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/runtimeinfo"
+	"google.golang.org/grpc"
+)
+
+// version is the version of the runtime, set at build time.
+var version = "dev"
+
+// rpcLister is implemented by providers which do not implement every RPC of their service.
+type rpcLister interface {
+	RPCs() []string
+}
+
+// credentialTypeLister is implemented by Authentication providers to describe the types of
+// credential they accept.
+type credentialTypeLister interface {
+	CredentialTypes() []authentication.CredentialType
+}
+
+// service is a service served by the runtime.
+type service struct {
+	desc *grpc.ServiceDesc
+	impl any
+	rpcs []string
+}
+
+// newService returns a service for the given implementation, which supports every RPC in desc
+// unless impl implements rpcLister.
+func newService(desc *grpc.ServiceDesc, impl any) service {
+	out := service{
+		desc: desc,
+		impl: impl,
+	}
+
+	if lister, ok := impl.(rpcLister); ok {
+		out.rpcs = lister.RPCs()
+
+		return out
+	}
+
+	for _, method := range desc.Methods {
+		out.rpcs = append(out.rpcs, method.MethodName)
+	}
+
+	for _, stream := range desc.Streams {
+		out.rpcs = append(out.rpcs, stream.StreamName)
+	}
+
+	return out
+}
+
+// newCompatService returns a service for an adapter which serves an older version of a service
+// using impl, supporting those RPCs in desc which impl supports.
+func newCompatService(desc *grpc.ServiceDesc, adapter any, impl service) service {
+	supported := make(map[string]bool, len(impl.rpcs))
+	for _, rpc := range impl.rpcs {
+		supported[rpc] = true
+	}
+
+	out := service{
+		desc: desc,
+		impl: adapter,
+	}
+
+	for _, method := range desc.Methods {
+		if supported[method.MethodName] {
+			out.rpcs = append(out.rpcs, method.MethodName)
+		}
+	}
+
+	return out
+}
+
+type runtimeInfoServer struct {
+	runtimeinfo.UnimplementedRuntimeInfoServer
+
+	info *runtimeinfo.GetRuntimeInfoResponse
+}
+
+func newRuntimeInfoServer(services []service, authn authentication.AuthenticationServer) *runtimeInfoServer {
+	info := &runtimeinfo.GetRuntimeInfoResponse{
+		Name:        "iam-runtime",
+		Version:     version,
+		SpecVersion: runtimeinfo.SpecVersion,
+	}
+
+	for _, svc := range services {
+		serviceInfo := &runtimeinfo.ServiceInfo{
+			Name: svc.desc.ServiceName,
+			Rpcs: svc.rpcs,
+		}
+
+		info.Services = append(info.Services, serviceInfo)
+	}
+
+	info.Services = append(info.Services, &runtimeinfo.ServiceInfo{
+		Name: runtimeinfo.RuntimeInfo_ServiceDesc.ServiceName,
+		Rpcs: []string{"GetRuntimeInfo"},
+	})
+
+	if lister, ok := authn.(credentialTypeLister); ok {
+		for _, credType := range lister.CredentialTypes() {
+			info.CredentialTypes = append(info.CredentialTypes, credType.String())
+		}
+	}
+
+	return &runtimeInfoServer{
+		info: info,
+	}
+}
+
+func (s *runtimeInfoServer) GetRuntimeInfo(ctx context.Context, req *runtimeinfo.GetRuntimeInfoRequest) (*runtimeinfo.GetRuntimeInfoResponse, error) {
+	return s.info, nil
+}
@@ -6,38 +6,119 @@ import (
 	"log"
 	"net"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"syscall"
+	"time"
 
+	"github.com/metal-toolbox/iam-runtime/pkg/compat"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/audit"
 	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
-	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization"
+	authorizationv1 "github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization"
+	authorization "github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization/v2"
 	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/identity"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/runtimeinfo"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/secrets"
+	"github.com/metal-toolbox/iam-runtime/pkg/providers/auditlog"
+	"github.com/metal-toolbox/iam-runtime/pkg/providers/filesecrets"
+	"github.com/metal-toolbox/iam-runtime/pkg/revocation"
+	"github.com/metal-toolbox/iam-runtime/pkg/roles"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/structpb"
 )
 
 var (
-	socket = flag.String("socket", "/tmp/runtime.sock", "Socket path")
+	socket      = flag.String("socket", "/tmp/runtime.sock", "Socket path")
+	revocations = flag.String("revocations", "/tmp/runtime-revocations.json", "Revocations file path")
+	secretsDir  = flag.String("secrets", "", "Directory secrets are served from; if empty, the Secrets service is not served")
 )
 
+// helloCredential describes the only credential the runtime knows about for revocation purposes.
+var helloCredential = revocation.Credential{
+	SubjectID: "hello",
+}
+
+// worldType is the type of every resource the runtime knows about.
+const worldType = "world"
+
 type authorizationServer struct {
 	authorization.UnimplementedAuthorizationServer
+
+	authn         authentication.AuthenticationServer
+	relationships *relationshipStore
+	roles         *roles.Roles
+}
+
+func newAuthorizationServer(authn authentication.AuthenticationServer) (*authorizationServer, error) {
+	relationships := newRelationshipStore()
+
+	greeter := roles.Definition{
+		ResourceType: worldType,
+		Name:         "greeter",
+		Description:  "Allows greeting the world",
+		Actions:      []string{"greet"},
+	}
+
+	rolesSrv, err := roles.New(relationships, greeter)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &authorizationServer{
+		authn:         authn,
+		relationships: relationships,
+		roles:         rolesSrv,
+	}
+
+	// The hello subject may greet the world until the role is unassigned.
+	assignReq := &authorization.AssignRoleRequest{
+		Resource: &authorization.ResourceReference{
+			ResourceType: worldType,
+			ResourceId:   "world",
+		},
+		Role:      greeter.Name,
+		SubjectId: "hello",
+	}
+
+	if _, err := rolesSrv.AssignRole(context.Background(), assignReq); err != nil {
+		return nil, err
+	}
+
+	return out, nil
 }
 
 func (s *authorizationServer) CheckAccess(ctx context.Context, req *authorization.CheckAccessRequest) (*authorization.CheckAccessResponse, error) {
-	tok := req.GetCredential()
+	validateReq := &authentication.ValidateCredentialRequest{
+		Credential: req.GetCredential(),
+	}
+
+	validateResp, err := s.authn.ValidateCredential(ctx, validateReq)
+	if err != nil {
+		return nil, err
+	}
 
-	log.Printf("received token: %s", tok)
-	if tok != "hello" {
+	if validateResp.GetResult() != authentication.ValidateCredentialResponse_RESULT_VALID {
 		err := status.Error(codes.InvalidArgument, "who are you?")
 		return nil, err
 	}
 
+	subjectID := validateResp.GetSubject().GetSubjectId()
+
 	result := authorization.CheckAccessResponse_RESULT_ALLOWED
 
+	// Access is granted only by roles assigned on the requested resource.
 	for _, action := range req.Actions {
-		if action.GetAction() != "greet" || action.GetResourceId() != "world" {
+		allowed, err := s.roles.Allows(ctx, worldResource(action.GetResourceId()), subjectID, action.GetAction())
+		if err != nil {
+			return nil, err
+		}
+
+		if !allowed {
 			result = authorization.CheckAccessResponse_RESULT_DENIED
 		}
 	}
@@ -49,21 +130,116 @@ func (s *authorizationServer) CheckAccess(ctx context.Context, req *authorizatio
 	return out, nil
 }
 
+// worldResource returns a reference to the resource with the given ID. Every resource the runtime
+// knows about is a world.
+func worldResource(id string) *authorization.ResourceReference {
+	return &authorization.ResourceReference{
+		ResourceType: worldType,
+		ResourceId:   id,
+	}
+}
+
+func validateRelationships(resourceID string, relationships []*authorization.Relationship) error {
+	if resourceID == "" {
+		return status.Error(codes.InvalidArgument, "resource_id is required")
+	}
+
+	for _, rel := range relationships {
+		if rel.GetRelation() == "" || rel.GetSubjectId() == "" {
+			return status.Error(codes.InvalidArgument, "relation and subject_id are required")
+		}
+	}
+
+	return nil
+}
+
+func (s *authorizationServer) CreateRelationships(ctx context.Context, req *authorization.CreateRelationshipsRequest) (*authorization.CreateRelationshipsResponse, error) {
+	if err := validateRelationships(req.GetResourceId(), req.GetRelationships()); err != nil {
+		return nil, err
+	}
+
+	if err := s.relationships.CreateRelationships(ctx, worldResource(req.GetResourceId()), req.GetRelationships()); err != nil {
+		return nil, err
+	}
+
+	return &authorization.CreateRelationshipsResponse{}, nil
+}
+
+func (s *authorizationServer) DeleteRelationships(ctx context.Context, req *authorization.DeleteRelationshipsRequest) (*authorization.DeleteRelationshipsResponse, error) {
+	if err := validateRelationships(req.GetResourceId(), req.GetRelationships()); err != nil {
+		return nil, err
+	}
+
+	if err := s.relationships.DeleteRelationships(ctx, worldResource(req.GetResourceId()), req.GetRelationships()); err != nil {
+		return nil, err
+	}
+
+	return &authorization.DeleteRelationshipsResponse{}, nil
+}
+
+func (s *authorizationServer) ListRoles(ctx context.Context, req *authorization.ListRolesRequest) (*authorization.ListRolesResponse, error) {
+	return s.roles.ListRoles(ctx, req)
+}
+
+func (s *authorizationServer) AssignRole(ctx context.Context, req *authorization.AssignRoleRequest) (*authorization.AssignRoleResponse, error) {
+	return s.roles.AssignRole(ctx, req)
+}
+
+func (s *authorizationServer) UnassignRole(ctx context.Context, req *authorization.UnassignRoleRequest) (*authorization.UnassignRoleResponse, error) {
+	return s.roles.UnassignRole(ctx, req)
+}
+
+func (s *authorizationServer) ListRoleBindings(ctx context.Context, req *authorization.ListRoleBindingsRequest) (*authorization.ListRoleBindingsResponse, error) {
+	return s.roles.ListRoleBindings(ctx, req)
+}
+
 type authenticationServer struct {
 	authentication.UnimplementedAuthenticationServer
+
+	revocations *revocation.Store
 }
 
+// helloAudience is the audience the hello credential is issued for.
+const helloAudience = "world"
+
 func (s *authenticationServer) ValidateCredential(ctx context.Context, req *authentication.ValidateCredentialRequest) (*authentication.ValidateCredentialResponse, error) {
+	switch req.GetCredentialType() {
+	case authentication.CredentialType_CREDENTIAL_TYPE_UNSPECIFIED, authentication.CredentialType_CREDENTIAL_TYPE_API_KEY:
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported credential type %s", req.GetCredentialType())
+	}
+
 	if req.Credential != "hello" {
 		out := &authentication.ValidateCredentialResponse{
-			Result: authentication.ValidateCredentialResponse_RESULT_INVALID,
+			Result:        authentication.ValidateCredentialResponse_RESULT_INVALID,
+			InvalidReason: authentication.ValidateCredentialResponse_INVALID_REASON_UNKNOWN_CREDENTIAL,
+		}
+
+		return out, nil
+	}
+
+	if aud := req.GetAudience(); aud != "" && aud != helloAudience {
+		out := &authentication.ValidateCredentialResponse{
+			Result:        authentication.ValidateCredentialResponse_RESULT_INVALID,
+			InvalidReason: authentication.ValidateCredentialResponse_INVALID_REASON_AUDIENCE_MISMATCH,
+		}
+
+		return out, nil
+	}
+
+	if r, revoked := s.revocations.IsRevoked(helloCredential); revoked {
+		log.Printf("rejecting revoked credential: %s", r.Reason)
+
+		out := &authentication.ValidateCredentialResponse{
+			Result:        authentication.ValidateCredentialResponse_RESULT_INVALID,
+			InvalidReason: authentication.ValidateCredentialResponse_INVALID_REASON_REVOKED,
 		}
 
 		return out, nil
 	}
 
 	claimsMap := map[string]any{
-		"aud": "world",
+		"aud": helloAudience,
 	}
 
 	claims, err := structpb.NewStruct(claimsMap)
@@ -82,6 +258,35 @@ func (s *authenticationServer) ValidateCredential(ctx context.Context, req *auth
 	return out, nil
 }
 
+func (s *authenticationServer) GetSubject(ctx context.Context, req *authentication.GetSubjectRequest) (*authentication.GetSubjectResponse, error) {
+	if req.GetSubjectId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "subject_id is required")
+	}
+
+	if req.GetSubjectId() != "hello" {
+		return nil, status.Error(codes.NotFound, "who is that?")
+	}
+
+	out := &authentication.GetSubjectResponse{
+		Subject: &authentication.Subject{
+			SubjectId: "hello",
+		},
+		Profile: &authentication.SubjectProfile{
+			DisplayName: "Hello",
+		},
+		Groups: []string{
+			"greeters",
+		},
+		MaxAge: durationpb.New(time.Minute),
+	}
+
+	return out, nil
+}
+
+func (s *authenticationServer) RevokeCredential(ctx context.Context, req *authentication.RevokeCredentialRequest) (*authentication.RevokeCredentialResponse, error) {
+	return s.revocations.RevokeCredential(ctx, req)
+}
+
 type identityServer struct {
 	identity.UnimplementedIdentityServer
 }
@@ -94,9 +299,99 @@ func (s *identityServer) GetAccessToken(ctx context.Context, req *identity.GetAc
 	return out, nil
 }
 
+type runtimeInfoServer struct {
+	runtimeinfo.UnimplementedRuntimeInfoServer
+
+	secrets bool
+}
+
+func (s *runtimeInfoServer) GetRuntimeInfo(ctx context.Context, req *runtimeinfo.GetRuntimeInfoRequest) (*runtimeinfo.GetRuntimeInfoResponse, error) {
+	out := &runtimeinfo.GetRuntimeInfoResponse{
+		Name:        "hello-world",
+		Version:     "dev",
+		SpecVersion: runtimeinfo.SpecVersion,
+		Services: []*runtimeinfo.ServiceInfo{
+			{
+				Name: authorization.Authorization_ServiceDesc.ServiceName,
+				Rpcs: []string{
+					"CheckAccess",
+					"CreateRelationships",
+					"DeleteRelationships",
+					"ListRoles",
+					"AssignRole",
+					"UnassignRole",
+					"ListRoleBindings",
+				},
+			},
+			{
+				Name: authorizationv1.Authorization_ServiceDesc.ServiceName,
+				Rpcs: []string{"CheckAccess", "CreateRelationships", "DeleteRelationships"},
+			},
+			{
+				Name: authentication.Authentication_ServiceDesc.ServiceName,
+				Rpcs: []string{"ValidateCredential", "GetSubject", "RevokeCredential"},
+			},
+			{
+				Name: identity.Identity_ServiceDesc.ServiceName,
+				Rpcs: []string{"GetAccessToken"},
+			},
+			{
+				Name: audit.Audit_ServiceDesc.ServiceName,
+				Rpcs: []string{"RecordEvent", "RecordEvents"},
+			},
+			{
+				Name: runtimeinfo.RuntimeInfo_ServiceDesc.ServiceName,
+				Rpcs: []string{"GetRuntimeInfo"},
+			},
+		},
+		CredentialTypes: []string{
+			authentication.CredentialType_CREDENTIAL_TYPE_API_KEY.String(),
+		},
+	}
+
+	if s.secrets {
+		out.Services = append(out.Services, &runtimeinfo.ServiceInfo{
+			Name: secrets.Secrets_ServiceDesc.ServiceName,
+			Rpcs: []string{"GetSecret", "WatchSecret"},
+		})
+	}
+
+	return out, nil
+}
+
+// newSecretsServer creates a Secrets server for the directory given by the -secrets flag. The
+// runtime's workload may only access secrets under "hello/".
+func newSecretsServer() (*filesecrets.Server, error) {
+	cfg := filesecrets.Config{
+		Dir:      *secretsDir,
+		Workload: "hello-world",
+		Access: map[string][]string{
+			"hello-world": {"hello/*"},
+		},
+	}
+
+	return filesecrets.NewServer(cfg)
+}
+
+// checkReady reports whether the runtime's dependencies are available. The only dependency of this
+// runtime is the revocations file, which must be writable for RevokeCredential to succeed.
+func checkReady() error {
+	f, err := os.CreateTemp(filepath.Dir(*revocations), ".ready-*")
+	if err != nil {
+		return err
+	}
+
+	f.Close()
+
+	return os.Remove(f.Name())
+}
+
 func main() {
 	flag.Parse()
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	if _, err := os.Stat(*socket); err == nil {
 		log.Printf("socket found at %s, unlinking", *socket)
 		if err := syscall.Unlink(*socket); err != nil {
@@ -104,19 +399,110 @@ func main() {
 		}
 	}
 
+	// The hello credential never expires, so revocations are kept indefinitely.
+	revocationStore, err := revocation.NewFileStore(*revocations, 0)
+	if err != nil {
+		log.Fatalf("failed to load revocations: %v", err)
+	}
+
+	authnSrv := &authenticationServer{
+		revocations: revocationStore,
+	}
+
+	authzSrv, err := newAuthorizationServer(authnSrv)
+	if err != nil {
+		log.Fatalf("failed to create authorization server: %v", err)
+	}
+
+	var secretsSrv *filesecrets.Server
+
+	if *secretsDir != "" {
+		secretsSrv, err = newSecretsServer()
+		if err != nil {
+			log.Fatalf("failed to create secrets server: %v", err)
+		}
+	}
+
 	listener, err := net.Listen("unix", *socket)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
 
+	services := []string{
+		authorization.Authorization_ServiceDesc.ServiceName,
+		authorizationv1.Authorization_ServiceDesc.ServiceName,
+		authentication.Authentication_ServiceDesc.ServiceName,
+		identity.Identity_ServiceDesc.ServiceName,
+		audit.Audit_ServiceDesc.ServiceName,
+		runtimeinfo.RuntimeInfo_ServiceDesc.ServiceName,
+	}
+
+	if secretsSrv != nil {
+		services = append(services, secrets.Secrets_ServiceDesc.ServiceName)
+	}
+
+	// Report every service as not serving until all of them are registered and ready.
+	healthSrv := health.NewServer()
+	healthSrv.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	for _, service := range services {
+		healthSrv.SetServingStatus(service, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+
 	srv := grpc.NewServer()
-	authorization.RegisterAuthorizationServer(srv, &authorizationServer{})
-	authentication.RegisterAuthenticationServer(srv, &authenticationServer{})
+	healthpb.RegisterHealthServer(srv, healthSrv)
+	authorization.RegisterAuthorizationServer(srv, authzSrv)
+	authorizationv1.RegisterAuthorizationServer(srv, compat.NewAuthorizationV1Server(authzSrv))
+	authentication.RegisterAuthenticationServer(srv, authnSrv)
 	identity.RegisterIdentityServer(srv, &identityServer{})
+	audit.RegisterAuditServer(srv, auditlog.NewServer(os.Stdout))
+	runtimeinfo.RegisterRuntimeInfoServer(srv, &runtimeInfoServer{secrets: secretsSrv != nil})
+
+	if secretsSrv != nil {
+		secrets.RegisterSecretsServer(srv, secretsSrv)
+	}
+
+	serveErr := make(chan error, 1)
+
+	go func() {
+		serveErr <- srv.Serve(listener)
+	}()
 
 	log.Printf("runtime listening at %s", listener.Addr())
 
-	if err := srv.Serve(listener); err != nil {
+	// Serving status is only set once the runtime's dependencies are available. Changes made after
+	// the health server is shut down are ignored.
+	go func() {
+		for {
+			err := checkReady()
+			if err == nil {
+				break
+			}
+
+			log.Printf("runtime not ready, retrying: %v", err)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(5 * time.Second):
+			}
+		}
+
+		for _, service := range services {
+			healthSrv.SetServingStatus(service, healthpb.HealthCheckResponse_SERVING)
+		}
+
+		healthSrv.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	}()
+
+	select {
+	case err := <-serveErr:
 		log.Fatalf("failed to serve: %v", err)
+	case <-ctx.Done():
 	}
+
+	log.Printf("shutting down")
+
+	healthSrv.Shutdown()
+	srv.GracefulStop()
 }
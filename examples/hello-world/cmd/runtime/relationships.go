@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	authorization "github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization/v2"
+)
+
+// resourceKey identifies a resource by both its type and ID.
+type resourceKey struct {
+	resourceType string
+	resourceID   string
+}
+
+func keyOf(resource *authorization.ResourceReference) resourceKey {
+	return resourceKey{resource.GetResourceType(), resource.GetResourceId()}
+}
+
+type relationshipKey struct {
+	relation  string
+	subjectID string
+}
+
+// relationshipStore is an in-memory store of relationships keyed by resource type and ID.
+type relationshipStore struct {
+	mu            sync.RWMutex
+	relationships map[resourceKey]map[relationshipKey]struct{}
+}
+
+func newRelationshipStore() *relationshipStore {
+	return &relationshipStore{
+		relationships: make(map[resourceKey]map[relationshipKey]struct{}),
+	}
+}
+
+func (s *relationshipStore) CreateRelationships(ctx context.Context, resource *authorization.ResourceReference, relationships []*authorization.Relationship) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rels, ok := s.relationships[keyOf(resource)]
+	if !ok {
+		rels = make(map[relationshipKey]struct{})
+		s.relationships[keyOf(resource)] = rels
+	}
+
+	for _, rel := range relationships {
+		rels[relationshipKey{rel.GetRelation(), rel.GetSubjectId()}] = struct{}{}
+	}
+
+	return nil
+}
+
+func (s *relationshipStore) DeleteRelationships(ctx context.Context, resource *authorization.ResourceReference, relationships []*authorization.Relationship) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rels := s.relationships[keyOf(resource)]
+
+	for _, rel := range relationships {
+		delete(rels, relationshipKey{rel.GetRelation(), rel.GetSubjectId()})
+	}
+
+	return nil
+}
+
+func (s *relationshipStore) ListRelationships(ctx context.Context, resource *authorization.ResourceReference) ([]*authorization.Relationship, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var out []*authorization.Relationship
+
+	for key := range s.relationships[keyOf(resource)] {
+		rel := &authorization.Relationship{
+			Relation:  key.relation,
+			SubjectId: key.subjectID,
+		}
+
+		out = append(out, rel)
+	}
+
+	return out, nil
+}
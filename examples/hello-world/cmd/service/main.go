@@ -1,12 +1,15 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/metal-toolbox/iam-runtime/pkg/client"
 	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
 	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization"
 	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/identity"
@@ -17,6 +20,7 @@ import (
 var (
 	runtime = flag.String("runtime", "unix:///tmp/runtime.sock", "Runtime socket")
 	address = flag.String("address", ":8080", "Server port")
+	timeout = flag.Duration("ready-timeout", 30*time.Second, "Time to wait for the runtime to be ready")
 )
 
 func getToken(req *http.Request) string {
@@ -38,6 +42,14 @@ func writeMessage(w http.ResponseWriter, status int, msg string) {
 	}
 }
 
+func writeUnauthorized(w http.ResponseWriter, reason authentication.ValidateCredentialResponse_InvalidReason) {
+	desc := strings.TrimPrefix(reason.String(), "INVALID_REASON_")
+	desc = strings.ToLower(strings.ReplaceAll(desc, "_", " "))
+
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="invalid_token", error_description=%q`, desc))
+	writeMessage(w, http.StatusUnauthorized, "who are you?")
+}
+
 func (s *server) handleWhoAmI(w http.ResponseWriter, req *http.Request) {
 	validateRequest := &authentication.ValidateCredentialRequest{
 		Credential: getToken(req),
@@ -52,7 +64,7 @@ func (s *server) handleWhoAmI(w http.ResponseWriter, req *http.Request) {
 	}
 
 	if resp.Result == authentication.ValidateCredentialResponse_RESULT_INVALID {
-		writeMessage(w, http.StatusUnauthorized, "who are you?")
+		writeUnauthorized(w, resp.InvalidReason)
 
 		return
 	}
@@ -81,7 +93,7 @@ func (s *server) handleCanI(w http.ResponseWriter, req *http.Request) {
 	}
 
 	if credsResp.Result == authentication.ValidateCredentialResponse_RESULT_INVALID {
-		writeMessage(w, http.StatusUnauthorized, "who are you?")
+		writeUnauthorized(w, credsResp.InvalidReason)
 
 		return
 	}
@@ -139,6 +151,24 @@ func main() {
 		log.Fatalf("error connecting to runtime: %v", err)
 	}
 
+	err = client.WaitForReady(context.Background(), conn, *timeout,
+		authorization.Authorization_ServiceDesc.ServiceName,
+		authentication.Authentication_ServiceDesc.ServiceName,
+		identity.Identity_ServiceDesc.ServiceName,
+	)
+	if err != nil {
+		log.Fatalf("error waiting for runtime: %v", err)
+	}
+
+	err = client.RequireRPCs(context.Background(), conn,
+		authorization.Authorization_CheckAccess_FullMethodName,
+		authentication.Authentication_ValidateCredential_FullMethodName,
+		identity.Identity_GetAccessToken_FullMethodName,
+	)
+	if err != nil {
+		log.Fatalf("runtime is not compatible: %v", err)
+	}
+
 	var runtime struct {
 		authorization.AuthorizationClient
 		authentication.AuthenticationClient
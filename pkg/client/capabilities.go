@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/runtimeinfo"
+	"google.golang.org/grpc"
+)
+
+// ErrUnsupported is returned when the runtime does not implement an RPC the client requires.
+var ErrUnsupported = errors.New("runtime does not implement required RPCs")
+
+// SupportsRPC reports whether info advertises support for the RPC with the given full method
+// name, such as "/runtime.iam.v1.Authorization/CreateRelationships".
+func SupportsRPC(info *runtimeinfo.GetRuntimeInfoResponse, fullMethod string) bool {
+	service, method, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !ok {
+		return false
+	}
+
+	for _, svc := range info.GetServices() {
+		if svc.GetName() != service {
+			continue
+		}
+
+		for _, rpc := range svc.GetRpcs() {
+			if rpc == method {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// RequireRPCs returns an error wrapping ErrUnsupported if the runtime does not implement every
+// RPC with the given full method names. Clients should call RequireRPCs at startup to fail fast
+// when a runtime lacks optional RPCs they depend on.
+func RequireRPCs(ctx context.Context, conn grpc.ClientConnInterface, fullMethods ...string) error {
+	info, err := runtimeinfo.NewRuntimeInfoClient(conn).GetRuntimeInfo(ctx, &runtimeinfo.GetRuntimeInfoRequest{})
+	if err != nil {
+		return fmt.Errorf("error getting runtime info: %w", err)
+	}
+
+	var missing []string
+
+	for _, method := range fullMethods {
+		if !SupportsRPC(info, method) {
+			missing = append(missing, method)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", ErrUnsupported, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
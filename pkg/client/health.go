@@ -0,0 +1,86 @@
+// Package client provides helpers for workloads connecting to an IAM runtime.
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// healthRetryInterval is the amount of time to wait before reopening a health watch stream that
+// was terminated by the runtime.
+const healthRetryInterval = 250 * time.Millisecond
+
+// WaitForReady blocks until the runtime reports that all of the given services are serving, or
+// until the timeout elapses. Services are identified by their fully qualified names, such as
+// "runtime.iam.v1.Authentication". If no services are given, WaitForReady waits for the runtime's
+// overall health status to be serving.
+func WaitForReady(ctx context.Context, conn grpc.ClientConnInterface, timeout time.Duration, services ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if len(services) == 0 {
+		services = []string{""}
+	}
+
+	client := healthpb.NewHealthClient(conn)
+
+	for _, service := range services {
+		if err := waitForService(ctx, client, service); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func waitForService(ctx context.Context, client healthpb.HealthClient, service string) error {
+	name := service
+	if name == "" {
+		name = "runtime"
+	}
+
+	for {
+		err := watchUntilServing(ctx, client, service)
+		if err == nil {
+			return nil
+		}
+
+		if status.Code(err) == codes.Unimplemented {
+			return fmt.Errorf("runtime does not support health checking: %w", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for %s to be ready: %w", name, err)
+		case <-time.After(healthRetryInterval):
+		}
+	}
+}
+
+func watchUntilServing(ctx context.Context, client healthpb.HealthClient, service string) error {
+	req := &healthpb.HealthCheckRequest{
+		Service: service,
+	}
+
+	stream, err := client.Watch(ctx, req, grpc.WaitForReady(true))
+	if err != nil {
+		return err
+	}
+
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		if resp.GetStatus() == healthpb.HealthCheckResponse_SERVING {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Package compat provides adapters which allow clients of older versions of IAM runtime services
+// to use runtimes which implement newer versions.
+package compat
+
+import (
+	"context"
+
+	authorizationv1 "github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization"
+	authorizationv2 "github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+type authorizationV1Server struct {
+	authorizationv1.UnimplementedAuthorizationServer
+
+	srv authorizationv2.AuthorizationServer
+}
+
+// NewAuthorizationV1Server returns a runtime.iam.v1 Authorization server which handles requests
+// using the given runtime.iam.v2 Authorization server. Runtimes which implement v2 should register
+// the returned server alongside their v2 implementation so that v1 clients continue to work.
+func NewAuthorizationV1Server(srv authorizationv2.AuthorizationServer) authorizationv1.AuthorizationServer {
+	return &authorizationV1Server{
+		srv: srv,
+	}
+}
+
+func (s *authorizationV1Server) CheckAccess(ctx context.Context, req *authorizationv1.CheckAccessRequest) (*authorizationv1.CheckAccessResponse, error) {
+	v2Req := &authorizationv2.CheckAccessRequest{}
+	if err := convert(req, v2Req); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.srv.CheckAccess(ctx, v2Req)
+	if err != nil {
+		return nil, err
+	}
+
+	var result authorizationv1.CheckAccessResponse_Result
+
+	switch resp.GetResult() {
+	case authorizationv2.CheckAccessResponse_RESULT_ALLOWED:
+		result = authorizationv1.CheckAccessResponse_RESULT_ALLOWED
+	case authorizationv2.CheckAccessResponse_RESULT_DENIED:
+		result = authorizationv1.CheckAccessResponse_RESULT_DENIED
+	default:
+		return nil, status.Errorf(codes.Internal, "runtime returned unexpected result %s", resp.GetResult())
+	}
+
+	out := &authorizationv1.CheckAccessResponse{
+		Result: result,
+	}
+
+	return out, nil
+}
+
+func (s *authorizationV1Server) CreateRelationships(ctx context.Context, req *authorizationv1.CreateRelationshipsRequest) (*authorizationv1.CreateRelationshipsResponse, error) {
+	v2Req := &authorizationv2.CreateRelationshipsRequest{}
+	if err := convert(req, v2Req); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.srv.CreateRelationships(ctx, v2Req); err != nil {
+		return nil, err
+	}
+
+	return &authorizationv1.CreateRelationshipsResponse{}, nil
+}
+
+func (s *authorizationV1Server) DeleteRelationships(ctx context.Context, req *authorizationv1.DeleteRelationshipsRequest) (*authorizationv1.DeleteRelationshipsResponse, error) {
+	v2Req := &authorizationv2.DeleteRelationshipsRequest{}
+	if err := convert(req, v2Req); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.srv.DeleteRelationships(ctx, v2Req); err != nil {
+		return nil, err
+	}
+
+	return &authorizationv1.DeleteRelationshipsResponse{}, nil
+}
+
+// convert copies src into dst using the protobuf wire format. It must only be used for messages
+// whose wire representations are compatible between versions.
+func convert(src, dst proto.Message) error {
+	b, err := proto.Marshal(src)
+	if err != nil {
+		return status.Errorf(codes.Internal, "error converting %s: %v", src.ProtoReflect().Descriptor().FullName(), err)
+	}
+
+	if err := proto.Unmarshal(b, dst); err != nil {
+		return status.Errorf(codes.Internal, "error converting %s: %v", src.ProtoReflect().Descriptor().FullName(), err)
+	}
+
+	return nil
+}
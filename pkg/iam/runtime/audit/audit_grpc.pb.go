@@ -0,0 +1,181 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: audit/audit.proto
+
+package audit
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Audit_RecordEvent_FullMethodName  = "/runtime.iam.v1.Audit/RecordEvent"
+	Audit_RecordEvents_FullMethodName = "/runtime.iam.v1.Audit/RecordEvents"
+)
+
+// AuditClient is the client API for Audit service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AuditClient interface {
+	RecordEvent(ctx context.Context, in *RecordEventRequest, opts ...grpc.CallOption) (*RecordEventResponse, error)
+	RecordEvents(ctx context.Context, opts ...grpc.CallOption) (Audit_RecordEventsClient, error)
+}
+
+type auditClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAuditClient(cc grpc.ClientConnInterface) AuditClient {
+	return &auditClient{cc}
+}
+
+func (c *auditClient) RecordEvent(ctx context.Context, in *RecordEventRequest, opts ...grpc.CallOption) (*RecordEventResponse, error) {
+	out := new(RecordEventResponse)
+	err := c.cc.Invoke(ctx, Audit_RecordEvent_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *auditClient) RecordEvents(ctx context.Context, opts ...grpc.CallOption) (Audit_RecordEventsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Audit_ServiceDesc.Streams[0], Audit_RecordEvents_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &auditRecordEventsClient{stream}
+	return x, nil
+}
+
+type Audit_RecordEventsClient interface {
+	Send(*RecordEventRequest) error
+	CloseAndRecv() (*RecordEventsResponse, error)
+	grpc.ClientStream
+}
+
+type auditRecordEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *auditRecordEventsClient) Send(m *RecordEventRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *auditRecordEventsClient) CloseAndRecv() (*RecordEventsResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(RecordEventsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// AuditServer is the server API for Audit service.
+// All implementations must embed UnimplementedAuditServer
+// for forward compatibility
+type AuditServer interface {
+	RecordEvent(context.Context, *RecordEventRequest) (*RecordEventResponse, error)
+	RecordEvents(Audit_RecordEventsServer) error
+	mustEmbedUnimplementedAuditServer()
+}
+
+// UnimplementedAuditServer must be embedded to have forward compatible implementations.
+type UnimplementedAuditServer struct {
+}
+
+func (UnimplementedAuditServer) RecordEvent(context.Context, *RecordEventRequest) (*RecordEventResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RecordEvent not implemented")
+}
+func (UnimplementedAuditServer) RecordEvents(Audit_RecordEventsServer) error {
+	return status.Errorf(codes.Unimplemented, "method RecordEvents not implemented")
+}
+func (UnimplementedAuditServer) mustEmbedUnimplementedAuditServer() {}
+
+// UnsafeAuditServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AuditServer will
+// result in compilation errors.
+type UnsafeAuditServer interface {
+	mustEmbedUnimplementedAuditServer()
+}
+
+func RegisterAuditServer(s grpc.ServiceRegistrar, srv AuditServer) {
+	s.RegisterService(&Audit_ServiceDesc, srv)
+}
+
+func _Audit_RecordEvent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RecordEventRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuditServer).RecordEvent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Audit_RecordEvent_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuditServer).RecordEvent(ctx, req.(*RecordEventRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Audit_RecordEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(AuditServer).RecordEvents(&auditRecordEventsServer{stream})
+}
+
+type Audit_RecordEventsServer interface {
+	SendAndClose(*RecordEventsResponse) error
+	Recv() (*RecordEventRequest, error)
+	grpc.ServerStream
+}
+
+type auditRecordEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *auditRecordEventsServer) SendAndClose(m *RecordEventsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *auditRecordEventsServer) Recv() (*RecordEventRequest, error) {
+	m := new(RecordEventRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Audit_ServiceDesc is the grpc.ServiceDesc for Audit service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Audit_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "runtime.iam.v1.Audit",
+	HandlerType: (*AuditServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "RecordEvent",
+			Handler:    _Audit_RecordEvent_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "RecordEvents",
+			Handler:       _Audit_RecordEvents_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "audit/audit.proto",
+}
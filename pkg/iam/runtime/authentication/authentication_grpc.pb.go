@@ -1,7 +1,7 @@
 // Code generated by protoc-gen-go-grpc. DO NOT EDIT.
 // versions:
 // - protoc-gen-go-grpc v1.3.0
-// - protoc             v5.26.1
+// - protoc             (unknown)
 // source: authentication/authentication.proto
 
 package authentication
@@ -20,6 +20,10 @@ const _ = grpc.SupportPackageIsVersion7
 
 const (
 	Authentication_ValidateCredential_FullMethodName = "/runtime.iam.v1.Authentication/ValidateCredential"
+	Authentication_GetTrustBundle_FullMethodName     = "/runtime.iam.v1.Authentication/GetTrustBundle"
+	Authentication_WatchTrustBundle_FullMethodName   = "/runtime.iam.v1.Authentication/WatchTrustBundle"
+	Authentication_GetSubject_FullMethodName         = "/runtime.iam.v1.Authentication/GetSubject"
+	Authentication_RevokeCredential_FullMethodName   = "/runtime.iam.v1.Authentication/RevokeCredential"
 )
 
 // AuthenticationClient is the client API for Authentication service.
@@ -27,6 +31,10 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type AuthenticationClient interface {
 	ValidateCredential(ctx context.Context, in *ValidateCredentialRequest, opts ...grpc.CallOption) (*ValidateCredentialResponse, error)
+	GetTrustBundle(ctx context.Context, in *GetTrustBundleRequest, opts ...grpc.CallOption) (*GetTrustBundleResponse, error)
+	WatchTrustBundle(ctx context.Context, in *WatchTrustBundleRequest, opts ...grpc.CallOption) (Authentication_WatchTrustBundleClient, error)
+	GetSubject(ctx context.Context, in *GetSubjectRequest, opts ...grpc.CallOption) (*GetSubjectResponse, error)
+	RevokeCredential(ctx context.Context, in *RevokeCredentialRequest, opts ...grpc.CallOption) (*RevokeCredentialResponse, error)
 }
 
 type authenticationClient struct {
@@ -46,11 +54,74 @@ func (c *authenticationClient) ValidateCredential(ctx context.Context, in *Valid
 	return out, nil
 }
 
+func (c *authenticationClient) GetTrustBundle(ctx context.Context, in *GetTrustBundleRequest, opts ...grpc.CallOption) (*GetTrustBundleResponse, error) {
+	out := new(GetTrustBundleResponse)
+	err := c.cc.Invoke(ctx, Authentication_GetTrustBundle_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authenticationClient) WatchTrustBundle(ctx context.Context, in *WatchTrustBundleRequest, opts ...grpc.CallOption) (Authentication_WatchTrustBundleClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Authentication_ServiceDesc.Streams[0], Authentication_WatchTrustBundle_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &authenticationWatchTrustBundleClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Authentication_WatchTrustBundleClient interface {
+	Recv() (*WatchTrustBundleResponse, error)
+	grpc.ClientStream
+}
+
+type authenticationWatchTrustBundleClient struct {
+	grpc.ClientStream
+}
+
+func (x *authenticationWatchTrustBundleClient) Recv() (*WatchTrustBundleResponse, error) {
+	m := new(WatchTrustBundleResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *authenticationClient) GetSubject(ctx context.Context, in *GetSubjectRequest, opts ...grpc.CallOption) (*GetSubjectResponse, error) {
+	out := new(GetSubjectResponse)
+	err := c.cc.Invoke(ctx, Authentication_GetSubject_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authenticationClient) RevokeCredential(ctx context.Context, in *RevokeCredentialRequest, opts ...grpc.CallOption) (*RevokeCredentialResponse, error) {
+	out := new(RevokeCredentialResponse)
+	err := c.cc.Invoke(ctx, Authentication_RevokeCredential_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // AuthenticationServer is the server API for Authentication service.
 // All implementations must embed UnimplementedAuthenticationServer
 // for forward compatibility
 type AuthenticationServer interface {
 	ValidateCredential(context.Context, *ValidateCredentialRequest) (*ValidateCredentialResponse, error)
+	GetTrustBundle(context.Context, *GetTrustBundleRequest) (*GetTrustBundleResponse, error)
+	WatchTrustBundle(*WatchTrustBundleRequest, Authentication_WatchTrustBundleServer) error
+	GetSubject(context.Context, *GetSubjectRequest) (*GetSubjectResponse, error)
+	RevokeCredential(context.Context, *RevokeCredentialRequest) (*RevokeCredentialResponse, error)
 	mustEmbedUnimplementedAuthenticationServer()
 }
 
@@ -61,6 +132,18 @@ type UnimplementedAuthenticationServer struct {
 func (UnimplementedAuthenticationServer) ValidateCredential(context.Context, *ValidateCredentialRequest) (*ValidateCredentialResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ValidateCredential not implemented")
 }
+func (UnimplementedAuthenticationServer) GetTrustBundle(context.Context, *GetTrustBundleRequest) (*GetTrustBundleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTrustBundle not implemented")
+}
+func (UnimplementedAuthenticationServer) WatchTrustBundle(*WatchTrustBundleRequest, Authentication_WatchTrustBundleServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchTrustBundle not implemented")
+}
+func (UnimplementedAuthenticationServer) GetSubject(context.Context, *GetSubjectRequest) (*GetSubjectResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSubject not implemented")
+}
+func (UnimplementedAuthenticationServer) RevokeCredential(context.Context, *RevokeCredentialRequest) (*RevokeCredentialResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeCredential not implemented")
+}
 func (UnimplementedAuthenticationServer) mustEmbedUnimplementedAuthenticationServer() {}
 
 // UnsafeAuthenticationServer may be embedded to opt out of forward compatibility for this service.
@@ -92,6 +175,81 @@ func _Authentication_ValidateCredential_Handler(srv interface{}, ctx context.Con
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Authentication_GetTrustBundle_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTrustBundleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthenticationServer).GetTrustBundle(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Authentication_GetTrustBundle_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthenticationServer).GetTrustBundle(ctx, req.(*GetTrustBundleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Authentication_WatchTrustBundle_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchTrustBundleRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AuthenticationServer).WatchTrustBundle(m, &authenticationWatchTrustBundleServer{stream})
+}
+
+type Authentication_WatchTrustBundleServer interface {
+	Send(*WatchTrustBundleResponse) error
+	grpc.ServerStream
+}
+
+type authenticationWatchTrustBundleServer struct {
+	grpc.ServerStream
+}
+
+func (x *authenticationWatchTrustBundleServer) Send(m *WatchTrustBundleResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Authentication_GetSubject_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSubjectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthenticationServer).GetSubject(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Authentication_GetSubject_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthenticationServer).GetSubject(ctx, req.(*GetSubjectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Authentication_RevokeCredential_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeCredentialRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthenticationServer).RevokeCredential(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Authentication_RevokeCredential_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthenticationServer).RevokeCredential(ctx, req.(*RevokeCredentialRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Authentication_ServiceDesc is the grpc.ServiceDesc for Authentication service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -103,7 +261,25 @@ var Authentication_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ValidateCredential",
 			Handler:    _Authentication_ValidateCredential_Handler,
 		},
+		{
+			MethodName: "GetTrustBundle",
+			Handler:    _Authentication_GetTrustBundle_Handler,
+		},
+		{
+			MethodName: "GetSubject",
+			Handler:    _Authentication_GetSubject_Handler,
+		},
+		{
+			MethodName: "RevokeCredential",
+			Handler:    _Authentication_RevokeCredential_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchTrustBundle",
+			Handler:       _Authentication_WatchTrustBundle_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "authentication/authentication.proto",
 }
@@ -0,0 +1,183 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: policyadmin/policyadmin.proto
+
+package policyadmin
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	PolicyAdmin_GetSchema_FullMethodName      = "/runtime.iam.v1.PolicyAdmin/GetSchema"
+	PolicyAdmin_ValidateSchema_FullMethodName = "/runtime.iam.v1.PolicyAdmin/ValidateSchema"
+	PolicyAdmin_ApplySchema_FullMethodName    = "/runtime.iam.v1.PolicyAdmin/ApplySchema"
+)
+
+// PolicyAdminClient is the client API for PolicyAdmin service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PolicyAdminClient interface {
+	GetSchema(ctx context.Context, in *GetSchemaRequest, opts ...grpc.CallOption) (*GetSchemaResponse, error)
+	ValidateSchema(ctx context.Context, in *ValidateSchemaRequest, opts ...grpc.CallOption) (*ValidateSchemaResponse, error)
+	ApplySchema(ctx context.Context, in *ApplySchemaRequest, opts ...grpc.CallOption) (*ApplySchemaResponse, error)
+}
+
+type policyAdminClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPolicyAdminClient(cc grpc.ClientConnInterface) PolicyAdminClient {
+	return &policyAdminClient{cc}
+}
+
+func (c *policyAdminClient) GetSchema(ctx context.Context, in *GetSchemaRequest, opts ...grpc.CallOption) (*GetSchemaResponse, error) {
+	out := new(GetSchemaResponse)
+	err := c.cc.Invoke(ctx, PolicyAdmin_GetSchema_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *policyAdminClient) ValidateSchema(ctx context.Context, in *ValidateSchemaRequest, opts ...grpc.CallOption) (*ValidateSchemaResponse, error) {
+	out := new(ValidateSchemaResponse)
+	err := c.cc.Invoke(ctx, PolicyAdmin_ValidateSchema_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *policyAdminClient) ApplySchema(ctx context.Context, in *ApplySchemaRequest, opts ...grpc.CallOption) (*ApplySchemaResponse, error) {
+	out := new(ApplySchemaResponse)
+	err := c.cc.Invoke(ctx, PolicyAdmin_ApplySchema_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PolicyAdminServer is the server API for PolicyAdmin service.
+// All implementations must embed UnimplementedPolicyAdminServer
+// for forward compatibility
+type PolicyAdminServer interface {
+	GetSchema(context.Context, *GetSchemaRequest) (*GetSchemaResponse, error)
+	ValidateSchema(context.Context, *ValidateSchemaRequest) (*ValidateSchemaResponse, error)
+	ApplySchema(context.Context, *ApplySchemaRequest) (*ApplySchemaResponse, error)
+	mustEmbedUnimplementedPolicyAdminServer()
+}
+
+// UnimplementedPolicyAdminServer must be embedded to have forward compatible implementations.
+type UnimplementedPolicyAdminServer struct {
+}
+
+func (UnimplementedPolicyAdminServer) GetSchema(context.Context, *GetSchemaRequest) (*GetSchemaResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSchema not implemented")
+}
+func (UnimplementedPolicyAdminServer) ValidateSchema(context.Context, *ValidateSchemaRequest) (*ValidateSchemaResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ValidateSchema not implemented")
+}
+func (UnimplementedPolicyAdminServer) ApplySchema(context.Context, *ApplySchemaRequest) (*ApplySchemaResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ApplySchema not implemented")
+}
+func (UnimplementedPolicyAdminServer) mustEmbedUnimplementedPolicyAdminServer() {}
+
+// UnsafePolicyAdminServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PolicyAdminServer will
+// result in compilation errors.
+type UnsafePolicyAdminServer interface {
+	mustEmbedUnimplementedPolicyAdminServer()
+}
+
+func RegisterPolicyAdminServer(s grpc.ServiceRegistrar, srv PolicyAdminServer) {
+	s.RegisterService(&PolicyAdmin_ServiceDesc, srv)
+}
+
+func _PolicyAdmin_GetSchema_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSchemaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PolicyAdminServer).GetSchema(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PolicyAdmin_GetSchema_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PolicyAdminServer).GetSchema(ctx, req.(*GetSchemaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PolicyAdmin_ValidateSchema_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ValidateSchemaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PolicyAdminServer).ValidateSchema(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PolicyAdmin_ValidateSchema_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PolicyAdminServer).ValidateSchema(ctx, req.(*ValidateSchemaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PolicyAdmin_ApplySchema_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ApplySchemaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PolicyAdminServer).ApplySchema(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PolicyAdmin_ApplySchema_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PolicyAdminServer).ApplySchema(ctx, req.(*ApplySchemaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// PolicyAdmin_ServiceDesc is the grpc.ServiceDesc for PolicyAdmin service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var PolicyAdmin_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "runtime.iam.v1.PolicyAdmin",
+	HandlerType: (*PolicyAdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetSchema",
+			Handler:    _PolicyAdmin_GetSchema_Handler,
+		},
+		{
+			MethodName: "ValidateSchema",
+			Handler:    _PolicyAdmin_ValidateSchema_Handler,
+		},
+		{
+			MethodName: "ApplySchema",
+			Handler:    _PolicyAdmin_ApplySchema_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "policyadmin/policyadmin.proto",
+}
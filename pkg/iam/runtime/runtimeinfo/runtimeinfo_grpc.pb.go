@@ -0,0 +1,109 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: runtimeinfo/runtimeinfo.proto
+
+package runtimeinfo
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	RuntimeInfo_GetRuntimeInfo_FullMethodName = "/runtime.iam.v1.RuntimeInfo/GetRuntimeInfo"
+)
+
+// RuntimeInfoClient is the client API for RuntimeInfo service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type RuntimeInfoClient interface {
+	GetRuntimeInfo(ctx context.Context, in *GetRuntimeInfoRequest, opts ...grpc.CallOption) (*GetRuntimeInfoResponse, error)
+}
+
+type runtimeInfoClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRuntimeInfoClient(cc grpc.ClientConnInterface) RuntimeInfoClient {
+	return &runtimeInfoClient{cc}
+}
+
+func (c *runtimeInfoClient) GetRuntimeInfo(ctx context.Context, in *GetRuntimeInfoRequest, opts ...grpc.CallOption) (*GetRuntimeInfoResponse, error) {
+	out := new(GetRuntimeInfoResponse)
+	err := c.cc.Invoke(ctx, RuntimeInfo_GetRuntimeInfo_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RuntimeInfoServer is the server API for RuntimeInfo service.
+// All implementations must embed UnimplementedRuntimeInfoServer
+// for forward compatibility
+type RuntimeInfoServer interface {
+	GetRuntimeInfo(context.Context, *GetRuntimeInfoRequest) (*GetRuntimeInfoResponse, error)
+	mustEmbedUnimplementedRuntimeInfoServer()
+}
+
+// UnimplementedRuntimeInfoServer must be embedded to have forward compatible implementations.
+type UnimplementedRuntimeInfoServer struct {
+}
+
+func (UnimplementedRuntimeInfoServer) GetRuntimeInfo(context.Context, *GetRuntimeInfoRequest) (*GetRuntimeInfoResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRuntimeInfo not implemented")
+}
+func (UnimplementedRuntimeInfoServer) mustEmbedUnimplementedRuntimeInfoServer() {}
+
+// UnsafeRuntimeInfoServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RuntimeInfoServer will
+// result in compilation errors.
+type UnsafeRuntimeInfoServer interface {
+	mustEmbedUnimplementedRuntimeInfoServer()
+}
+
+func RegisterRuntimeInfoServer(s grpc.ServiceRegistrar, srv RuntimeInfoServer) {
+	s.RegisterService(&RuntimeInfo_ServiceDesc, srv)
+}
+
+func _RuntimeInfo_GetRuntimeInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRuntimeInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RuntimeInfoServer).GetRuntimeInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RuntimeInfo_GetRuntimeInfo_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RuntimeInfoServer).GetRuntimeInfo(ctx, req.(*GetRuntimeInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// RuntimeInfo_ServiceDesc is the grpc.ServiceDesc for RuntimeInfo service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RuntimeInfo_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "runtime.iam.v1.RuntimeInfo",
+	HandlerType: (*RuntimeInfoServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetRuntimeInfo",
+			Handler:    _RuntimeInfo_GetRuntimeInfo_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "runtimeinfo/runtimeinfo.proto",
+}
@@ -0,0 +1,5 @@
+package runtimeinfo
+
+// SpecVersion is the version of the IAM runtime specification implemented by the runtimes in this
+// module, reported as GetRuntimeInfoResponse.spec_version.
+const SpecVersion = "1.5.0"
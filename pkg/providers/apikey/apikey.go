@@ -0,0 +1,375 @@
+// Package apikey provides an Authentication service implementation which validates long-lived API
+// keys against a file of key hashes.
+//
+// The keys file is a YAML document listing each key's hash along with the subject it identifies:
+//
+//	keys:
+//	  - id: ci-deploy
+//	    prefix: iam_ci_
+//	    hash: $argon2id$v=19$m=65536,t=3,p=4$...$...
+//	    subject_id: ci
+//	    audiences:
+//	      - deploy-api
+//	    claims:
+//	      team: platform
+//
+// Keys with a prefix are only checked against credentials beginning with that prefix, which avoids
+// computing a hash for every configured key on each request. Since argon2id and bcrypt hashes are
+// deliberately expensive to compute, keys with such hashes must have a prefix; only keys with
+// salted SHA-256 hashes may omit it. The file is reloaded when it changes; if the new contents are
+// not valid, the previously loaded keys remain in use.
+package apikey
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
+	"github.com/metal-toolbox/iam-runtime/pkg/revocation"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultReloadInterval is the minimum interval between checks of the keys file for changes if no
+// interval is configured.
+const DefaultReloadInterval = 5 * time.Second
+
+// Config represents the configuration for an API key Authentication server.
+type Config struct {
+	// KeysFile is the path of the keys file.
+	KeysFile string `yaml:"keys_file"`
+	// ReloadInterval is the minimum interval between checks of the keys file for changes.
+	ReloadInterval time.Duration `yaml:"reload_interval"`
+	// Revocations is the store of revoked credentials, if any. Keys are matched against
+	// revocations by their ID, subject ID, and issue time.
+	Revocations *revocation.Store `yaml:"-"`
+}
+
+// Key describes an API key in a keys file.
+type Key struct {
+	// ID identifies the key in logs. It must be unique within the file.
+	ID string `yaml:"id"`
+	// Prefix is a non-secret prefix of the key. It is required unless the key has a salted SHA-256
+	// hash.
+	Prefix string `yaml:"prefix"`
+	// Hash is the hash of the key.
+	Hash string `yaml:"hash"`
+	// SubjectID is the ID of the subject the key identifies.
+	SubjectID string `yaml:"subject_id"`
+	// Audiences is the set of audiences the key is valid for. If empty, the key is only valid for
+	// requests which do not specify an audience.
+	Audiences []string `yaml:"audiences"`
+	// Claims is the set of claims returned for the subject.
+	Claims map[string]any `yaml:"claims"`
+	// IssuedAt is the time the key was issued, if known. Keys with no issue time are revoked by
+	// any revocation limited to credentials issued before a given time.
+	IssuedAt time.Time `yaml:"issued_at"`
+	// ExpiresAt is the time after which the key is no longer valid, if any.
+	ExpiresAt time.Time `yaml:"expires_at"`
+}
+
+type keysFile struct {
+	Keys []Key `yaml:"keys"`
+}
+
+type key struct {
+	Key
+
+	verifier verifier
+	claims   *structpb.Struct
+}
+
+// keySet is an index of the keys in a keys file.
+type keySet struct {
+	byPrefix      map[string][]*key
+	prefixLens    []int
+	withoutPrefix []*key
+}
+
+// Server is an Authentication server which validates API keys.
+type Server struct {
+	authentication.UnimplementedAuthenticationServer
+
+	path           string
+	reloadInterval time.Duration
+	revocations    *revocation.Store
+
+	mu          sync.Mutex
+	keys        *keySet
+	modTime     time.Time
+	size        int64
+	lastChecked time.Time
+}
+
+// NewServer creates a new Server using the given config. The keys file must exist and be valid.
+func NewServer(cfg Config) (*Server, error) {
+	if cfg.KeysFile == "" {
+		return nil, errors.New("keys_file is required")
+	}
+
+	reloadInterval := cfg.ReloadInterval
+	if reloadInterval <= 0 {
+		reloadInterval = DefaultReloadInterval
+	}
+
+	out := &Server{
+		path:           cfg.KeysFile,
+		reloadInterval: reloadInterval,
+		revocations:    cfg.Revocations,
+	}
+
+	info, err := os.Stat(cfg.KeysFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := out.load(info); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// RPCs returns the names of the Authentication RPCs the server implements.
+func (s *Server) RPCs() []string {
+	return []string{"ValidateCredential"}
+}
+
+// CredentialTypes returns the types of credential the server accepts.
+func (s *Server) CredentialTypes() []authentication.CredentialType {
+	return []authentication.CredentialType{authentication.CredentialType_CREDENTIAL_TYPE_API_KEY}
+}
+
+// ValidateCredential checks the given credential against the hashes of the keys it may match.
+func (s *Server) ValidateCredential(ctx context.Context, req *authentication.ValidateCredentialRequest) (*authentication.ValidateCredentialResponse, error) {
+	switch req.GetCredentialType() {
+	case authentication.CredentialType_CREDENTIAL_TYPE_UNSPECIFIED, authentication.CredentialType_CREDENTIAL_TYPE_API_KEY:
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported credential type %s", req.GetCredentialType())
+	}
+
+	credential := req.GetCredential()
+	if credential == "" {
+		return invalid(authentication.ValidateCredentialResponse_INVALID_REASON_MALFORMED), nil
+	}
+
+	// Hashes are computed without holding mu, so that checking a key does not delay other requests.
+	k := s.currentKeys().find(credential)
+	if k == nil {
+		return invalid(authentication.ValidateCredentialResponse_INVALID_REASON_UNKNOWN_CREDENTIAL), nil
+	}
+
+	if !k.hasAudience(req.GetAudience()) {
+		return invalid(authentication.ValidateCredentialResponse_INVALID_REASON_AUDIENCE_MISMATCH), nil
+	}
+
+	if !k.ExpiresAt.IsZero() && time.Now().After(k.ExpiresAt) {
+		return invalid(authentication.ValidateCredentialResponse_INVALID_REASON_EXPIRED), nil
+	}
+
+	if s.isRevoked(k) {
+		return invalid(authentication.ValidateCredentialResponse_INVALID_REASON_REVOKED), nil
+	}
+
+	out := &authentication.ValidateCredentialResponse{
+		Result: authentication.ValidateCredentialResponse_RESULT_VALID,
+		Subject: &authentication.Subject{
+			SubjectId: k.SubjectID,
+			Claims:    k.claims,
+		},
+	}
+
+	if !k.ExpiresAt.IsZero() {
+		out.ExpiresAt = timestamppb.New(k.ExpiresAt)
+	}
+
+	return out, nil
+}
+
+// isRevoked reports whether the given key has been revoked.
+func (s *Server) isRevoked(k *key) bool {
+	if s.revocations == nil {
+		return false
+	}
+
+	cred := revocation.Credential{
+		TokenID:   k.ID,
+		SubjectID: k.SubjectID,
+		IssuedAt:  k.IssuedAt,
+	}
+
+	_, revoked := s.revocations.IsRevoked(cred)
+
+	return revoked
+}
+
+// currentKeys returns the loaded keys, reloading the keys file first if it has changed.
+func (s *Server) currentKeys() *keySet {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if time.Since(s.lastChecked) < s.reloadInterval {
+		return s.keys
+	}
+
+	s.lastChecked = time.Now()
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		log.Printf("error checking keys file %s, keeping previously loaded keys: %v", s.path, err)
+
+		return s.keys
+	}
+
+	if info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return s.keys
+	}
+
+	if err := s.load(info); err != nil {
+		log.Printf("error reloading keys file, keeping previously loaded keys: %v", err)
+
+		return s.keys
+	}
+
+	log.Printf("reloaded keys file %s", s.path)
+
+	return s.keys
+}
+
+// load reads the keys file, which has the given file info. It must be called with mu held, except
+// during construction.
+func (s *Server) load(info os.FileInfo) error {
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+
+	var file keysFile
+
+	if err := yaml.Unmarshal(b, &file); err != nil {
+		return fmt.Errorf("error parsing keys file %s: %w", s.path, err)
+	}
+
+	keys, err := newKeySet(file.Keys)
+	if err != nil {
+		return fmt.Errorf("error loading keys file %s: %w", s.path, err)
+	}
+
+	s.keys = keys
+	s.modTime = info.ModTime()
+	s.size = info.Size()
+
+	return nil
+}
+
+func newKeySet(keys []Key) (*keySet, error) {
+	out := &keySet{
+		byPrefix: make(map[string][]*key),
+	}
+
+	ids := make(map[string]bool, len(keys))
+	prefixLens := make(map[int]bool)
+
+	for i, k := range keys {
+		if k.ID == "" || k.Hash == "" || k.SubjectID == "" {
+			return nil, fmt.Errorf("key %d: id, hash, and subject_id are required", i)
+		}
+
+		if ids[k.ID] {
+			return nil, fmt.Errorf("key %s: duplicate id", k.ID)
+		}
+
+		ids[k.ID] = true
+
+		v, err := parseHash(k.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", k.ID, err)
+		}
+
+		if k.Prefix == "" && v.expensive() {
+			return nil, fmt.Errorf("key %s: prefix is required for argon2id and bcrypt hashes", k.ID)
+		}
+
+		claims, err := structpb.NewStruct(k.Claims)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: error converting claims: %w", k.ID, err)
+		}
+
+		entry := &key{
+			Key:      k,
+			verifier: v,
+			claims:   claims,
+		}
+
+		if k.Prefix == "" {
+			out.withoutPrefix = append(out.withoutPrefix, entry)
+
+			continue
+		}
+
+		prefixLens[len(k.Prefix)] = true
+		out.byPrefix[k.Prefix] = append(out.byPrefix[k.Prefix], entry)
+	}
+
+	for n := range prefixLens {
+		out.prefixLens = append(out.prefixLens, n)
+	}
+
+	// Check longer prefixes first, so that the most specific prefix matches.
+	sort.Sort(sort.Reverse(sort.IntSlice(out.prefixLens)))
+
+	return out, nil
+}
+
+// find returns the key matching the given credential, if any.
+func (s *keySet) find(credential string) *key {
+	for _, n := range s.prefixLens {
+		if n > len(credential) {
+			continue
+		}
+
+		for _, k := range s.byPrefix[credential[:n]] {
+			if k.verifier.verify(credential) {
+				return k
+			}
+		}
+	}
+
+	for _, k := range s.withoutPrefix {
+		if k.verifier.verify(credential) {
+			return k
+		}
+	}
+
+	return nil
+}
+
+func (k *key) hasAudience(audience string) bool {
+	if audience == "" {
+		return true
+	}
+
+	for _, aud := range k.Audiences {
+		if aud == audience {
+			return true
+		}
+	}
+
+	return false
+}
+
+func invalid(reason authentication.ValidateCredentialResponse_InvalidReason) *authentication.ValidateCredentialResponse {
+	return &authentication.ValidateCredentialResponse{
+		Result:        authentication.ValidateCredentialResponse_RESULT_INVALID,
+		InvalidReason: reason,
+	}
+}
@@ -0,0 +1,231 @@
+package apikey
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
+	"github.com/metal-toolbox/iam-runtime/pkg/revocation"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gopkg.in/yaml.v3"
+)
+
+// writeKeys writes a keys file, setting its modification time to mtime so that changes are
+// detected regardless of the resolution of the file system's timestamps.
+func writeKeys(t *testing.T, path string, keys []Key, mtime time.Time) {
+	t.Helper()
+
+	b, err := yaml.Marshal(keysFile{Keys: keys})
+	if err != nil {
+		t.Fatalf("error encoding keys: %v", err)
+	}
+
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatalf("error writing keys file: %v", err)
+	}
+
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatalf("error setting keys file time: %v", err)
+	}
+}
+
+func validate(t *testing.T, srv *Server, credential, audience string) *authentication.ValidateCredentialResponse {
+	t.Helper()
+
+	req := &authentication.ValidateCredentialRequest{
+		Credential: credential,
+		Audience:   audience,
+	}
+
+	resp, err := srv.ValidateCredential(context.Background(), req)
+	if err != nil {
+		t.Fatalf("error validating credential: %v", err)
+	}
+
+	return resp
+}
+
+func TestValidateCredential(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+
+	revocations, err := revocation.NewFileStore(filepath.Join(t.TempDir(), "revocations.json"), 0)
+	if err != nil {
+		t.Fatalf("error creating revocation store: %v", err)
+	}
+
+	writeKeys(t, path, []Key{
+		{ID: "general", Prefix: "iam_", Hash: argon2idTestHash("iam_general", 64, 1, 1), SubjectID: "general"},
+		{ID: "ci", Prefix: "iam_ci_", Hash: bcryptTestHash(t, "iam_ci_deploy"), SubjectID: "ci", Audiences: []string{"deploy-api"}, Claims: map[string]any{"team": "platform"}},
+		{ID: "legacy", Hash: sha256TestHash("legacy-key"), SubjectID: "legacy"},
+		{ID: "expired", Prefix: "iam_", Hash: sha256TestHash("iam_expired"), SubjectID: "expired", ExpiresAt: time.Now().Add(-time.Hour)},
+		{ID: "revoked", Prefix: "iam_", Hash: sha256TestHash("iam_revoked"), SubjectID: "revoked"},
+	}, time.Now())
+
+	if err := revocations.Revoke(revocation.Revocation{TokenID: "revoked"}); err != nil {
+		t.Fatalf("error revoking key: %v", err)
+	}
+
+	srv, err := NewServer(Config{KeysFile: path, Revocations: revocations})
+	if err != nil {
+		t.Fatalf("error creating server: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		credential  string
+		audience    string
+		wantSubject string
+		wantReason  authentication.ValidateCredentialResponse_InvalidReason
+	}{
+		{name: "shorter prefix", credential: "iam_general", wantSubject: "general"},
+		{name: "longer prefix", credential: "iam_ci_deploy", wantSubject: "ci"},
+		{name: "audience", credential: "iam_ci_deploy", audience: "deploy-api", wantSubject: "ci"},
+		{name: "without prefix", credential: "legacy-key", wantSubject: "legacy"},
+		{name: "wrong key with prefix", credential: "iam_ci_other", wantReason: authentication.ValidateCredentialResponse_INVALID_REASON_UNKNOWN_CREDENTIAL},
+		{name: "wrong key", credential: "other", wantReason: authentication.ValidateCredentialResponse_INVALID_REASON_UNKNOWN_CREDENTIAL},
+		{name: "empty", credential: "", wantReason: authentication.ValidateCredentialResponse_INVALID_REASON_MALFORMED},
+		{name: "other audience", credential: "iam_ci_deploy", audience: "other-api", wantReason: authentication.ValidateCredentialResponse_INVALID_REASON_AUDIENCE_MISMATCH},
+		{name: "no audiences", credential: "iam_general", audience: "deploy-api", wantReason: authentication.ValidateCredentialResponse_INVALID_REASON_AUDIENCE_MISMATCH},
+		{name: "expired", credential: "iam_expired", wantReason: authentication.ValidateCredentialResponse_INVALID_REASON_EXPIRED},
+		{name: "revoked", credential: "iam_revoked", wantReason: authentication.ValidateCredentialResponse_INVALID_REASON_REVOKED},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := validate(t, srv, tt.credential, tt.audience)
+
+			if tt.wantSubject == "" {
+				if resp.GetResult() != authentication.ValidateCredentialResponse_RESULT_INVALID || resp.GetInvalidReason() != tt.wantReason {
+					t.Errorf("got result %s, reason %s, want invalid with reason %s", resp.GetResult(), resp.GetInvalidReason(), tt.wantReason)
+				}
+
+				return
+			}
+
+			if resp.GetResult() != authentication.ValidateCredentialResponse_RESULT_VALID {
+				t.Fatalf("got result %s, reason %s, want valid", resp.GetResult(), resp.GetInvalidReason())
+			}
+
+			if got := resp.GetSubject().GetSubjectId(); got != tt.wantSubject {
+				t.Errorf("got subject %s, want %s", got, tt.wantSubject)
+			}
+		})
+	}
+
+	resp := validate(t, srv, "iam_ci_deploy", "")
+	if got := resp.GetSubject().GetClaims().AsMap()["team"]; got != "platform" {
+		t.Errorf("got team claim %v, want platform", got)
+	}
+
+	req := &authentication.ValidateCredentialRequest{
+		Credential:     "iam_general",
+		CredentialType: authentication.CredentialType_CREDENTIAL_TYPE_JWT,
+	}
+
+	if _, err := srv.ValidateCredential(context.Background(), req); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("got error %v for JWT credential type, want %s", err, codes.InvalidArgument)
+	}
+}
+
+func TestNewServerInvalidKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []Key
+		want string
+	}{
+		{
+			name: "argon2id without prefix",
+			keys: []Key{{ID: "a", Hash: argon2idTestHash("a", 64, 1, 1), SubjectID: "a"}},
+			want: "prefix is required",
+		},
+		{
+			name: "bcrypt without prefix",
+			keys: []Key{{ID: "a", Hash: bcryptTestHash(t, "a"), SubjectID: "a"}},
+			want: "prefix is required",
+		},
+		{
+			name: "duplicate id",
+			keys: []Key{
+				{ID: "a", Hash: sha256TestHash("a"), SubjectID: "a"},
+				{ID: "a", Hash: sha256TestHash("b"), SubjectID: "b"},
+			},
+			want: "duplicate id",
+		},
+		{
+			name: "missing subject",
+			keys: []Key{{ID: "a", Hash: sha256TestHash("a")}},
+			want: "required",
+		},
+		{
+			name: "invalid hash",
+			keys: []Key{{ID: "a", Prefix: "a", Hash: "$argon2id$v=19$m=1048576,t=1,p=1$YWJjZGVmZ2g$YWJjZGVmZ2g", SubjectID: "a"}},
+			want: "memory",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "keys.yaml")
+			writeKeys(t, path, tt.keys, time.Now())
+
+			_, err := NewServer(Config{KeysFile: path})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	mtime := time.Now().Add(-time.Hour)
+
+	writeKeys(t, path, []Key{
+		{ID: "old", Hash: sha256TestHash("old-key"), SubjectID: "old"},
+	}, mtime)
+
+	srv, err := NewServer(Config{KeysFile: path, ReloadInterval: time.Nanosecond})
+	if err != nil {
+		t.Fatalf("error creating server: %v", err)
+	}
+
+	if resp := validate(t, srv, "old-key", ""); resp.GetResult() != authentication.ValidateCredentialResponse_RESULT_VALID {
+		t.Fatalf("got result %s for old key before reloading, want valid", resp.GetResult())
+	}
+
+	mtime = mtime.Add(time.Minute)
+	writeKeys(t, path, []Key{
+		{ID: "new", Hash: sha256TestHash("new-key"), SubjectID: "new"},
+	}, mtime)
+
+	if resp := validate(t, srv, "new-key", ""); resp.GetResult() != authentication.ValidateCredentialResponse_RESULT_VALID {
+		t.Errorf("got result %s for new key after reloading, want valid", resp.GetResult())
+	}
+
+	if resp := validate(t, srv, "old-key", ""); resp.GetResult() != authentication.ValidateCredentialResponse_RESULT_INVALID {
+		t.Errorf("got result %s for old key after reloading, want invalid", resp.GetResult())
+	}
+
+	// Invalid keys are not loaded, and the previously loaded keys remain in use.
+	mtime = mtime.Add(time.Minute)
+	writeKeys(t, path, []Key{
+		{ID: "unprefixed", Hash: argon2idTestHash("unprefixed-key", 64, 1, 1), SubjectID: "unprefixed"},
+	}, mtime)
+
+	if resp := validate(t, srv, "new-key", ""); resp.GetResult() != authentication.ValidateCredentialResponse_RESULT_VALID {
+		t.Errorf("got result %s for new key after writing invalid keys, want valid", resp.GetResult())
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("error removing keys file: %v", err)
+	}
+
+	if resp := validate(t, srv, "new-key", ""); resp.GetResult() != authentication.ValidateCredentialResponse_RESULT_VALID {
+		t.Errorf("got result %s for new key after removing keys file, want valid", resp.GetResult())
+	}
+}
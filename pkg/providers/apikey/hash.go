@@ -0,0 +1,211 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Parameters used by HashKey.
+const (
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024
+	argon2Threads = 4
+	argon2KeyLen  = 32
+	saltLen       = 16
+)
+
+// Limits on the parameters of stored hashes, which bound the cost of checking a key against a
+// hash. Argon2id memory is in KiB, so at most 256 MiB is used for each check. The lower limits are
+// the minimums defined by RFC 9106.
+const (
+	maxArgon2Time    = 16
+	maxArgon2Memory  = 256 * 1024
+	minArgon2SaltLen = 8
+	minArgon2KeyLen  = 4
+	maxBcryptCost    = 14
+)
+
+var errUnknownHashFormat = errors.New("unknown hash format")
+
+// verifier checks whether a key matches a stored hash.
+type verifier interface {
+	verify(key string) bool
+	// expensive reports whether checking a key is deliberately slow, as for password hashes.
+	expensive() bool
+}
+
+// parseHash parses a stored key hash. Supported formats are:
+//
+//   - argon2id in PHC string format: $argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<hash>
+//   - bcrypt: $2a$, $2b$, or $2y$
+//   - salted SHA-256: $sha256$<salt>$<hash>, where hash is SHA-256(salt || key)
+//
+// Salts and hashes in argon2id and SHA-256 formats are unpadded standard base64.
+func parseHash(s string) (verifier, error) {
+	switch {
+	case strings.HasPrefix(s, "$argon2id$"):
+		return parseArgon2id(s)
+	case strings.HasPrefix(s, "$2a$"), strings.HasPrefix(s, "$2b$"), strings.HasPrefix(s, "$2y$"):
+		cost, err := bcrypt.Cost([]byte(s))
+		if err != nil {
+			return nil, err
+		}
+
+		if cost > maxBcryptCost {
+			return nil, fmt.Errorf("bcrypt cost must be at most %d", maxBcryptCost)
+		}
+
+		return bcryptHash(s), nil
+	case strings.HasPrefix(s, "$sha256$"):
+		return parseSHA256(s)
+	default:
+		return nil, errUnknownHashFormat
+	}
+}
+
+type argon2idHash struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+	salt    []byte
+	hash    []byte
+}
+
+func parseArgon2id(s string) (*argon2idHash, error) {
+	parts := strings.Split(s, "$")
+	if len(parts) != 6 {
+		return nil, errors.New("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, errors.New("unsupported argon2id version")
+	}
+
+	out := &argon2idHash{}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &out.memory, &out.time, &out.threads); err != nil {
+		return nil, fmt.Errorf("invalid argon2id parameters: %w", err)
+	}
+
+	if fmt.Sprintf("m=%d,t=%d,p=%d", out.memory, out.time, out.threads) != parts[3] {
+		return nil, errors.New("invalid argon2id parameters")
+	}
+
+	switch {
+	case out.time < 1 || out.time > maxArgon2Time:
+		return nil, fmt.Errorf("argon2id time must be between 1 and %d", maxArgon2Time)
+	case out.threads < 1:
+		return nil, errors.New("argon2id parallelism must be at least 1")
+	case out.memory < 8*uint32(out.threads) || out.memory > maxArgon2Memory:
+		return nil, fmt.Errorf("argon2id memory must be between 8 KiB per thread and %d KiB", maxArgon2Memory)
+	}
+
+	var err error
+
+	if out.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+
+	if out.hash, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return nil, fmt.Errorf("invalid argon2id hash: %w", err)
+	}
+
+	if len(out.salt) < minArgon2SaltLen {
+		return nil, fmt.Errorf("argon2id salt must be at least %d bytes", minArgon2SaltLen)
+	}
+
+	if len(out.hash) < minArgon2KeyLen {
+		return nil, fmt.Errorf("argon2id hash must be at least %d bytes", minArgon2KeyLen)
+	}
+
+	return out, nil
+}
+
+func (h *argon2idHash) verify(key string) bool {
+	sum := argon2.IDKey([]byte(key), h.salt, h.time, h.memory, h.threads, uint32(len(h.hash)))
+
+	return subtle.ConstantTimeCompare(sum, h.hash) == 1
+}
+
+func (h *argon2idHash) expensive() bool {
+	return true
+}
+
+type bcryptHash string
+
+func (h bcryptHash) verify(key string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(h), []byte(key)) == nil
+}
+
+func (h bcryptHash) expensive() bool {
+	return true
+}
+
+type sha256Hash struct {
+	salt []byte
+	hash []byte
+}
+
+func parseSHA256(s string) (*sha256Hash, error) {
+	parts := strings.Split(s, "$")
+	if len(parts) != 4 {
+		return nil, errors.New("invalid sha256 hash")
+	}
+
+	out := &sha256Hash{}
+
+	var err error
+
+	if out.salt, err = base64.RawStdEncoding.DecodeString(parts[2]); err != nil {
+		return nil, fmt.Errorf("invalid sha256 salt: %w", err)
+	}
+
+	if out.hash, err = base64.RawStdEncoding.DecodeString(parts[3]); err != nil {
+		return nil, fmt.Errorf("invalid sha256 hash: %w", err)
+	}
+
+	if len(out.salt) == 0 || len(out.hash) != sha256.Size {
+		return nil, errors.New("invalid sha256 hash")
+	}
+
+	return out, nil
+}
+
+func (h *sha256Hash) verify(key string) bool {
+	sum := sha256.New()
+	sum.Write(h.salt)
+	sum.Write([]byte(key))
+
+	return subtle.ConstantTimeCompare(sum.Sum(nil), h.hash) == 1
+}
+
+func (h *sha256Hash) expensive() bool {
+	return false
+}
+
+// HashKey returns an argon2id hash of the given key in the format accepted in key files.
+func HashKey(key string) (string, error) {
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	hash := argon2.IDKey([]byte(key), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+
+	out := fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(hash),
+	)
+
+	return out, nil
+}
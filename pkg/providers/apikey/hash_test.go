@@ -0,0 +1,113 @@
+package apikey
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testSalt = []byte("0123456789abcdef")
+
+// argon2idTestHash returns an argon2id hash of key with the given parameters.
+func argon2idTestHash(key string, memory, time uint32, threads uint8) string {
+	hash := argon2.IDKey([]byte(key), testSalt, time, memory, threads, 32)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, memory, time, threads,
+		base64.RawStdEncoding.EncodeToString(testSalt), base64.RawStdEncoding.EncodeToString(hash))
+}
+
+func bcryptTestHash(t *testing.T, key string) string {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(key), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("error hashing key: %v", err)
+	}
+
+	return string(hash)
+}
+
+func sha256TestHash(key string) string {
+	sum := sha256.Sum256(append(append([]byte{}, testSalt...), key...))
+
+	return "$sha256$" + base64.RawStdEncoding.EncodeToString(testSalt) + "$" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+func TestParseHash(t *testing.T) {
+	bcryptHash := bcryptTestHash(t, "secret")
+	salt := base64.RawStdEncoding.EncodeToString(testSalt)
+	sum := base64.RawStdEncoding.EncodeToString(make([]byte, 32))
+
+	tests := []struct {
+		name          string
+		hash          string
+		wantErr       bool
+		wantExpensive bool
+	}{
+		{name: "argon2id", hash: argon2idTestHash("secret", 64, 1, 1), wantExpensive: true},
+		{name: "bcrypt", hash: bcryptHash, wantExpensive: true},
+		{name: "sha256", hash: sha256TestHash("secret")},
+		{name: "unknown format", hash: "$md5$abc", wantErr: true},
+		{name: "argon2id version", hash: "$argon2id$v=16$m=64,t=1,p=1$" + salt + "$" + sum, wantErr: true},
+		{name: "argon2id padded parameters", hash: "$argon2id$v=19$m=064,t=1,p=1$" + salt + "$" + sum, wantErr: true},
+		{name: "argon2id trailing parameters", hash: "$argon2id$v=19$m=64,t=1,p=1,x=1$" + salt + "$" + sum, wantErr: true},
+		{name: "argon2id zero time", hash: "$argon2id$v=19$m=64,t=0,p=1$" + salt + "$" + sum, wantErr: true},
+		{name: "argon2id excessive time", hash: "$argon2id$v=19$m=64,t=17,p=1$" + salt + "$" + sum, wantErr: true},
+		{name: "argon2id zero threads", hash: "$argon2id$v=19$m=64,t=1,p=0$" + salt + "$" + sum, wantErr: true},
+		{name: "argon2id too little memory", hash: "$argon2id$v=19$m=15,t=1,p=2$" + salt + "$" + sum, wantErr: true},
+		{name: "argon2id excessive memory", hash: "$argon2id$v=19$m=262145,t=1,p=1$" + salt + "$" + sum, wantErr: true},
+		{name: "argon2id short salt", hash: "$argon2id$v=19$m=64,t=1,p=1$YWJj$" + sum, wantErr: true},
+		{name: "argon2id short hash", hash: "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$YWJj", wantErr: true},
+		{name: "argon2id missing field", hash: "$argon2id$v=19$m=64,t=1,p=1$" + salt, wantErr: true},
+		{name: "bcrypt excessive cost", hash: strings.Replace(bcryptHash, "$04$", "$15$", 1), wantErr: true},
+		{name: "bcrypt truncated", hash: bcryptHash[:20], wantErr: true},
+		{name: "sha256 short hash", hash: "$sha256$" + salt + "$YWJj", wantErr: true},
+		{name: "sha256 empty salt", hash: "$sha256$$" + sum, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := parseHash(tt.hash)
+			if gotErr := err != nil; gotErr != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if v.expensive() != tt.wantExpensive {
+				t.Errorf("got expensive %t, want %t", v.expensive(), tt.wantExpensive)
+			}
+
+			if !v.verify("secret") {
+				t.Error("matching key not verified")
+			}
+
+			if v.verify("secreT") {
+				t.Error("other key verified")
+			}
+		})
+	}
+}
+
+func TestHashKey(t *testing.T) {
+	hash, err := HashKey("secret")
+	if err != nil {
+		t.Fatalf("error hashing key: %v", err)
+	}
+
+	v, err := parseHash(hash)
+	if err != nil {
+		t.Fatalf("error parsing hash %s: %v", hash, err)
+	}
+
+	if !v.verify("secret") || v.verify("other") {
+		t.Errorf("hash %s does not verify only the hashed key", hash)
+	}
+}
@@ -0,0 +1,147 @@
+// Package auditlog provides an Audit service implementation which writes each recorded event as a
+// line of JSON, either to stdout for collection by a log aggregation system or to a file:
+//
+//	path: /var/log/iam-runtime/audit.log
+//
+// Events are written in the protobuf JSON encoding of AuditEvent. Events written to a file are
+// synced to disk before they are reported as recorded.
+package auditlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/audit"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Config represents the configuration for an Audit server which writes events to a file.
+type Config struct {
+	// Path is the path of the file events are appended to. It is created if it does not exist.
+	Path string `yaml:"path"`
+}
+
+// Server is an Audit server which writes events as lines of JSON.
+type Server struct {
+	audit.UnimplementedAuditServer
+
+	mu    sync.Mutex
+	w     io.Writer
+	sync  func() error
+	close func() error
+	now   func() time.Time
+}
+
+// NewServer creates a new Server which writes events to w.
+func NewServer(w io.Writer) *Server {
+	return &Server{
+		w:     w,
+		sync:  func() error { return nil },
+		close: func() error { return nil },
+		now:   time.Now,
+	}
+}
+
+// NewFileServer creates a new Server which appends events to the file configured in cfg. The file
+// is closed by Close.
+func NewFileServer(cfg Config) (*Server, error) {
+	if cfg.Path == "" {
+		return nil, errors.New("path is required")
+	}
+
+	f, err := os.OpenFile(cfg.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening audit log: %w", err)
+	}
+
+	out := NewServer(f)
+	out.sync = f.Sync
+	out.close = f.Close
+
+	return out, nil
+}
+
+// Close closes the file events are written to, if the server was created by NewFileServer.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.close()
+}
+
+// RecordEvent writes the given event.
+func (s *Server) RecordEvent(ctx context.Context, req *audit.RecordEventRequest) (*audit.RecordEventResponse, error) {
+	if err := s.record(req.GetEvent()); err != nil {
+		return nil, err
+	}
+
+	return &audit.RecordEventResponse{}, nil
+}
+
+// RecordEvents writes each event sent on the stream, in order.
+func (s *Server) RecordEvents(stream audit.Audit_RecordEventsServer) error {
+	var count uint64
+
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			out := &audit.RecordEventsResponse{
+				RecordedCount: count,
+			}
+
+			return stream.SendAndClose(out)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := s.record(req.GetEvent()); err != nil {
+			return err
+		}
+
+		count++
+	}
+}
+
+// record validates the given event and writes it, setting its time if it is not set.
+func (s *Server) record(event *audit.AuditEvent) error {
+	if event.GetAction() == "" || event.GetOutcome() == audit.AuditEvent_OUTCOME_UNSPECIFIED {
+		return status.Error(codes.InvalidArgument, "action and outcome are required")
+	}
+
+	if event.Time == nil {
+		event.Time = timestamppb.New(s.now())
+	}
+
+	b, err := protojson.Marshal(event)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "error encoding event")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.w.Write(append(b, '\n')); err != nil {
+		log.Printf("error writing audit event: %v", err)
+
+		return status.Error(codes.Unavailable, "error writing audit event")
+	}
+
+	if err := s.sync(); err != nil {
+		log.Printf("error syncing audit log: %v", err)
+
+		return status.Error(codes.Unavailable, "error writing audit event")
+	}
+
+	return nil
+}
@@ -0,0 +1,199 @@
+package auditlog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/audit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// testStream is an Audit_RecordEventsServer which receives the given requests in order.
+type testStream struct {
+	grpc.ServerStream
+
+	reqs []*audit.RecordEventRequest
+	resp *audit.RecordEventsResponse
+}
+
+func (s *testStream) Recv() (*audit.RecordEventRequest, error) {
+	if len(s.reqs) == 0 {
+		return nil, io.EOF
+	}
+
+	req := s.reqs[0]
+	s.reqs = s.reqs[1:]
+
+	return req, nil
+}
+
+func (s *testStream) SendAndClose(resp *audit.RecordEventsResponse) error {
+	s.resp = resp
+
+	return nil
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func event(action string) *audit.AuditEvent {
+	return &audit.AuditEvent{
+		SubjectId: "user-alice",
+		Action:    action,
+		Outcome:   audit.AuditEvent_OUTCOME_SUCCEEDED,
+	}
+}
+
+// readEvents decodes the events written as lines of JSON.
+func readEvents(t *testing.T, out string) []*audit.AuditEvent {
+	t.Helper()
+
+	var events []*audit.AuditEvent
+
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		event := &audit.AuditEvent{}
+		if err := protojson.Unmarshal([]byte(line), event); err != nil {
+			t.Fatalf("error decoding event %q: %v", line, err)
+		}
+
+		events = append(events, event)
+	}
+
+	return events
+}
+
+func TestRecordEvent(t *testing.T) {
+	var buf bytes.Buffer
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	srv := NewServer(&buf)
+	srv.now = func() time.Time { return now }
+
+	tests := []struct {
+		name     string
+		event    *audit.AuditEvent
+		wantCode codes.Code
+	}{
+		{"valid", event("delete_widget"), codes.OK},
+		{"missing action", event(""), codes.InvalidArgument},
+		{"missing outcome", &audit.AuditEvent{Action: "delete_widget"}, codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := srv.RecordEvent(context.Background(), &audit.RecordEventRequest{Event: tt.event})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("got code %s, want %s: %v", code, tt.wantCode, err)
+			}
+		})
+	}
+
+	events := readEvents(t, buf.String())
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+
+	if events[0].GetAction() != "delete_widget" || !events[0].GetTime().AsTime().Equal(now) {
+		t.Errorf("got event %v, want delete_widget at %s", events[0], now)
+	}
+}
+
+func TestRecordEventUnavailable(t *testing.T) {
+	srv := NewServer(failingWriter{})
+
+	_, err := srv.RecordEvent(context.Background(), &audit.RecordEventRequest{Event: event("delete_widget")})
+	if code := status.Code(err); code != codes.Unavailable {
+		t.Errorf("got code %s, want %s: %v", code, codes.Unavailable, err)
+	}
+}
+
+func TestRecordEvents(t *testing.T) {
+	var buf bytes.Buffer
+
+	srv := NewServer(&buf)
+
+	stream := &testStream{
+		reqs: []*audit.RecordEventRequest{
+			{Event: event("create_widget")},
+			{Event: event("delete_widget")},
+		},
+	}
+
+	if err := srv.RecordEvents(stream); err != nil {
+		t.Fatalf("error recording events: %v", err)
+	}
+
+	if got := stream.resp.GetRecordedCount(); got != 2 {
+		t.Errorf("got recorded count %d, want 2", got)
+	}
+
+	if events := readEvents(t, buf.String()); len(events) != 2 || events[1].GetAction() != "delete_widget" {
+		t.Errorf("got events %v, want create_widget and delete_widget", events)
+	}
+
+	// Events before an invalid event are recorded, and the stream fails.
+	stream = &testStream{
+		reqs: []*audit.RecordEventRequest{
+			{Event: event("update_widget")},
+			{Event: event("")},
+		},
+	}
+
+	if err := srv.RecordEvents(stream); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("got error %v for invalid event, want %s", err, codes.InvalidArgument)
+	}
+
+	if events := readEvents(t, buf.String()); len(events) != 3 {
+		t.Errorf("got %d events, want 3", len(events))
+	}
+}
+
+func TestNewFileServer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	if err := os.WriteFile(path, []byte("{\"action\":\"existing\"}\n"), 0o600); err != nil {
+		t.Fatalf("error writing audit log: %v", err)
+	}
+
+	srv, err := NewFileServer(Config{Path: path})
+	if err != nil {
+		t.Fatalf("error creating server: %v", err)
+	}
+
+	if _, err := srv.RecordEvent(context.Background(), &audit.RecordEventRequest{Event: event("delete_widget")}); err != nil {
+		t.Fatalf("error recording event: %v", err)
+	}
+
+	if err := srv.Close(); err != nil {
+		t.Fatalf("error closing server: %v", err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("error reading audit log: %v", err)
+	}
+
+	// Events are appended to the existing file.
+	if events := readEvents(t, string(b)); len(events) != 2 || events[1].GetAction() != "delete_widget" {
+		t.Errorf("got events %v, want existing and delete_widget", events)
+	}
+
+	if _, err := NewFileServer(Config{}); err == nil {
+		t.Error("got no error without path")
+	}
+}
@@ -0,0 +1,186 @@
+// Package celrules provides an Authorization service implementation which makes access decisions by
+// evaluating a CEL (https://cel.dev) expression for each action, for services which need more than
+// fixed rules but less than a policy engine.
+//
+// Rules map each action to an expression which must evaluate to a bool:
+//
+//	rules:
+//	  read_widget: subject.claims.groups.exists(g, g == "admins" || g == "readers")
+//	  delete_widget: |
+//	    subject.claims.groups.exists(g, g == "admins") &&
+//	      resource_id.startsWith(subject.claims.tenant + "-")
+//
+// Expressions may refer to the same variables as conditions of the policy provider:
+//
+//   - subject: the Subject the credential identifies, with fields subject_id and claims, where
+//     claims are those returned by the Authentication service
+//   - action: the action being checked
+//   - resource_id: the ID of the resource the action is on
+//   - now: the time the request was received, as a timestamp
+//   - metadata: the gRPC metadata of the request, as a map from each lowercase key to its values
+//     joined with commas
+//
+// Expressions are compiled and type checked when the server is created, so that errors such as
+// referring to an unknown variable or to an unknown field of subject are reported at startup.
+// Claims are not typed, so referring to a claim the subject does not have is only detected when the
+// expression is evaluated. Actions without a rule are denied, as are actions whose expression
+// cannot be evaluated.
+package celrules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
+	authorization "github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization/v2"
+	"github.com/metal-toolbox/iam-runtime/pkg/providers/internal/celenv"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultCostLimit is the maximum cost of evaluating a single expression if no limit is configured.
+const DefaultCostLimit = 1_000_000
+
+// Config represents the configuration for a CEL rules Authorization server.
+type Config struct {
+	// Rules maps each action to the expression which decides whether it is allowed.
+	Rules map[string]string `yaml:"rules"`
+	// CostLimit is the maximum cost of evaluating a single expression.
+	CostLimit uint64 `yaml:"cost_limit"`
+}
+
+// Server is an Authorization server which makes access decisions by evaluating CEL expressions.
+// Credentials are validated using an Authentication server.
+type Server struct {
+	authorization.UnimplementedAuthorizationServer
+
+	authn    authentication.AuthenticationServer
+	programs map[string]cel.Program
+}
+
+// NewServer creates a new Server using the given config, validating credentials with authn. It
+// returns an error describing every rule which fails to compile.
+func NewServer(cfg Config, authn authentication.AuthenticationServer) (*Server, error) {
+	if len(cfg.Rules) == 0 {
+		return nil, errors.New("rules is required")
+	}
+
+	costLimit := cfg.CostLimit
+	if costLimit == 0 {
+		costLimit = DefaultCostLimit
+	}
+
+	env, err := celenv.New()
+	if err != nil {
+		return nil, fmt.Errorf("error creating CEL environment: %w", err)
+	}
+
+	actions := make([]string, 0, len(cfg.Rules))
+	for action := range cfg.Rules {
+		actions = append(actions, action)
+	}
+
+	sort.Strings(actions)
+
+	out := &Server{
+		authn:    authn,
+		programs: make(map[string]cel.Program, len(cfg.Rules)),
+	}
+
+	var errs []error
+
+	for _, action := range actions {
+		program, err := celenv.Compile(env, cfg.Rules[action], costLimit)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %s: %w", action, err))
+
+			continue
+		}
+
+		out.programs[action] = program
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// RPCs returns the names of the Authorization RPCs the server implements.
+func (s *Server) RPCs() []string {
+	return []string{"CheckAccess"}
+}
+
+// CheckAccess allows the request if the rule for every requested action evaluates to true for the
+// subject identified by the given credential.
+func (s *Server) CheckAccess(ctx context.Context, req *authorization.CheckAccessRequest) (*authorization.CheckAccessResponse, error) {
+	if len(req.GetActions()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "actions are required")
+	}
+
+	now := time.Now()
+
+	validateReq := &authentication.ValidateCredentialRequest{
+		Credential: req.GetCredential(),
+	}
+
+	validateResp, err := s.authn.ValidateCredential(ctx, validateReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if validateResp.GetResult() != authentication.ValidateCredentialResponse_RESULT_VALID {
+		return nil, status.Error(codes.InvalidArgument, "invalid credential")
+	}
+
+	request := celenv.NewRequest(ctx, validateResp.GetSubject(), now)
+
+	result := authorization.CheckAccessResponse_RESULT_ALLOWED
+
+	for _, action := range req.GetActions() {
+		vars := request.Vars(action.GetAction(), action.GetResourceId())
+
+		allowed, err := s.evaluate(ctx, action.GetAction(), vars)
+		if err != nil {
+			return nil, err
+		}
+
+		if !allowed {
+			result = authorization.CheckAccessResponse_RESULT_DENIED
+
+			break
+		}
+	}
+
+	out := &authorization.CheckAccessResponse{
+		Result: result,
+	}
+
+	return out, nil
+}
+
+// evaluate reports whether the rule for the given action evaluates to true.
+func (s *Server) evaluate(ctx context.Context, action string, vars map[string]any) (bool, error) {
+	program, ok := s.programs[action]
+	if !ok {
+		return false, nil
+	}
+
+	val, _, err := program.ContextEval(ctx, vars)
+	if ctx.Err() != nil {
+		return false, status.FromContextError(ctx.Err()).Err()
+	}
+
+	if err != nil {
+		return false, nil
+	}
+
+	allowed, ok := val.Value().(bool)
+
+	return ok && allowed, nil
+}
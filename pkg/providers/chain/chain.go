@@ -0,0 +1,338 @@
+// Package chain provides an Authentication service implementation which validates credentials using
+// a sequence of other Authentication servers, such that a runtime can accept several kinds of
+// credential at once.
+package chain
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Provider is an Authentication server in a chain, along with the credentials routed to it.
+type Provider struct {
+	// Name identifies the provider in logs.
+	Name string
+	// Server is the Authentication server credentials are validated with.
+	Server authentication.AuthenticationServer
+	// Prefixes is the set of prefixes of credentials routed to the provider. If empty, credentials
+	// are routed to the provider regardless of prefix.
+	Prefixes []string
+	// CredentialTypes is the set of types of credential routed to the provider. If empty, the
+	// types the server describes itself as accepting are used, and if it does not describe them,
+	// credentials are routed to the provider regardless of type.
+	CredentialTypes []authentication.CredentialType
+}
+
+// rpcLister is implemented by servers which do not implement every Authentication RPC.
+type rpcLister interface {
+	RPCs() []string
+}
+
+// credentialTypeLister is implemented by servers to describe the types of credential they accept.
+type credentialTypeLister interface {
+	CredentialTypes() []authentication.CredentialType
+}
+
+// Server is an Authentication server which routes each request to the providers in a chain.
+type Server struct {
+	authentication.UnimplementedAuthenticationServer
+
+	providers []Provider
+}
+
+// NewServer creates a new Server which tries the given providers in order.
+func NewServer(providers ...Provider) (*Server, error) {
+	if len(providers) == 0 {
+		return nil, errors.New("at least one provider is required")
+	}
+
+	for i, p := range providers {
+		if p.Server == nil {
+			return nil, fmt.Errorf("provider %d (%s): server is required", i, p.Name)
+		}
+	}
+
+	out := &Server{
+		providers: providers,
+	}
+
+	return out, nil
+}
+
+// RPCs returns the names of the Authentication RPCs the server implements.
+func (s *Server) RPCs() []string {
+	out := []string{"ValidateCredential"}
+
+	for _, rpc := range []string{"GetTrustBundle", "WatchTrustBundle", "GetSubject"} {
+		for _, p := range s.providers {
+			if supportsRPC(p.Server, rpc) {
+				out = append(out, rpc)
+
+				break
+			}
+		}
+	}
+
+	return out
+}
+
+// CredentialTypes returns the types of credential accepted by any provider in the chain.
+func (s *Server) CredentialTypes() []authentication.CredentialType {
+	var out []authentication.CredentialType
+
+	seen := make(map[authentication.CredentialType]bool)
+
+	for _, p := range s.providers {
+		for _, credType := range p.credentialTypes() {
+			if !seen[credType] {
+				seen[credType] = true
+				out = append(out, credType)
+			}
+		}
+	}
+
+	return out
+}
+
+// ValidateCredential validates the given credential with each provider it is routed to in order,
+// returning the first valid response. If no provider finds the credential valid, the most specific
+// reason given by any provider is returned. Errors from providers are only returned if no provider
+// gave a result, so that a failing provider does not prevent others from rejecting a credential.
+func (s *Server) ValidateCredential(ctx context.Context, req *authentication.ValidateCredentialRequest) (*authentication.ValidateCredentialResponse, error) {
+	credType := req.GetCredentialType()
+	if credType == authentication.CredentialType_CREDENTIAL_TYPE_UNSPECIFIED {
+		credType = DetectCredentialType(req.GetCredential())
+	} else if !s.supports(credType) {
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported credential type %s", credType)
+	}
+
+	var (
+		reason   authentication.ValidateCredentialResponse_InvalidReason
+		routed   bool
+		answered bool
+		lastErr  error
+	)
+
+	for _, p := range s.providers {
+		if !p.routes(req.GetCredential(), credType) {
+			continue
+		}
+
+		routed = true
+
+		resp, err := p.Server.ValidateCredential(ctx, req)
+		if err != nil {
+			log.Printf("error validating credential with provider %s: %v", p.Name, err)
+
+			lastErr = err
+
+			continue
+		}
+
+		if resp.GetResult() == authentication.ValidateCredentialResponse_RESULT_VALID {
+			return resp, nil
+		}
+
+		answered = true
+
+		if specificity(resp.GetInvalidReason()) > specificity(reason) {
+			reason = resp.GetInvalidReason()
+		}
+	}
+
+	// If every provider the credential was routed to failed, one of them might have found it valid.
+	if !answered && lastErr != nil {
+		return nil, lastErr
+	}
+
+	if !routed {
+		reason = authentication.ValidateCredentialResponse_INVALID_REASON_UNKNOWN_CREDENTIAL
+	}
+
+	out := &authentication.ValidateCredentialResponse{
+		Result:        authentication.ValidateCredentialResponse_RESULT_INVALID,
+		InvalidReason: reason,
+	}
+
+	return out, nil
+}
+
+// GetSubject returns the subject from the first provider in the chain which knows about it.
+func (s *Server) GetSubject(ctx context.Context, req *authentication.GetSubjectRequest) (*authentication.GetSubjectResponse, error) {
+	if req.GetSubjectId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "subject_id is required")
+	}
+
+	for _, p := range s.providers {
+		if !supportsRPC(p.Server, "GetSubject") {
+			continue
+		}
+
+		resp, err := p.Server.GetSubject(ctx, req)
+
+		switch status.Code(err) {
+		case codes.OK:
+			return resp, nil
+		case codes.NotFound, codes.Unimplemented:
+			continue
+		default:
+			return nil, err
+		}
+	}
+
+	return nil, status.Errorf(codes.NotFound, "subject %q not found", req.GetSubjectId())
+}
+
+// GetTrustBundle returns the trust bundle of the first provider in the chain which serves one.
+func (s *Server) GetTrustBundle(ctx context.Context, req *authentication.GetTrustBundleRequest) (*authentication.GetTrustBundleResponse, error) {
+	for _, p := range s.providers {
+		if supportsRPC(p.Server, "GetTrustBundle") {
+			return p.Server.GetTrustBundle(ctx, req)
+		}
+	}
+
+	return nil, status.Error(codes.Unimplemented, "method GetTrustBundle not implemented")
+}
+
+// WatchTrustBundle streams the trust bundle of the first provider in the chain which serves one.
+func (s *Server) WatchTrustBundle(req *authentication.WatchTrustBundleRequest, stream authentication.Authentication_WatchTrustBundleServer) error {
+	for _, p := range s.providers {
+		if supportsRPC(p.Server, "WatchTrustBundle") {
+			return p.Server.WatchTrustBundle(req, stream)
+		}
+	}
+
+	return status.Error(codes.Unimplemented, "method WatchTrustBundle not implemented")
+}
+
+// supports reports whether any provider in the chain accepts credentials of the given type.
+func (s *Server) supports(credType authentication.CredentialType) bool {
+	for _, p := range s.providers {
+		credTypes := p.credentialTypes()
+		if len(credTypes) == 0 {
+			return true
+		}
+
+		for _, t := range credTypes {
+			if t == credType {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// credentialTypes returns the types of credential routed to the provider, or nil if credentials
+// are routed to it regardless of type.
+func (p Provider) credentialTypes() []authentication.CredentialType {
+	if len(p.CredentialTypes) > 0 {
+		return p.CredentialTypes
+	}
+
+	if lister, ok := p.Server.(credentialTypeLister); ok {
+		return lister.CredentialTypes()
+	}
+
+	return nil
+}
+
+// routes reports whether the given credential of the given type is routed to the provider.
+func (p Provider) routes(credential string, credType authentication.CredentialType) bool {
+	if len(p.Prefixes) > 0 {
+		var ok bool
+
+		for _, prefix := range p.Prefixes {
+			if strings.HasPrefix(credential, prefix) {
+				ok = true
+
+				break
+			}
+		}
+
+		if !ok {
+			return false
+		}
+	}
+
+	credTypes := p.credentialTypes()
+	if len(credTypes) == 0 {
+		return true
+	}
+
+	for _, t := range credTypes {
+		// A credential of unknown type is not a JWT, but may be of any other type.
+		if t == credType || (credType == authentication.CredentialType_CREDENTIAL_TYPE_UNSPECIFIED && t != authentication.CredentialType_CREDENTIAL_TYPE_JWT) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DetectCredentialType returns CREDENTIAL_TYPE_JWT if the given credential is a JWT in compact
+// serialization, and CREDENTIAL_TYPE_UNSPECIFIED otherwise. Other types of credential cannot be
+// reliably told apart by their format.
+func DetectCredentialType(credential string) authentication.CredentialType {
+	header, _, ok := strings.Cut(credential, ".")
+	if !ok || strings.Count(credential, ".") != 2 {
+		return authentication.CredentialType_CREDENTIAL_TYPE_UNSPECIFIED
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(header)
+	if err != nil {
+		return authentication.CredentialType_CREDENTIAL_TYPE_UNSPECIFIED
+	}
+
+	var fields struct {
+		Alg string `json:"alg"`
+	}
+
+	if err := json.Unmarshal(b, &fields); err != nil || fields.Alg == "" {
+		return authentication.CredentialType_CREDENTIAL_TYPE_UNSPECIFIED
+	}
+
+	return authentication.CredentialType_CREDENTIAL_TYPE_JWT
+}
+
+// specificity ranks invalid reasons by how much they say about a credential. Reasons which mean a
+// provider does not recognize a credential rank below reasons given by the provider which issued
+// it, such that a credential which is expired according to one provider is not reported as
+// unknown because another provider does not recognize it.
+func specificity(reason authentication.ValidateCredentialResponse_InvalidReason) int {
+	switch reason {
+	case authentication.ValidateCredentialResponse_INVALID_REASON_UNSPECIFIED:
+		return 0
+	case authentication.ValidateCredentialResponse_INVALID_REASON_MALFORMED:
+		return 1
+	case authentication.ValidateCredentialResponse_INVALID_REASON_UNKNOWN_CREDENTIAL,
+		authentication.ValidateCredentialResponse_INVALID_REASON_UNKNOWN_ISSUER:
+		return 2
+	default:
+		return 3
+	}
+}
+
+func supportsRPC(srv authentication.AuthenticationServer, rpc string) bool {
+	lister, ok := srv.(rpcLister)
+	if !ok {
+		return true
+	}
+
+	for _, r := range lister.RPCs() {
+		if r == rpc {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,321 @@
+package chain
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	typeJWT         = authentication.CredentialType_CREDENTIAL_TYPE_JWT
+	typeAPIKey      = authentication.CredentialType_CREDENTIAL_TYPE_API_KEY
+	typeSession     = authentication.CredentialType_CREDENTIAL_TYPE_SESSION
+	typeUnspecified = authentication.CredentialType_CREDENTIAL_TYPE_UNSPECIFIED
+
+	reasonUnspecified = authentication.ValidateCredentialResponse_INVALID_REASON_UNSPECIFIED
+	reasonMalformed   = authentication.ValidateCredentialResponse_INVALID_REASON_MALFORMED
+	reasonUnknown     = authentication.ValidateCredentialResponse_INVALID_REASON_UNKNOWN_CREDENTIAL
+	reasonExpired     = authentication.ValidateCredentialResponse_INVALID_REASON_EXPIRED
+)
+
+// testJWT has the form of a JWT, but is not signed by anyone.
+var testJWT = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256"}`)) + ".e30.c2ln"
+
+// testServer accepts a single credential, giving reason for any other. If err is set, every
+// request fails with it.
+type testServer struct {
+	authentication.UnimplementedAuthenticationServer
+
+	credential string
+	reason     authentication.ValidateCredentialResponse_InvalidReason
+	err        error
+	calls      int
+}
+
+func (s *testServer) ValidateCredential(ctx context.Context, req *authentication.ValidateCredentialRequest) (*authentication.ValidateCredentialResponse, error) {
+	s.calls++
+
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	if req.GetCredential() == s.credential {
+		out := &authentication.ValidateCredentialResponse{
+			Result:  authentication.ValidateCredentialResponse_RESULT_VALID,
+			Subject: &authentication.Subject{SubjectId: s.credential},
+		}
+
+		return out, nil
+	}
+
+	out := &authentication.ValidateCredentialResponse{
+		Result:        authentication.ValidateCredentialResponse_RESULT_INVALID,
+		InvalidReason: s.reason,
+	}
+
+	return out, nil
+}
+
+// typedServer is a testServer which describes the types of credential it accepts.
+type typedServer struct {
+	*testServer
+
+	types []authentication.CredentialType
+}
+
+func (s typedServer) CredentialTypes() []authentication.CredentialType {
+	return s.types
+}
+
+func newChain(t *testing.T, providers ...Provider) *Server {
+	t.Helper()
+
+	srv, err := NewServer(providers...)
+	if err != nil {
+		t.Fatalf("error creating server: %v", err)
+	}
+
+	return srv
+}
+
+func validate(srv *Server, credential string, credType authentication.CredentialType) (*authentication.ValidateCredentialResponse, error) {
+	req := &authentication.ValidateCredentialRequest{
+		Credential:     credential,
+		CredentialType: credType,
+	}
+
+	return srv.ValidateCredential(context.Background(), req)
+}
+
+func TestRouting(t *testing.T) {
+	jwt := &testServer{credential: testJWT, reason: reasonMalformed}
+	apikey := &testServer{credential: "key-abc", reason: reasonUnknown}
+	session := &testServer{credential: "sess-abc", reason: reasonUnknown}
+
+	srv := newChain(t,
+		Provider{Name: "jwt", Server: typedServer{jwt, []authentication.CredentialType{typeJWT}}},
+		Provider{Name: "apikey", Server: apikey, Prefixes: []string{"key-"}, CredentialTypes: []authentication.CredentialType{typeAPIKey}},
+		Provider{Name: "session", Server: typedServer{session, []authentication.CredentialType{typeSession}}},
+	)
+
+	tests := []struct {
+		name       string
+		credential string
+		credType   authentication.CredentialType
+		wantValid  bool
+		wantCalled []*testServer
+		wantCode   codes.Code
+	}{
+		{
+			name:       "detected JWT",
+			credential: testJWT,
+			wantValid:  true,
+			wantCalled: []*testServer{jwt},
+		},
+		{
+			name:       "unknown type skips JWT provider",
+			credential: "key-abc",
+			wantValid:  true,
+			wantCalled: []*testServer{apikey},
+		},
+		{
+			name:       "unknown type without prefix",
+			credential: "sess-abc",
+			wantValid:  true,
+			wantCalled: []*testServer{session},
+		},
+		{
+			name:       "explicit type",
+			credential: "sess-abc",
+			credType:   typeSession,
+			wantValid:  true,
+			wantCalled: []*testServer{session},
+		},
+		{
+			name:       "explicit type not routed by prefix",
+			credential: "sess-abc",
+			credType:   typeAPIKey,
+			wantCalled: []*testServer{},
+		},
+		{
+			name:       "unsupported type",
+			credential: "abc",
+			credType:   authentication.CredentialType_CREDENTIAL_TYPE_BASIC,
+			wantCalled: []*testServer{},
+			wantCode:   codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, s := range []*testServer{jwt, apikey, session} {
+				s.calls = 0
+			}
+
+			resp, err := validate(srv, tt.credential, tt.credType)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("got code %s, want %s: %v", code, tt.wantCode, err)
+			}
+
+			if err == nil && (resp.GetResult() == authentication.ValidateCredentialResponse_RESULT_VALID) != tt.wantValid {
+				t.Errorf("got result %s, want valid %t", resp.GetResult(), tt.wantValid)
+			}
+
+			for _, s := range tt.wantCalled {
+				if s.calls != 1 {
+					t.Errorf("provider accepting %s called %d times, want once", s.credential, s.calls)
+				}
+			}
+
+			if total := jwt.calls + apikey.calls + session.calls; total != len(tt.wantCalled) {
+				t.Errorf("got %d calls to providers, want %d", total, len(tt.wantCalled))
+			}
+		})
+	}
+
+	// Credentials routed to no provider are unknown.
+	resp, err := validate(srv, "sess-abc", typeAPIKey)
+	if err != nil {
+		t.Fatalf("error validating credential: %v", err)
+	}
+
+	if resp.GetInvalidReason() != reasonUnknown {
+		t.Errorf("got reason %s for unrouted credential, want %s", resp.GetInvalidReason(), reasonUnknown)
+	}
+
+	want := []authentication.CredentialType{typeJWT, typeAPIKey, typeSession}
+
+	if got := srv.CredentialTypes(); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("got credential types %v, want %v", got, want)
+	}
+}
+
+func TestInvalidReasons(t *testing.T) {
+	tests := []struct {
+		name    string
+		reasons []authentication.ValidateCredentialResponse_InvalidReason
+		want    authentication.ValidateCredentialResponse_InvalidReason
+	}{
+		{"issuer reason over unknown", []authentication.ValidateCredentialResponse_InvalidReason{reasonUnknown, reasonExpired}, reasonExpired},
+		{"issuer reason first", []authentication.ValidateCredentialResponse_InvalidReason{reasonExpired, reasonUnknown}, reasonExpired},
+		{"unknown over malformed", []authentication.ValidateCredentialResponse_InvalidReason{reasonMalformed, reasonUnknown}, reasonUnknown},
+		{"malformed over unspecified", []authentication.ValidateCredentialResponse_InvalidReason{reasonUnspecified, reasonMalformed}, reasonMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var providers []Provider
+
+			for _, reason := range tt.reasons {
+				providers = append(providers, Provider{
+					Name:   reason.String(),
+					Server: &testServer{credential: "valid", reason: reason},
+				})
+			}
+
+			resp, err := validate(newChain(t, providers...), "invalid", typeUnspecified)
+			if err != nil {
+				t.Fatalf("error validating credential: %v", err)
+			}
+
+			if resp.GetResult() != authentication.ValidateCredentialResponse_RESULT_INVALID {
+				t.Fatalf("got result %s, want invalid", resp.GetResult())
+			}
+
+			if resp.GetInvalidReason() != tt.want {
+				t.Errorf("got reason %s, want %s", resp.GetInvalidReason(), tt.want)
+			}
+		})
+	}
+}
+
+func TestProviderErrors(t *testing.T) {
+	errUnavailable := status.Error(codes.Unavailable, "backend unavailable")
+
+	failing := func() *testServer {
+		return &testServer{err: errUnavailable}
+	}
+
+	tests := []struct {
+		name       string
+		servers    []*testServer
+		credential string
+		wantValid  bool
+		wantReason authentication.ValidateCredentialResponse_InvalidReason
+		wantCode   codes.Code
+	}{
+		{
+			name:       "another provider rejects",
+			servers:    []*testServer{failing(), {credential: "valid", reason: reasonExpired}},
+			credential: "invalid",
+			wantReason: reasonExpired,
+		},
+		{
+			name:       "another provider rejects first",
+			servers:    []*testServer{{credential: "valid", reason: reasonUnknown}, failing()},
+			credential: "invalid",
+			wantReason: reasonUnknown,
+		},
+		{
+			name:       "another provider accepts",
+			servers:    []*testServer{failing(), {credential: "valid"}},
+			credential: "valid",
+			wantValid:  true,
+		},
+		{
+			name:       "every provider fails",
+			servers:    []*testServer{failing(), failing()},
+			credential: "valid",
+			wantCode:   codes.Unavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var providers []Provider
+
+			for _, s := range tt.servers {
+				providers = append(providers, Provider{Server: s})
+			}
+
+			resp, err := validate(newChain(t, providers...), tt.credential, typeUnspecified)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("got code %s, want %s: %v", code, tt.wantCode, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if gotValid := resp.GetResult() == authentication.ValidateCredentialResponse_RESULT_VALID; gotValid != tt.wantValid {
+				t.Errorf("got result %s, want valid %t", resp.GetResult(), tt.wantValid)
+			}
+
+			if resp.GetInvalidReason() != tt.wantReason {
+				t.Errorf("got reason %s, want %s", resp.GetInvalidReason(), tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestDetectCredentialType(t *testing.T) {
+	tests := []struct {
+		credential string
+		want       authentication.CredentialType
+	}{
+		{testJWT, typeJWT},
+		{"key-abc", typeUnspecified},
+		{"a.b.c", typeUnspecified},
+		{base64.RawURLEncoding.EncodeToString([]byte(`{"typ":"JWT"}`)) + ".e30.c2ln", typeUnspecified},
+	}
+
+	for _, tt := range tests {
+		if got := DetectCredentialType(tt.credential); got != tt.want {
+			t.Errorf("DetectCredentialType(%q): got %s, want %s", tt.credential, got, tt.want)
+		}
+	}
+}
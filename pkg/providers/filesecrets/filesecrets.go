@@ -0,0 +1,222 @@
+// Package filesecrets provides a Secrets service implementation backed by files on disk, intended
+// for local development.
+//
+// Each secret is a regular file in a directory, named by the secret's name (for example, the
+// secret "database/password" is read from "<dir>/database/password"). Only the current contents
+// of each file are available, and the version of a secret is derived from a hash of its contents.
+//
+// Access is granted to workload identities by patterns of secret names, using the syntax of
+// path.Match, such that a single config may describe every workload:
+//
+//	dir: /etc/iam-runtime/secrets
+//	workload: billing-api
+//	access:
+//	  billing-api: ["database/*", "stripe/api-key"]
+//	  reports: ["database/readonly-password"]
+//
+// A runtime is deployed alongside a single workload, whose identity is configured, and serves only
+// the secrets that workload may access.
+package filesecrets
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/secrets"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultPollInterval is the interval at which watched secrets are checked for changes if no
+// interval is configured.
+const DefaultPollInterval = time.Second
+
+// Config represents the configuration for a file-backed Secrets server.
+type Config struct {
+	// Dir is the directory secrets are read from.
+	Dir string `yaml:"dir"`
+	// PollInterval is the interval at which watched secrets are checked for changes.
+	PollInterval time.Duration `yaml:"poll_interval"`
+	// Workload is the identity of the workload the runtime is deployed alongside.
+	Workload string `yaml:"workload"`
+	// Access maps each workload identity to the patterns of names of the secrets it may access.
+	// Workloads which are not listed may not access any secret.
+	Access map[string][]string `yaml:"access"`
+}
+
+// Server is a Secrets server which serves secrets from files on disk.
+type Server struct {
+	secrets.UnimplementedSecretsServer
+
+	dir          string
+	pollInterval time.Duration
+	patterns     []string
+}
+
+// NewServer creates a new Server using the given config.
+func NewServer(cfg Config) (*Server, error) {
+	if cfg.Dir == "" || cfg.Workload == "" {
+		return nil, errors.New("dir and workload are required")
+	}
+
+	for workload, patterns := range cfg.Access {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("workload %s: invalid pattern %q: %w", workload, pattern, err)
+			}
+		}
+	}
+
+	info, err := os.Stat(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "open", Path: cfg.Dir, Err: errors.New("not a directory")}
+	}
+
+	// The directory is resolved so that the files secrets resolve to can be checked against it.
+	dir, err := filepath.EvalSymlinks(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	pollInterval := cfg.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = DefaultPollInterval
+	}
+
+	out := &Server{
+		dir:          dir,
+		pollInterval: pollInterval,
+		patterns:     cfg.Access[cfg.Workload],
+	}
+
+	return out, nil
+}
+
+// GetSecret returns the current contents of the requested secret.
+func (s *Server) GetSecret(ctx context.Context, req *secrets.GetSecretRequest) (*secrets.GetSecretResponse, error) {
+	secret, err := s.readSecret(req.GetName())
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GetVersion() != "" && req.GetVersion() != secret.Version {
+		return nil, status.Errorf(codes.NotFound, "version %q of secret %q not found", req.GetVersion(), req.GetName())
+	}
+
+	out := &secrets.GetSecretResponse{
+		Secret: secret,
+	}
+
+	return out, nil
+}
+
+// WatchSecret sends the requested secret to the client each time its contents change. The
+// stream is terminated with NOT_FOUND if the secret is removed.
+func (s *Server) WatchSecret(req *secrets.WatchSecretRequest, stream secrets.Secrets_WatchSecretServer) error {
+	lastVersion := req.GetVersion()
+
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		secret, err := s.readSecret(req.GetName())
+		if err != nil {
+			return err
+		}
+
+		if secret.Version != lastVersion {
+			out := &secrets.WatchSecretResponse{
+				Secret: secret,
+			}
+
+			if err := stream.Send(out); err != nil {
+				return err
+			}
+
+			lastVersion = secret.Version
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// allowed reports whether the workload may access the secret with the given name.
+func (s *Server) allowed(name string) bool {
+	for _, pattern := range s.patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Server) readSecret(name string) (*secrets.Secret, error) {
+	if !fs.ValidPath(name) || name == "." {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid secret name %q", name)
+	}
+
+	if !s.allowed(name) {
+		return nil, status.Errorf(codes.PermissionDenied, "access to secret %q denied", name)
+	}
+
+	// Symbolic links are followed, such that secrets may be updated atomically by replacing a link
+	// as Kubernetes does, but not out of the directory.
+	file, err := filepath.EvalSymlinks(filepath.Join(s.dir, filepath.FromSlash(name)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, status.Errorf(codes.NotFound, "secret %q not found", name)
+	}
+
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "error reading secret %q", name)
+	}
+
+	if rel, err := filepath.Rel(s.dir, file); err != nil || !filepath.IsLocal(rel) {
+		return nil, status.Errorf(codes.PermissionDenied, "secret %q is outside of the secrets directory", name)
+	}
+
+	value, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, status.Errorf(codes.NotFound, "secret %q not found", name)
+	}
+
+	if err != nil {
+		// Errors reading a directory mean the name does not refer to a secret.
+		if info, statErr := os.Stat(file); statErr == nil && info.IsDir() {
+			return nil, status.Errorf(codes.NotFound, "secret %q not found", name)
+		}
+
+		return nil, status.Errorf(codes.Internal, "error reading secret %q", name)
+	}
+
+	sum := sha256.Sum256(value)
+
+	out := &secrets.Secret{
+		Name:    name,
+		Version: hex.EncodeToString(sum[:8]),
+		Value:   value,
+	}
+
+	return out, nil
+}
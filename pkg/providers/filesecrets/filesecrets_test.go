@@ -0,0 +1,289 @@
+package filesecrets
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/secrets"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testStream is a Secrets_WatchSecretServer which passes the secrets sent to it over a channel.
+type testStream struct {
+	grpc.ServerStream
+
+	ctx     context.Context
+	secrets chan *secrets.Secret
+}
+
+func (s *testStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *testStream) Send(resp *secrets.WatchSecretResponse) error {
+	select {
+	case s.secrets <- resp.GetSecret():
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+func writeSecret(t *testing.T, dir, name, value string) {
+	t.Helper()
+
+	file := filepath.Join(dir, filepath.FromSlash(name))
+
+	if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+		t.Fatalf("error creating secret directory: %v", err)
+	}
+
+	if err := os.WriteFile(file, []byte(value), 0o600); err != nil {
+		t.Fatalf("error writing secret: %v", err)
+	}
+}
+
+func newTestServer(t *testing.T, dir string) *Server {
+	t.Helper()
+
+	srv, err := NewServer(Config{
+		Dir:          dir,
+		PollInterval: time.Millisecond,
+		Workload:     "billing-api",
+		Access: map[string][]string{
+			"billing-api": {"database/*", "stripe/api-key", "links/*"},
+			"reports":     {"reports/*"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("error creating server: %v", err)
+	}
+
+	return srv
+}
+
+func getSecret(srv *Server, name, version string) (*secrets.Secret, error) {
+	req := &secrets.GetSecretRequest{
+		Name:    name,
+		Version: version,
+	}
+
+	resp, err := srv.GetSecret(context.Background(), req)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.GetSecret(), nil
+}
+
+func TestGetSecret(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+
+	writeSecret(t, dir, "database/password", "hunter2")
+	writeSecret(t, dir, "database/replica/password", "hunter3")
+	writeSecret(t, dir, "stripe/api-key", "sk_test")
+	writeSecret(t, dir, "reports/password", "reports")
+	writeSecret(t, dir, "links/data/current", "linked")
+	writeSecret(t, outside, "password", "outside")
+
+	// Links within the directory are followed, but links out of it are not.
+	if err := os.Symlink(filepath.Join("data", "current"), filepath.Join(dir, "links", "current")); err != nil {
+		t.Fatalf("error creating link: %v", err)
+	}
+
+	if err := os.Symlink(filepath.Join(outside, "password"), filepath.Join(dir, "links", "outside")); err != nil {
+		t.Fatalf("error creating link: %v", err)
+	}
+
+	if err := os.Symlink(outside, filepath.Join(dir, "database", "outside")); err != nil {
+		t.Fatalf("error creating link: %v", err)
+	}
+
+	srv := newTestServer(t, dir)
+
+	tests := []struct {
+		name      string
+		secret    string
+		wantValue string
+		wantCode  codes.Code
+	}{
+		{"allowed by pattern", "database/password", "hunter2", codes.OK},
+		{"allowed by name", "stripe/api-key", "sk_test", codes.OK},
+		{"pattern does not match subdirectories", "database/replica/password", "", codes.PermissionDenied},
+		{"another workload's secret", "reports/password", "", codes.PermissionDenied},
+		{"not found", "database/username", "", codes.NotFound},
+		{"directory", "database/replica", "", codes.NotFound},
+		{"link within directory", "links/current", "linked", codes.OK},
+		{"link out of directory", "links/outside", "", codes.PermissionDenied},
+		{"linked directory out of directory", "database/outside", "", codes.PermissionDenied},
+		{"parent directory", "../password", "", codes.InvalidArgument},
+		{"absolute", "/database/password", "", codes.InvalidArgument},
+		{"empty", "", "", codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret, err := getSecret(srv, tt.secret, "")
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("got code %s, want %s: %v", code, tt.wantCode, err)
+			}
+
+			if err == nil && string(secret.GetValue()) != tt.wantValue {
+				t.Errorf("got value %q, want %q", secret.GetValue(), tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestGetSecretVersion(t *testing.T) {
+	dir := t.TempDir()
+
+	writeSecret(t, dir, "database/password", "hunter2")
+
+	srv := newTestServer(t, dir)
+
+	secret, err := getSecret(srv, "database/password", "")
+	if err != nil {
+		t.Fatalf("error getting secret: %v", err)
+	}
+
+	if _, err := getSecret(srv, "database/password", secret.GetVersion()); err != nil {
+		t.Errorf("error getting current version: %v", err)
+	}
+
+	writeSecret(t, dir, "database/password", "hunter3")
+
+	if _, err := getSecret(srv, "database/password", secret.GetVersion()); status.Code(err) != codes.NotFound {
+		t.Errorf("got error %v getting previous version, want %s", err, codes.NotFound)
+	}
+
+	changed, err := getSecret(srv, "database/password", "")
+	if err != nil {
+		t.Fatalf("error getting secret: %v", err)
+	}
+
+	if changed.GetVersion() == secret.GetVersion() {
+		t.Errorf("version %s unchanged after changing secret", changed.GetVersion())
+	}
+}
+
+func TestWatchSecret(t *testing.T) {
+	dir := t.TempDir()
+
+	writeSecret(t, dir, "database/password", "hunter2")
+
+	srv := newTestServer(t, dir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	stream := &testStream{
+		ctx:     ctx,
+		secrets: make(chan *secrets.Secret),
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- srv.WatchSecret(&secrets.WatchSecretRequest{Name: "database/password"}, stream)
+	}()
+
+	receive := func() *secrets.Secret {
+		t.Helper()
+
+		select {
+		case secret := <-stream.secrets:
+			return secret
+		case err := <-done:
+			t.Fatalf("watch ended: %v", err)
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for secret")
+		}
+
+		return nil
+	}
+
+	if got := receive(); string(got.GetValue()) != "hunter2" {
+		t.Errorf("got value %q, want hunter2", got.GetValue())
+	}
+
+	writeSecret(t, dir, "database/password", "hunter3")
+
+	if got := receive(); string(got.GetValue()) != "hunter3" {
+		t.Errorf("got value %q after changing secret, want hunter3", got.GetValue())
+	}
+
+	if err := os.Remove(filepath.Join(dir, "database", "password")); err != nil {
+		t.Fatalf("error removing secret: %v", err)
+	}
+
+	select {
+	case err := <-done:
+		if status.Code(err) != codes.NotFound {
+			t.Errorf("got error %v after removing secret, want %s", err, codes.NotFound)
+		}
+	case secret := <-stream.secrets:
+		t.Errorf("got secret %v after removing secret, want none", secret)
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for watch to end")
+	}
+}
+
+func TestWatchSecretFromVersion(t *testing.T) {
+	dir := t.TempDir()
+
+	writeSecret(t, dir, "database/password", "hunter2")
+
+	srv := newTestServer(t, dir)
+
+	secret, err := getSecret(srv, "database/password", "")
+	if err != nil {
+		t.Fatalf("error getting secret: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	stream := &testStream{
+		ctx:     ctx,
+		secrets: make(chan *secrets.Secret, 1),
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- srv.WatchSecret(&secrets.WatchSecretRequest{Name: "database/password", Version: secret.GetVersion()}, stream)
+	}()
+
+	// The version the client already has is not sent.
+	time.Sleep(20 * time.Millisecond)
+
+	select {
+	case got := <-stream.secrets:
+		t.Fatalf("got secret %v the client already has", got)
+	default:
+	}
+
+	writeSecret(t, dir, "database/password", "hunter3")
+
+	select {
+	case got := <-stream.secrets:
+		if string(got.GetValue()) != "hunter3" {
+			t.Errorf("got value %q, want hunter3", got.GetValue())
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for secret")
+	}
+
+	cancel()
+
+	if err := <-done; err != nil {
+		t.Errorf("got error %v after cancelling, want none", err)
+	}
+}
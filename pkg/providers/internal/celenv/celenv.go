@@ -0,0 +1,107 @@
+// Package celenv defines the CEL environment shared by the Authorization providers which evaluate
+// CEL expressions, such that expressions are written the same way regardless of provider.
+//
+// Expressions may refer to the following variables:
+//
+//   - subject: the Subject the credential identifies, with fields subject_id and claims, where
+//     claims are those returned by the Authentication service
+//   - action: the action being checked
+//   - resource_id: the ID of the resource the action is on
+//   - now: the time the request was received, as a timestamp
+//   - metadata: the gRPC metadata of the request, as a map from each lowercase key to its values
+//     joined with commas
+package celenv
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
+	"google.golang.org/grpc/metadata"
+)
+
+// New returns the CEL environment expressions are compiled in. Every variable is typed, so that
+// referring to an unknown variable or field fails to compile.
+func New() (*cel.Env, error) {
+	subject := &authentication.Subject{}
+
+	return cel.NewEnv(
+		cel.Types(subject),
+		cel.Variable("subject", cel.ObjectType(string(subject.ProtoReflect().Descriptor().FullName()))),
+		cel.Variable("action", cel.StringType),
+		cel.Variable("resource_id", cel.StringType),
+		cel.Variable("now", cel.TimestampType),
+		cel.Variable("metadata", cel.MapType(cel.StringType, cel.StringType)),
+	)
+}
+
+// Compile compiles and type checks an expression, which must evaluate to a bool, and returns a
+// program which evaluates it within the given cost limit.
+func Compile(env *cel.Env, expression string, costLimit uint64) (cel.Program, error) {
+	ast, issues := env.Compile(expression)
+	if issues.Err() != nil {
+		return nil, fmt.Errorf("error compiling expression: %w", issues.Err())
+	}
+
+	if t := ast.OutputType(); !t.IsAssignableType(cel.BoolType) {
+		return nil, fmt.Errorf("expression must evaluate to bool, not %s", t)
+	}
+
+	program, err := env.Program(ast,
+		cel.CostLimit(costLimit),
+		cel.InterruptCheckFrequency(100),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating program: %w", err)
+	}
+
+	return program, nil
+}
+
+// Request holds the variables which are the same for every action in a request.
+type Request struct {
+	subject  *authentication.Subject
+	now      time.Time
+	metadata map[string]string
+}
+
+// NewRequest returns the variables for a request received at now by the given subject, including
+// the gRPC metadata of ctx.
+func NewRequest(ctx context.Context, subject *authentication.Subject, now time.Time) Request {
+	if subject == nil {
+		subject = &authentication.Subject{}
+	}
+
+	md := make(map[string]string)
+
+	if incoming, ok := metadata.FromIncomingContext(ctx); ok {
+		for key, values := range incoming {
+			md[key] = strings.Join(values, ",")
+		}
+	}
+
+	out := Request{
+		subject:  subject,
+		now:      now,
+		metadata: md,
+	}
+
+	return out
+}
+
+// Vars returns the variables an expression is evaluated with for the given action on the resource
+// with the given ID.
+func (r Request) Vars(action, resourceID string) map[string]any {
+	out := map[string]any{
+		"subject":     r.subject,
+		"action":      action,
+		"resource_id": resourceID,
+		"now":         r.now,
+		"metadata":    r.metadata,
+	}
+
+	return out
+}
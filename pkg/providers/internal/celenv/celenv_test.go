@@ -0,0 +1,203 @@
+package celenv
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const testCostLimit = 1_000_000
+
+func TestCompile(t *testing.T) {
+	env, err := New()
+	if err != nil {
+		t.Fatalf("error creating environment: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		wantErr    bool
+	}{
+		{
+			name:       "claims",
+			expression: `subject.claims.groups.exists(g, g == "admins")`,
+		},
+		{
+			name:       "every variable",
+			expression: `subject.subject_id != "" && action == "read" && resource_id.startsWith("tnnt-") && now > timestamp("2024-01-01T00:00:00Z") && metadata["x-env"] == "prod"`,
+		},
+		{
+			name:       "dynamic claim",
+			expression: `subject.claims.admin`,
+		},
+		{
+			name:       "unknown variable",
+			expression: `subjct.claims.groups.exists(g, g == "admins")`,
+			wantErr:    true,
+		},
+		{
+			name:       "unknown subject field",
+			expression: `subject.id == "alice"`,
+			wantErr:    true,
+		},
+		{
+			name:       "mistyped variable",
+			expression: `action > 1`,
+			wantErr:    true,
+		},
+		{
+			name:       "not bool",
+			expression: `resource_id`,
+			wantErr:    true,
+		},
+		{
+			name:       "syntax error",
+			expression: `action ==`,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(env, tt.expression, testCostLimit)
+			if gotErr := err != nil; gotErr != tt.wantErr {
+				t.Errorf("got error %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	env, err := New()
+	if err != nil {
+		t.Fatalf("error creating environment: %v", err)
+	}
+
+	claims, err := structpb.NewStruct(map[string]any{
+		"groups": []any{"admins"},
+		"tenant": "tnnt-a",
+	})
+	if err != nil {
+		t.Fatalf("error creating claims: %v", err)
+	}
+
+	subject := &authentication.Subject{
+		SubjectId: "alice",
+		Claims:    claims,
+	}
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-env", "prod", "x-env", "eu"))
+
+	request := NewRequest(ctx, subject, now)
+
+	tests := []struct {
+		name       string
+		expression string
+		action     string
+		resourceID string
+		want       bool
+		wantErr    bool
+	}{
+		{
+			name:       "claims",
+			expression: `subject.claims.groups.exists(g, g == "admins") && resource_id.startsWith(subject.claims.tenant)`,
+			resourceID: "tnnt-a-widget",
+			want:       true,
+		},
+		{
+			name:       "subject ID",
+			expression: `subject.subject_id == "alice"`,
+			want:       true,
+		},
+		{
+			name:       "action",
+			expression: `action.startsWith("read_")`,
+			action:     "write_widget",
+			want:       false,
+		},
+		{
+			name:       "now",
+			expression: `now.getFullYear() == 2024`,
+			want:       true,
+		},
+		{
+			name:       "metadata",
+			expression: `metadata["x-env"] == "prod,eu"`,
+			want:       true,
+		},
+		{
+			name:       "missing claim",
+			expression: `subject.claims.roles.exists(r, r == "reader")`,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program, err := Compile(env, tt.expression, testCostLimit)
+			if err != nil {
+				t.Fatalf("error compiling: %v", err)
+			}
+
+			val, _, err := program.Eval(request.Vars(tt.action, tt.resourceID))
+			if gotErr := err != nil; gotErr != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if got, ok := val.Value().(bool); !ok || got != tt.want {
+				t.Errorf("got %v, want %t", val.Value(), tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateWithoutClaims(t *testing.T) {
+	env, err := New()
+	if err != nil {
+		t.Fatalf("error creating environment: %v", err)
+	}
+
+	program, err := Compile(env, `subject.subject_id == "alice" && !("admin" in subject.claims)`, testCostLimit)
+	if err != nil {
+		t.Fatalf("error compiling: %v", err)
+	}
+
+	request := NewRequest(context.Background(), &authentication.Subject{SubjectId: "alice"}, time.Now())
+
+	val, _, err := program.Eval(request.Vars("read", "tnnt-a"))
+	if err != nil {
+		t.Fatalf("error evaluating: %v", err)
+	}
+
+	if val.Value() != true {
+		t.Errorf("got %v, want true", val.Value())
+	}
+}
+
+func TestCostLimit(t *testing.T) {
+	env, err := New()
+	if err != nil {
+		t.Fatalf("error creating environment: %v", err)
+	}
+
+	program, err := Compile(env, `[1, 2, 3, 4, 5, 6, 7, 8].all(x, [1, 2, 3, 4, 5, 6, 7, 8].all(y, x + y > 0))`, 10)
+	if err != nil {
+		t.Fatalf("error compiling: %v", err)
+	}
+
+	request := NewRequest(context.Background(), &authentication.Subject{}, time.Now())
+
+	if _, _, err := program.Eval(request.Vars("read", "tnnt-a")); err == nil {
+		t.Error("got no error exceeding cost limit")
+	}
+}
@@ -0,0 +1,223 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+)
+
+// minRefreshInterval is the minimum amount of time between attempts to load a key set outside of
+// its refresh interval, such as when credentials are signed with unknown keys or a previous
+// attempt failed, so that neither such credentials nor an unavailable issuer cause a fetch on
+// every request.
+const minRefreshInterval = 10 * time.Second
+
+// maxResponseSize is the maximum size of a discovery document or JWKS fetched over HTTP.
+const maxResponseSize = 1 << 20
+
+// keySet is a cached JWKS for an issuer, loaded from a URL or local file.
+type keySet struct {
+	issuer          string
+	url             string
+	file            string
+	refreshInterval time.Duration
+	client          *http.Client
+
+	// loadMu is held while loading the key set, such that only one load is in progress at a time
+	// and requests using cached keys are not blocked by it.
+	loadMu sync.Mutex
+
+	mu          sync.Mutex
+	keys        *jose.JSONWebKeySet
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	err         error
+}
+
+// getKeys returns the keys in the set with the given key ID, or every key if kid is empty. The key
+// set is refreshed if it is stale, or if no key has the given ID and the set was not loaded
+// recently. An error is returned only if the key set has never been loaded.
+func (s *keySet) getKeys(ctx context.Context, kid string) ([]jose.JSONWebKey, error) {
+	keys, fetchedAt, err := s.cached()
+
+	switch {
+	case keys == nil:
+		// Nothing can be verified until the key set is loaded, so wait for it.
+		s.refresh(ctx, true)
+
+		keys, _, err = s.cached()
+		if keys == nil {
+			if err == nil {
+				err = fmt.Errorf("error loading JWKS for issuer %s: %w", s.issuer, ctx.Err())
+			}
+
+			return nil, err
+		}
+	case time.Since(fetchedAt) >= s.refreshInterval:
+		// Stale keys remain usable, so requests do not wait while another refreshes them.
+		s.refresh(ctx, false)
+
+		keys, _, _ = s.cached()
+	}
+
+	if kid == "" {
+		return keys.Keys, nil
+	}
+
+	if found := keys.Key(kid); len(found) > 0 {
+		return found, nil
+	}
+
+	// The issuer may have added the key since the set was loaded. If it cannot be loaded, the
+	// credential cannot be verified with the keys available, and is treated as having a bad
+	// signature.
+	s.refresh(ctx, true)
+
+	keys, _, _ = s.cached()
+
+	return keys.Key(kid), nil
+}
+
+// cached returns the currently loaded keys, the time they were loaded, and the error from the
+// last attempt to load them, if it failed.
+func (s *keySet) cached() (*jose.JSONWebKeySet, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.keys, s.fetchedAt, s.err
+}
+
+// refresh loads the key set unless it was attempted within minRefreshInterval. If wait is false
+// and another load is already in progress, it returns immediately. If loading fails, the
+// previously loaded keys remain in use.
+func (s *keySet) refresh(ctx context.Context, wait bool) {
+	if wait {
+		s.loadMu.Lock()
+	} else if !s.loadMu.TryLock() {
+		return
+	}
+
+	defer s.loadMu.Unlock()
+
+	s.mu.Lock()
+	attemptedAt := s.attemptedAt
+	s.mu.Unlock()
+
+	if !attemptedAt.IsZero() && time.Since(attemptedAt) < minRefreshInterval {
+		return
+	}
+
+	keys, err := s.load(ctx)
+
+	// A load abandoned by the request which started it says nothing about the issuer, so it is
+	// not counted as an attempt.
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.attemptedAt = time.Now()
+	s.err = err
+
+	if err != nil {
+		log.Printf("%v", err)
+
+		return
+	}
+
+	s.keys = keys
+	s.fetchedAt = s.attemptedAt
+}
+
+// load reads or fetches the key set.
+func (s *keySet) load(ctx context.Context) (*jose.JSONWebKeySet, error) {
+	var (
+		b   []byte
+		err error
+	)
+
+	if s.file != "" {
+		b, err = os.ReadFile(s.file)
+	} else {
+		b, err = s.fetchJWKS(ctx)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error loading JWKS for issuer %s: %w", s.issuer, err)
+	}
+
+	keys := &jose.JSONWebKeySet{}
+	if err := json.Unmarshal(b, keys); err != nil {
+		return nil, fmt.Errorf("error parsing JWKS for issuer %s: %w", s.issuer, err)
+	}
+
+	return keys, nil
+}
+
+// fetchJWKS fetches the key set from its URL, discovering the URL using OpenID Connect Discovery
+// if none is configured.
+func (s *keySet) fetchJWKS(ctx context.Context) ([]byte, error) {
+	url := s.url
+
+	if url == "" {
+		discoveryURL := strings.TrimSuffix(s.issuer, "/") + "/.well-known/openid-configuration"
+
+		b, err := s.get(ctx, discoveryURL)
+		if err != nil {
+			return nil, err
+		}
+
+		var discovery struct {
+			Issuer  string `json:"issuer"`
+			JWKSURI string `json:"jwks_uri"`
+		}
+
+		if err := json.Unmarshal(b, &discovery); err != nil {
+			return nil, fmt.Errorf("error parsing discovery document: %w", err)
+		}
+
+		if discovery.Issuer != s.issuer {
+			return nil, fmt.Errorf("discovery document issuer %q does not match %q", discovery.Issuer, s.issuer)
+		}
+
+		if discovery.JWKSURI == "" {
+			return nil, errors.New("discovery document does not contain jwks_uri")
+		}
+
+		url = discovery.JWKSURI
+	}
+
+	return s.get(ctx, url)
+}
+
+func (s *keySet) get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status fetching %s: %s", url, resp.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+}
@@ -0,0 +1,341 @@
+// Package jwt provides an Authentication service implementation which validates JSON Web Tokens
+// issued by trusted issuers, such as OpenID Connect providers.
+//
+// Signing keys are loaded from each issuer's JWKS, which is fetched from a configured URL, read
+// from a local file, or located using OpenID Connect Discovery. Key sets are cached and refreshed
+// periodically, and when a token is signed with a key which is not in the cached set. The public
+// keys of every issuer are served as the runtime's trust bundle.
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+	josejwt "github.com/go-jose/go-jose/v4/jwt"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
+	"github.com/metal-toolbox/iam-runtime/pkg/revocation"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// DefaultClockSkew is the clock skew allowed when checking time-based claims if none is
+	// configured.
+	DefaultClockSkew = time.Minute
+	// DefaultRefreshInterval is the interval at which key sets are refreshed if none is configured.
+	DefaultRefreshInterval = 15 * time.Minute
+	// DefaultHTTPTimeout is the timeout for fetching key sets if no HTTP client is configured.
+	DefaultHTTPTimeout = 10 * time.Second
+)
+
+// DefaultAlgorithms is the set of signature algorithms accepted if none are configured.
+var DefaultAlgorithms = []string{
+	string(jose.RS256), string(jose.RS384), string(jose.RS512),
+	string(jose.PS256), string(jose.PS384), string(jose.PS512),
+	string(jose.ES256), string(jose.ES384), string(jose.ES512),
+	string(jose.EdDSA),
+}
+
+// Config represents the configuration for a JWT Authentication server.
+type Config struct {
+	// Issuers is the set of trusted issuers.
+	Issuers []IssuerConfig `yaml:"issuers"`
+	// Audiences is the set of audiences tokens must be issued for when a request does not specify
+	// an audience. If empty, the audience of such tokens is not checked.
+	Audiences []string `yaml:"audiences"`
+	// ClockSkew is the clock skew allowed when checking the exp, nbf, and iat claims.
+	ClockSkew time.Duration `yaml:"clock_skew"`
+	// Algorithms is the set of signature algorithms accepted.
+	Algorithms []string `yaml:"algorithms"`
+	// HTTPClient is the client used to fetch key sets and discovery documents.
+	HTTPClient *http.Client `yaml:"-"`
+	// Revocations is the store of revoked credentials, if any. Tokens are matched against
+	// revocations by their jti, sub, and iat claims.
+	Revocations *revocation.Store `yaml:"-"`
+}
+
+// IssuerConfig describes a trusted issuer. At most one of JWKSURL and JWKSFile may be set; if
+// neither is set, the JWKS URL is discovered from the issuer's OpenID Connect discovery document.
+type IssuerConfig struct {
+	// Issuer is the issuer identifier, which must match the iss claim of tokens exactly.
+	Issuer string `yaml:"issuer"`
+	// JWKSURL is the URL of the issuer's JWKS.
+	JWKSURL string `yaml:"jwks_url"`
+	// JWKSFile is the path of a file containing the issuer's JWKS.
+	JWKSFile string `yaml:"jwks_file"`
+	// RefreshInterval is the interval at which the issuer's JWKS is refreshed.
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+}
+
+// Server is an Authentication server which validates JWTs.
+type Server struct {
+	authentication.UnimplementedAuthenticationServer
+
+	issuers     map[string]*keySet
+	issuerNames []string
+	audiences   []string
+	clockSkew   time.Duration
+	algorithms  []jose.SignatureAlgorithm
+	revocations *revocation.Store
+}
+
+// NewServer creates a new Server using the given config. Key sets are loaded on first use.
+func NewServer(cfg Config) (*Server, error) {
+	if len(cfg.Issuers) == 0 {
+		return nil, errors.New("at least one issuer is required")
+	}
+
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{
+			Timeout: DefaultHTTPTimeout,
+		}
+	}
+
+	clockSkew := cfg.ClockSkew
+	if clockSkew <= 0 {
+		clockSkew = DefaultClockSkew
+	}
+
+	algorithms := cfg.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = DefaultAlgorithms
+	}
+
+	out := &Server{
+		issuers:     make(map[string]*keySet, len(cfg.Issuers)),
+		audiences:   cfg.Audiences,
+		clockSkew:   clockSkew,
+		revocations: cfg.Revocations,
+	}
+
+	for _, alg := range algorithms {
+		out.algorithms = append(out.algorithms, jose.SignatureAlgorithm(alg))
+	}
+
+	for _, iss := range cfg.Issuers {
+		if iss.Issuer == "" {
+			return nil, errors.New("issuer is required")
+		}
+
+		if iss.JWKSURL != "" && iss.JWKSFile != "" {
+			return nil, fmt.Errorf("issuer %s: only one of jwks_url and jwks_file may be set", iss.Issuer)
+		}
+
+		if _, ok := out.issuers[iss.Issuer]; ok {
+			return nil, fmt.Errorf("issuer %s: duplicate issuer", iss.Issuer)
+		}
+
+		refreshInterval := iss.RefreshInterval
+		if refreshInterval <= 0 {
+			refreshInterval = DefaultRefreshInterval
+		}
+
+		out.issuers[iss.Issuer] = &keySet{
+			issuer:          iss.Issuer,
+			url:             iss.JWKSURL,
+			file:            iss.JWKSFile,
+			refreshInterval: refreshInterval,
+			client:          client,
+		}
+
+		out.issuerNames = append(out.issuerNames, iss.Issuer)
+	}
+
+	sort.Strings(out.issuerNames)
+
+	return out, nil
+}
+
+// Ready loads the key set of every issuer, returning an error if any cannot be loaded.
+func (s *Server) Ready(ctx context.Context) error {
+	for _, iss := range s.issuerNames {
+		if _, err := s.issuers[iss].getKeys(ctx, ""); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RPCs returns the names of the Authentication RPCs the server implements.
+func (s *Server) RPCs() []string {
+	return []string{"ValidateCredential", "GetTrustBundle", "WatchTrustBundle"}
+}
+
+// CredentialTypes returns the types of credential the server accepts.
+func (s *Server) CredentialTypes() []authentication.CredentialType {
+	return []authentication.CredentialType{authentication.CredentialType_CREDENTIAL_TYPE_JWT}
+}
+
+// claims holds the claims of a token which are returned outside of Subject.claims.
+type claims struct {
+	josejwt.Claims
+
+	AuthenticationMethods []string `json:"amr"`
+	AssuranceLevel        string   `json:"acr"`
+}
+
+// ValidateCredential verifies the signature of the given JWT using the keys of its issuer and
+// checks its registered claims.
+func (s *Server) ValidateCredential(ctx context.Context, req *authentication.ValidateCredentialRequest) (*authentication.ValidateCredentialResponse, error) {
+	switch req.GetCredentialType() {
+	case authentication.CredentialType_CREDENTIAL_TYPE_UNSPECIFIED, authentication.CredentialType_CREDENTIAL_TYPE_JWT:
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported credential type %s", req.GetCredentialType())
+	}
+
+	token, err := josejwt.ParseSigned(req.GetCredential(), s.algorithms)
+	if err != nil {
+		return invalid(authentication.ValidateCredentialResponse_INVALID_REASON_MALFORMED), nil
+	}
+
+	var unverified josejwt.Claims
+	if err := token.UnsafeClaimsWithoutVerification(&unverified); err != nil {
+		return invalid(authentication.ValidateCredentialResponse_INVALID_REASON_MALFORMED), nil
+	}
+
+	keys, ok := s.issuers[unverified.Issuer]
+	if !ok {
+		return invalid(authentication.ValidateCredentialResponse_INVALID_REASON_UNKNOWN_ISSUER), nil
+	}
+
+	var (
+		verified  claims
+		rawClaims map[string]any
+	)
+
+	reason, err := s.verify(ctx, token, keys, &verified, &rawClaims)
+	if err != nil {
+		return nil, err
+	}
+
+	if reason != authentication.ValidateCredentialResponse_INVALID_REASON_UNSPECIFIED {
+		return invalid(reason), nil
+	}
+
+	if reason := s.validateClaims(verified.Claims, req.GetAudience()); reason != authentication.ValidateCredentialResponse_INVALID_REASON_UNSPECIFIED {
+		return invalid(reason), nil
+	}
+
+	if s.isRevoked(verified.Claims) {
+		return invalid(authentication.ValidateCredentialResponse_INVALID_REASON_REVOKED), nil
+	}
+
+	subjectClaims, err := structpb.NewStruct(rawClaims)
+	if err != nil {
+		return invalid(authentication.ValidateCredentialResponse_INVALID_REASON_MALFORMED), nil
+	}
+
+	out := &authentication.ValidateCredentialResponse{
+		Result: authentication.ValidateCredentialResponse_RESULT_VALID,
+		Subject: &authentication.Subject{
+			SubjectId: verified.Subject,
+			Claims:    subjectClaims,
+		},
+		ExpiresAt:             timestamppb.New(verified.Expiry.Time()),
+		Issuer:                verified.Issuer,
+		AuthenticationMethods: verified.AuthenticationMethods,
+		AssuranceLevel:        verified.AssuranceLevel,
+	}
+
+	return out, nil
+}
+
+// verify verifies the signature of token using the issuer's keys and decodes its claims into dest.
+// It returns a reason if the token is not valid, and an error only if the issuer's keys could not
+// be loaded.
+func (s *Server) verify(ctx context.Context, token *josejwt.JSONWebToken, keys *keySet, dest ...any) (authentication.ValidateCredentialResponse_InvalidReason, error) {
+	var kid string
+	if len(token.Headers) > 0 {
+		kid = token.Headers[0].KeyID
+	}
+
+	candidates, err := keys.getKeys(ctx, kid)
+	if err != nil {
+		return authentication.ValidateCredentialResponse_INVALID_REASON_UNSPECIFIED, unavailable(err)
+	}
+
+	for _, key := range candidates {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+
+		if err := token.Claims(key.Key, dest...); err == nil {
+			return authentication.ValidateCredentialResponse_INVALID_REASON_UNSPECIFIED, nil
+		}
+	}
+
+	return authentication.ValidateCredentialResponse_INVALID_REASON_BAD_SIGNATURE, nil
+}
+
+// validateClaims checks the registered claims of a token whose signature has been verified.
+func (s *Server) validateClaims(c josejwt.Claims, audience string) authentication.ValidateCredentialResponse_InvalidReason {
+	if c.Subject == "" || c.Expiry == nil {
+		return authentication.ValidateCredentialResponse_INVALID_REASON_MALFORMED
+	}
+
+	expected := josejwt.Expected{
+		Issuer:      c.Issuer,
+		AnyAudience: s.audiences,
+	}
+
+	if audience != "" {
+		expected.AnyAudience = josejwt.Audience{audience}
+	}
+
+	err := c.ValidateWithLeeway(expected, s.clockSkew)
+
+	switch {
+	case err == nil:
+		return authentication.ValidateCredentialResponse_INVALID_REASON_UNSPECIFIED
+	case errors.Is(err, josejwt.ErrExpired):
+		return authentication.ValidateCredentialResponse_INVALID_REASON_EXPIRED
+	case errors.Is(err, josejwt.ErrNotValidYet), errors.Is(err, josejwt.ErrIssuedInTheFuture):
+		return authentication.ValidateCredentialResponse_INVALID_REASON_NOT_YET_VALID
+	case errors.Is(err, josejwt.ErrInvalidAudience):
+		return authentication.ValidateCredentialResponse_INVALID_REASON_AUDIENCE_MISMATCH
+	default:
+		return authentication.ValidateCredentialResponse_INVALID_REASON_MALFORMED
+	}
+}
+
+// isRevoked reports whether the token with the given verified claims has been revoked.
+func (s *Server) isRevoked(c josejwt.Claims) bool {
+	if s.revocations == nil {
+		return false
+	}
+
+	cred := revocation.Credential{
+		TokenID:   c.ID,
+		SubjectID: c.Subject,
+		IssuedAt:  c.IssuedAt.Time(),
+	}
+
+	_, revoked := s.revocations.IsRevoked(cred)
+
+	return revoked
+}
+
+func invalid(reason authentication.ValidateCredentialResponse_InvalidReason) *authentication.ValidateCredentialResponse {
+	return &authentication.ValidateCredentialResponse{
+		Result:        authentication.ValidateCredentialResponse_RESULT_INVALID,
+		InvalidReason: reason,
+	}
+}
+
+// unavailable returns an UNAVAILABLE status error for an error loading keys, which is expected to be
+// transient.
+func unavailable(err error) error {
+	log.Printf("error loading keys: %v", err)
+
+	return status.Error(codes.Unavailable, "error loading issuer keys")
+}
@@ -0,0 +1,397 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+	josejwt "github.com/go-jose/go-jose/v4/jwt"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const testIssuer = "https://issuer.example.com"
+
+// testIssuerServer serves a JWKS over HTTP, counting the requests made for it.
+type testIssuerServer struct {
+	*httptest.Server
+
+	keys     []jose.JSONWebKey
+	down     atomic.Bool
+	requests atomic.Int32
+}
+
+func newTestIssuerServer(t *testing.T, keys ...jose.JSONWebKey) *testIssuerServer {
+	t.Helper()
+
+	srv := &testIssuerServer{
+		keys: keys,
+	}
+
+	srv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		srv.requests.Add(1)
+
+		if srv.down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		jwks := jose.JSONWebKeySet{}
+		for _, key := range srv.keys {
+			jwks.Keys = append(jwks.Keys, key.Public())
+		}
+
+		if err := json.NewEncoder(w).Encode(jwks); err != nil {
+			t.Errorf("error encoding JWKS: %v", err)
+		}
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func newTestKey(t *testing.T, kid string) jose.JSONWebKey {
+	t.Helper()
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+
+	return jose.JSONWebKey{
+		Key:       priv,
+		KeyID:     kid,
+		Algorithm: string(jose.ES256),
+		Use:       "sig",
+	}
+}
+
+func newTestServer(t *testing.T, issuer *testIssuerServer) *Server {
+	t.Helper()
+
+	cfg := Config{
+		Issuers: []IssuerConfig{
+			{
+				Issuer:  testIssuer,
+				JWKSURL: issuer.URL,
+			},
+		},
+		Audiences: []string{"test"},
+	}
+
+	srv, err := NewServer(cfg)
+	if err != nil {
+		t.Fatalf("error creating server: %v", err)
+	}
+
+	return srv
+}
+
+func signToken(t *testing.T, key jose.JSONWebKey, claims josejwt.Claims) string {
+	t.Helper()
+
+	signingKey := jose.SigningKey{
+		Algorithm: jose.ES256,
+		Key:       key,
+	}
+
+	signer, err := jose.NewSigner(signingKey, nil)
+	if err != nil {
+		t.Fatalf("error creating signer: %v", err)
+	}
+
+	token, err := josejwt.Signed(signer).Claims(claims).Serialize()
+	if err != nil {
+		t.Fatalf("error signing token: %v", err)
+	}
+
+	return token
+}
+
+func testClaims() josejwt.Claims {
+	now := time.Now()
+
+	return josejwt.Claims{
+		Issuer:   testIssuer,
+		Subject:  "alice",
+		Audience: josejwt.Audience{"test"},
+		IssuedAt: josejwt.NewNumericDate(now),
+		Expiry:   josejwt.NewNumericDate(now.Add(time.Hour)),
+	}
+}
+
+func validate(t *testing.T, srv *Server, req *authentication.ValidateCredentialRequest) *authentication.ValidateCredentialResponse {
+	t.Helper()
+
+	resp, err := srv.ValidateCredential(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error validating credential: %v", err)
+	}
+
+	return resp
+}
+
+func TestValidateCredential(t *testing.T) {
+	key := newTestKey(t, "key-1")
+	issuer := newTestIssuerServer(t, key)
+	srv := newTestServer(t, issuer)
+
+	expired := testClaims()
+	expired.Expiry = josejwt.NewNumericDate(time.Now().Add(-time.Hour))
+
+	tests := []struct {
+		name     string
+		req      *authentication.ValidateCredentialRequest
+		result   authentication.ValidateCredentialResponse_Result
+		reason   authentication.ValidateCredentialResponse_InvalidReason
+		subject  string
+		wantCode codes.Code
+	}{
+		{
+			name: "valid",
+			req: &authentication.ValidateCredentialRequest{
+				Credential: signToken(t, key, testClaims()),
+			},
+			result:  authentication.ValidateCredentialResponse_RESULT_VALID,
+			subject: "alice",
+		},
+		{
+			name: "expired",
+			req: &authentication.ValidateCredentialRequest{
+				Credential: signToken(t, key, expired),
+			},
+			result: authentication.ValidateCredentialResponse_RESULT_INVALID,
+			reason: authentication.ValidateCredentialResponse_INVALID_REASON_EXPIRED,
+		},
+		{
+			name: "audience mismatch",
+			req: &authentication.ValidateCredentialRequest{
+				Credential: signToken(t, key, testClaims()),
+				Audience:   "other",
+			},
+			result: authentication.ValidateCredentialResponse_RESULT_INVALID,
+			reason: authentication.ValidateCredentialResponse_INVALID_REASON_AUDIENCE_MISMATCH,
+		},
+		{
+			name: "malformed",
+			req: &authentication.ValidateCredentialRequest{
+				Credential: "not-a-jwt",
+			},
+			result: authentication.ValidateCredentialResponse_RESULT_INVALID,
+			reason: authentication.ValidateCredentialResponse_INVALID_REASON_MALFORMED,
+		},
+		{
+			name: "unsupported credential type",
+			req: &authentication.ValidateCredentialRequest{
+				Credential:     signToken(t, key, testClaims()),
+				CredentialType: authentication.CredentialType_CREDENTIAL_TYPE_API_KEY,
+			},
+			wantCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := srv.ValidateCredential(context.Background(), tt.req)
+			if tt.wantCode != codes.OK {
+				if status.Code(err) != tt.wantCode {
+					t.Fatalf("got error %v, want code %s", err, tt.wantCode)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if resp.GetResult() != tt.result || resp.GetInvalidReason() != tt.reason {
+				t.Errorf("got %s (%s), want %s (%s)", resp.GetResult(), resp.GetInvalidReason(), tt.result, tt.reason)
+			}
+
+			if resp.GetSubject().GetSubjectId() != tt.subject {
+				t.Errorf("got subject %q, want %q", resp.GetSubject().GetSubjectId(), tt.subject)
+			}
+		})
+	}
+}
+
+func TestUnknownKeyID(t *testing.T) {
+	key := newTestKey(t, "key-1")
+	issuer := newTestIssuerServer(t, key)
+	srv := newTestServer(t, issuer)
+
+	resp := validate(t, srv, &authentication.ValidateCredentialRequest{
+		Credential: signToken(t, key, testClaims()),
+	})
+
+	if resp.GetResult() != authentication.ValidateCredentialResponse_RESULT_VALID {
+		t.Fatalf("got %s (%s), want valid", resp.GetResult(), resp.GetInvalidReason())
+	}
+
+	// A token signed with an unknown key is rejected using the cached keys, without refetching
+	// the key set so soon after it was loaded, even when the issuer is unavailable.
+	issuer.down.Store(true)
+
+	unknown := signToken(t, newTestKey(t, "key-2"), testClaims())
+
+	for range 3 {
+		resp := validate(t, srv, &authentication.ValidateCredentialRequest{
+			Credential: unknown,
+		})
+
+		if resp.GetInvalidReason() != authentication.ValidateCredentialResponse_INVALID_REASON_BAD_SIGNATURE {
+			t.Fatalf("got %s (%s), want bad signature", resp.GetResult(), resp.GetInvalidReason())
+		}
+	}
+
+	if got := issuer.requests.Load(); got != 1 {
+		t.Errorf("got %d JWKS requests, want 1", got)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldKey := newTestKey(t, "key-1")
+	issuer := newTestIssuerServer(t, oldKey)
+	srv := newTestServer(t, issuer)
+
+	if err := srv.Ready(context.Background()); err != nil {
+		t.Fatalf("unexpected error loading keys: %v", err)
+	}
+
+	newKey := newTestKey(t, "key-2")
+	issuer.keys = []jose.JSONWebKey{oldKey, newKey}
+
+	// Pretend the key set was loaded long enough ago to be refreshed for an unknown key.
+	srv.issuers[testIssuer].attemptedAt = time.Now().Add(-minRefreshInterval)
+
+	resp := validate(t, srv, &authentication.ValidateCredentialRequest{
+		Credential: signToken(t, newKey, testClaims()),
+	})
+
+	if resp.GetResult() != authentication.ValidateCredentialResponse_RESULT_VALID {
+		t.Fatalf("got %s (%s), want valid", resp.GetResult(), resp.GetInvalidReason())
+	}
+
+	if got := issuer.requests.Load(); got != 2 {
+		t.Errorf("got %d JWKS requests, want 2", got)
+	}
+}
+
+func TestIssuerUnavailable(t *testing.T) {
+	key := newTestKey(t, "key-1")
+	issuer := newTestIssuerServer(t, key)
+	issuer.down.Store(true)
+
+	srv := newTestServer(t, issuer)
+	token := signToken(t, key, testClaims())
+
+	// Failed loads are not retried on every request.
+	for range 3 {
+		_, err := srv.ValidateCredential(context.Background(), &authentication.ValidateCredentialRequest{
+			Credential: token,
+		})
+
+		if status.Code(err) != codes.Unavailable {
+			t.Fatalf("got error %v, want code %s", err, codes.Unavailable)
+		}
+	}
+
+	if got := issuer.requests.Load(); got != 1 {
+		t.Errorf("got %d JWKS requests, want 1", got)
+	}
+
+	if err := srv.Ready(context.Background()); err == nil {
+		t.Error("expected readiness error while issuer is unavailable")
+	}
+
+	// Once the backoff has passed, the key set is loaded again.
+	issuer.down.Store(false)
+	srv.issuers[testIssuer].attemptedAt = time.Now().Add(-minRefreshInterval)
+
+	resp := validate(t, srv, &authentication.ValidateCredentialRequest{
+		Credential: token,
+	})
+
+	if resp.GetResult() != authentication.ValidateCredentialResponse_RESULT_VALID {
+		t.Fatalf("got %s (%s), want valid", resp.GetResult(), resp.GetInvalidReason())
+	}
+}
+
+func TestStaleKeysRemainInUse(t *testing.T) {
+	key := newTestKey(t, "key-1")
+	issuer := newTestIssuerServer(t, key)
+	srv := newTestServer(t, issuer)
+
+	if err := srv.Ready(context.Background()); err != nil {
+		t.Fatalf("unexpected error loading keys: %v", err)
+	}
+
+	issuer.down.Store(true)
+
+	keys := srv.issuers[testIssuer]
+	keys.fetchedAt = time.Now().Add(-DefaultRefreshInterval)
+	keys.attemptedAt = keys.fetchedAt
+
+	token := signToken(t, key, testClaims())
+
+	for range 3 {
+		resp := validate(t, srv, &authentication.ValidateCredentialRequest{
+			Credential: token,
+		})
+
+		if resp.GetResult() != authentication.ValidateCredentialResponse_RESULT_VALID {
+			t.Fatalf("got %s (%s), want valid", resp.GetResult(), resp.GetInvalidReason())
+		}
+	}
+
+	if got := issuer.requests.Load(); got != 2 {
+		t.Errorf("got %d JWKS requests, want 2", got)
+	}
+}
+
+func TestGetTrustBundle(t *testing.T) {
+	key := newTestKey(t, "key-1")
+	issuer := newTestIssuerServer(t, key)
+	srv := newTestServer(t, issuer)
+
+	resp, err := srv.GetTrustBundle(context.Background(), &authentication.GetTrustBundleRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	bundle := resp.GetTrustBundle()
+
+	var jwks jose.JSONWebKeySet
+	if err := json.Unmarshal(bundle.GetJwks(), &jwks); err != nil {
+		t.Fatalf("error parsing trust bundle: %v", err)
+	}
+
+	if len(jwks.Keys) != 1 || jwks.Keys[0].KeyID != "key-1" || !jwks.Keys[0].IsPublic() {
+		t.Errorf("got keys %+v, want the public key-1", jwks.Keys)
+	}
+
+	if len(bundle.GetIssuers()) != 1 || bundle.GetIssuers()[0] != testIssuer {
+		t.Errorf("got issuers %v, want [%s]", bundle.GetIssuers(), testIssuer)
+	}
+
+	again, err := srv.GetTrustBundle(context.Background(), &authentication.GetTrustBundleRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if again.GetTrustBundle().GetVersion() != bundle.GetVersion() {
+		t.Errorf("version changed from %s to %s without a key change", bundle.GetVersion(), again.GetTrustBundle().GetVersion())
+	}
+}
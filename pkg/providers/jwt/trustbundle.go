@@ -0,0 +1,126 @@
+package jwt
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// trustBundlePollInterval is the interval at which WatchTrustBundle checks the key sets of issuers
+// for changes.
+const trustBundlePollInterval = minRefreshInterval
+
+// GetTrustBundle returns the public signing keys of every trusted issuer.
+func (s *Server) GetTrustBundle(ctx context.Context, req *authentication.GetTrustBundleRequest) (*authentication.GetTrustBundleResponse, error) {
+	bundle, err := s.trustBundle(ctx)
+	if err != nil {
+		log.Printf("error building trust bundle: %v", err)
+
+		return nil, status.Error(codes.Internal, "error loading issuer keys")
+	}
+
+	out := &authentication.GetTrustBundleResponse{
+		TrustBundle: bundle,
+	}
+
+	return out, nil
+}
+
+// WatchTrustBundle sends the trust bundle to the client, unless it already has the current
+// version, and then sends it again each time the key set of any issuer changes.
+func (s *Server) WatchTrustBundle(req *authentication.WatchTrustBundleRequest, stream authentication.Authentication_WatchTrustBundleServer) error {
+	ctx := stream.Context()
+
+	bundle, err := s.trustBundle(ctx)
+	if err != nil {
+		log.Printf("error building trust bundle: %v", err)
+
+		return status.Error(codes.Internal, "error loading issuer keys")
+	}
+
+	version := req.GetVersion()
+
+	ticker := time.NewTicker(trustBundlePollInterval)
+	defer ticker.Stop()
+
+	for {
+		if bundle.GetVersion() != version {
+			resp := &authentication.WatchTrustBundleResponse{
+				TrustBundle: bundle,
+			}
+
+			if err := stream.Send(resp); err != nil {
+				return err
+			}
+
+			version = bundle.GetVersion()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		next, err := s.trustBundle(ctx)
+		if err != nil {
+			// Keys which cannot be refreshed remain in use, so the error is only reached before any
+			// keys were loaded, and the last bundle sent remains the best available.
+			log.Printf("error building trust bundle: %v", err)
+
+			continue
+		}
+
+		bundle = next
+	}
+}
+
+// trustBundle returns a trust bundle containing the public signing keys of every issuer. Its
+// version is derived from the keys it contains, such that it changes only when the keys do.
+func (s *Server) trustBundle(ctx context.Context) (*authentication.TrustBundle, error) {
+	var jwks jose.JSONWebKeySet
+
+	for _, iss := range s.issuerNames {
+		keys, err := s.issuers[iss].getKeys(ctx, "")
+		if err != nil {
+			return nil, err
+		}
+
+		for _, key := range keys {
+			if key.Use != "" && key.Use != "sig" {
+				continue
+			}
+
+			// Symmetric keys have no public form and cannot be shared with clients.
+			pub := key.Public()
+			if !pub.Valid() {
+				continue
+			}
+
+			jwks.Keys = append(jwks.Keys, pub)
+		}
+	}
+
+	b, err := json.Marshal(jwks)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(b)
+
+	out := &authentication.TrustBundle{
+		Version: hex.EncodeToString(sum[:]),
+		Jwks:    b,
+		Issuers: s.issuerNames,
+	}
+
+	return out, nil
+}
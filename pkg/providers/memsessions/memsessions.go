@@ -0,0 +1,442 @@
+// Package memsessions provides a Sessions service implementation which keeps sessions in memory,
+// such that every session ends when the runtime stops.
+//
+// Sessions are created from credentials validated by another Authentication server. Session tokens
+// are random, and only their hashes are kept. The server also validates session tokens as
+// credentials of type CREDENTIAL_TYPE_SESSION through the Authentication server returned by
+// Authentication, which is meant to be combined with the server sessions are created with, such as
+// in a chain.
+package memsessions
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/sessions"
+	"github.com/metal-toolbox/iam-runtime/pkg/revocation"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// DefaultTTL is the amount of time a session remains valid after it is created or refreshed if
+	// no TTL is configured.
+	DefaultTTL = time.Hour
+	// DefaultMaxLifetime is the maximum amount of time a session remains valid after it is created,
+	// regardless of refreshes, if no maximum is configured.
+	DefaultMaxLifetime = 24 * time.Hour
+)
+
+// Config represents the configuration for an in-memory Sessions server.
+type Config struct {
+	// TTL is the amount of time a session remains valid after it is created or refreshed.
+	TTL time.Duration `yaml:"ttl"`
+	// MaxLifetime is the maximum amount of time a session remains valid after it is created,
+	// regardless of refreshes.
+	MaxLifetime time.Duration `yaml:"max_lifetime"`
+	// Revocations is the store of revoked credentials, if any. Sessions are matched against
+	// revocations by their session ID, subject ID, and creation time.
+	Revocations *revocation.Store `yaml:"-"`
+}
+
+// session is a session along with the subject it was created for.
+type session struct {
+	id          string
+	tokenHash   string
+	subject     *authentication.Subject
+	issuer      string
+	methods     []string
+	assurance   string
+	createdAt   time.Time
+	refreshedAt time.Time
+	expiresAt   time.Time
+	ended       bool
+	attributes  map[string]string
+}
+
+// Server is a Sessions server which keeps sessions in memory. Credentials are validated using an
+// Authentication server when sessions are created.
+type Server struct {
+	sessions.UnimplementedSessionsServer
+
+	authn       authentication.AuthenticationServer
+	ttl         time.Duration
+	maxLifetime time.Duration
+	revocations *revocation.Store
+	now         func() time.Time
+
+	mu        sync.Mutex
+	byID      map[string]*session
+	byToken   map[string]*session
+	lastPrune time.Time
+}
+
+// NewServer creates a new Server using the given config, validating the credentials sessions are
+// created from with authn.
+func NewServer(cfg Config, authn authentication.AuthenticationServer) (*Server, error) {
+	if authn == nil {
+		return nil, errors.New("authentication server is required")
+	}
+
+	ttl := cfg.TTL
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	maxLifetime := cfg.MaxLifetime
+	if maxLifetime <= 0 {
+		maxLifetime = DefaultMaxLifetime
+	}
+
+	if maxLifetime < ttl {
+		return nil, errors.New("max_lifetime must not be less than ttl")
+	}
+
+	out := &Server{
+		authn:       authn,
+		ttl:         ttl,
+		maxLifetime: maxLifetime,
+		revocations: cfg.Revocations,
+		now:         time.Now,
+		byID:        make(map[string]*session),
+		byToken:     make(map[string]*session),
+	}
+
+	return out, nil
+}
+
+// CreateSession validates the given credential and creates a session for its subject.
+func (s *Server) CreateSession(ctx context.Context, req *sessions.CreateSessionRequest) (*sessions.CreateSessionResponse, error) {
+	if req.GetCredentialType() == authentication.CredentialType_CREDENTIAL_TYPE_SESSION {
+		return nil, status.Error(codes.InvalidArgument, "sessions cannot be created from session tokens")
+	}
+
+	validateReq := &authentication.ValidateCredentialRequest{
+		Credential:     req.GetCredential(),
+		CredentialType: req.GetCredentialType(),
+	}
+
+	validateResp, err := s.authn.ValidateCredential(ctx, validateReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if validateResp.GetResult() != authentication.ValidateCredentialResponse_RESULT_VALID {
+		return nil, status.Error(codes.Unauthenticated, "invalid credential")
+	}
+
+	id, err := randomString(16)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "error creating session")
+	}
+
+	token, err := randomString(32)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "error creating session")
+	}
+
+	now := s.now()
+
+	sess := &session{
+		id:         id,
+		tokenHash:  hashToken(token),
+		subject:    validateResp.GetSubject(),
+		issuer:     validateResp.GetIssuer(),
+		methods:    validateResp.GetAuthenticationMethods(),
+		assurance:  validateResp.GetAssuranceLevel(),
+		createdAt:  now,
+		expiresAt:  now.Add(s.ttl),
+		attributes: req.GetAttributes(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune(now)
+
+	s.byID[sess.id] = sess
+	s.byToken[sess.tokenHash] = sess
+
+	out := &sessions.CreateSessionResponse{
+		Session: sess.proto(),
+		Token:   token,
+	}
+
+	return out, nil
+}
+
+// RefreshSession extends the expiry of the session with the given token, up to the session's
+// maximum lifetime, and rotates its token.
+func (s *Server) RefreshSession(ctx context.Context, req *sessions.RefreshSessionRequest) (*sessions.RefreshSessionResponse, error) {
+	token, err := randomString(32)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "error refreshing session")
+	}
+
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune(now)
+
+	sess, reason := s.lookup(req.GetToken(), now)
+	if sess == nil {
+		return nil, status.Errorf(codes.Unauthenticated, "session %s", reason)
+	}
+
+	delete(s.byToken, sess.tokenHash)
+
+	sess.tokenHash = hashToken(token)
+	sess.refreshedAt = now
+	sess.expiresAt = now.Add(s.ttl)
+
+	if limit := sess.createdAt.Add(s.maxLifetime); sess.expiresAt.After(limit) {
+		sess.expiresAt = limit
+	}
+
+	s.byToken[sess.tokenHash] = sess
+
+	out := &sessions.RefreshSessionResponse{
+		Session: sess.proto(),
+		Token:   token,
+	}
+
+	return out, nil
+}
+
+// EndSession ends the session with the given token or ID.
+func (s *Server) EndSession(ctx context.Context, req *sessions.EndSessionRequest) (*sessions.EndSessionResponse, error) {
+	if (req.GetToken() == "") == (req.GetSessionId() == "") {
+		return nil, status.Error(codes.InvalidArgument, "exactly one of token and session_id is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.byID[req.GetSessionId()]
+	if req.GetToken() != "" {
+		sess, ok = s.byToken[hashToken(req.GetToken())]
+	}
+
+	// Ended sessions are kept until they would have expired, such that their tokens are reported as
+	// revoked rather than unknown.
+	if ok {
+		sess.ended = true
+	}
+
+	return &sessions.EndSessionResponse{}, nil
+}
+
+// ListSessions lists the active sessions of the given subject, in order of creation.
+func (s *Server) ListSessions(ctx context.Context, req *sessions.ListSessionsRequest) (*sessions.ListSessionsResponse, error) {
+	if req.GetSubjectId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "subject_id is required")
+	}
+
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var active []*session
+
+	for _, sess := range s.byID {
+		if sess.subject.GetSubjectId() == req.GetSubjectId() && s.invalidReason(sess, now) == authentication.ValidateCredentialResponse_INVALID_REASON_UNSPECIFIED {
+			active = append(active, sess)
+		}
+	}
+
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].createdAt.Before(active[j].createdAt)
+	})
+
+	out := &sessions.ListSessionsResponse{}
+
+	for _, sess := range active {
+		out.Sessions = append(out.Sessions, sess.proto())
+	}
+
+	return out, nil
+}
+
+// Authentication returns an Authentication server which validates the server's session tokens.
+func (s *Server) Authentication() authentication.AuthenticationServer {
+	return &authenticationServer{
+		sessions: s,
+	}
+}
+
+// authenticationServer is an Authentication server which validates session tokens.
+type authenticationServer struct {
+	authentication.UnimplementedAuthenticationServer
+
+	sessions *Server
+}
+
+// RPCs returns the names of the Authentication RPCs the server implements.
+func (a *authenticationServer) RPCs() []string {
+	return []string{"ValidateCredential"}
+}
+
+// CredentialTypes returns the types of credential the server accepts.
+func (a *authenticationServer) CredentialTypes() []authentication.CredentialType {
+	return []authentication.CredentialType{authentication.CredentialType_CREDENTIAL_TYPE_SESSION}
+}
+
+// ValidateCredential returns the subject of the session with the given token while it is active.
+func (a *authenticationServer) ValidateCredential(ctx context.Context, req *authentication.ValidateCredentialRequest) (*authentication.ValidateCredentialResponse, error) {
+	switch req.GetCredentialType() {
+	case authentication.CredentialType_CREDENTIAL_TYPE_UNSPECIFIED, authentication.CredentialType_CREDENTIAL_TYPE_SESSION:
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported credential type %s", req.GetCredentialType())
+	}
+
+	// Sessions have no audience of their own, so requests for a specific audience cannot be
+	// satisfied.
+	if req.GetAudience() != "" {
+		return invalid(authentication.ValidateCredentialResponse_INVALID_REASON_AUDIENCE_MISMATCH), nil
+	}
+
+	s := a.sessions
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune(now)
+
+	sess, ok := s.byToken[hashToken(req.GetCredential())]
+	if !ok {
+		return invalid(authentication.ValidateCredentialResponse_INVALID_REASON_UNKNOWN_CREDENTIAL), nil
+	}
+
+	if reason := s.invalidReason(sess, now); reason != authentication.ValidateCredentialResponse_INVALID_REASON_UNSPECIFIED {
+		return invalid(reason), nil
+	}
+
+	out := &authentication.ValidateCredentialResponse{
+		Result:                authentication.ValidateCredentialResponse_RESULT_VALID,
+		Subject:               sess.subject,
+		ExpiresAt:             timestamppb.New(sess.expiresAt),
+		Issuer:                sess.issuer,
+		AuthenticationMethods: sess.methods,
+		AssuranceLevel:        sess.assurance,
+	}
+
+	return out, nil
+}
+
+// lookup returns the active session with the given token, or a description of why there is none.
+// It must be called with mu held.
+func (s *Server) lookup(token string, now time.Time) (*session, string) {
+	sess, ok := s.byToken[hashToken(token)]
+	if !ok {
+		return nil, "not found"
+	}
+
+	switch s.invalidReason(sess, now) {
+	case authentication.ValidateCredentialResponse_INVALID_REASON_UNSPECIFIED:
+		return sess, ""
+	case authentication.ValidateCredentialResponse_INVALID_REASON_EXPIRED:
+		return nil, "expired"
+	default:
+		return nil, "ended"
+	}
+}
+
+// invalidReason returns the reason the given session is not active, if any. Sessions which were
+// ended or revoked are reported as revoked.
+func (s *Server) invalidReason(sess *session, now time.Time) authentication.ValidateCredentialResponse_InvalidReason {
+	if !now.Before(sess.expiresAt) {
+		return authentication.ValidateCredentialResponse_INVALID_REASON_EXPIRED
+	}
+
+	if sess.ended {
+		return authentication.ValidateCredentialResponse_INVALID_REASON_REVOKED
+	}
+
+	if s.revocations != nil {
+		cred := revocation.Credential{
+			TokenID:   sess.id,
+			SubjectID: sess.subject.GetSubjectId(),
+			IssuedAt:  sess.createdAt,
+		}
+
+		if _, revoked := s.revocations.IsRevoked(cred); revoked {
+			return authentication.ValidateCredentialResponse_INVALID_REASON_REVOKED
+		}
+	}
+
+	return authentication.ValidateCredentialResponse_INVALID_REASON_UNSPECIFIED
+}
+
+// prune removes expired sessions, including ended sessions once they would have expired, at most
+// once per TTL. It is called by each RPC which looks up sessions, so that sessions are freed
+// regardless of which RPCs a runtime mostly serves. It must be called with mu held.
+func (s *Server) prune(now time.Time) {
+	if now.Sub(s.lastPrune) < s.ttl {
+		return
+	}
+
+	s.lastPrune = now
+
+	for _, sess := range s.byID {
+		if !now.Before(sess.expiresAt) {
+			s.remove(sess)
+		}
+	}
+}
+
+// remove removes the given session. It must be called with mu held.
+func (s *Server) remove(sess *session) {
+	delete(s.byID, sess.id)
+	delete(s.byToken, sess.tokenHash)
+}
+
+func (sess *session) proto() *sessions.Session {
+	out := &sessions.Session{
+		SessionId:  sess.id,
+		SubjectId:  sess.subject.GetSubjectId(),
+		CreatedAt:  timestamppb.New(sess.createdAt),
+		ExpiresAt:  timestamppb.New(sess.expiresAt),
+		Attributes: sess.attributes,
+	}
+
+	if !sess.refreshedAt.IsZero() {
+		out.RefreshedAt = timestamppb.New(sess.refreshedAt)
+	}
+
+	return out
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func invalid(reason authentication.ValidateCredentialResponse_InvalidReason) *authentication.ValidateCredentialResponse {
+	return &authentication.ValidateCredentialResponse{
+		Result:        authentication.ValidateCredentialResponse_RESULT_INVALID,
+		InvalidReason: reason,
+	}
+}
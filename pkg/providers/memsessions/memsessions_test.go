@@ -0,0 +1,440 @@
+package memsessions
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/sessions"
+	"github.com/metal-toolbox/iam-runtime/pkg/providers/static"
+	"github.com/metal-toolbox/iam-runtime/pkg/revocation"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	testTTL         = time.Hour
+	testMaxLifetime = 3 * time.Hour
+)
+
+// testClock is a clock which only moves when advanced.
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestServer(t *testing.T, revocations *revocation.Store) (*Server, *testClock) {
+	t.Helper()
+
+	authn, err := static.NewAuthenticationServer(static.AuthenticationConfig{
+		Credentials: []static.Credential{
+			{Credential: "alice-key", SubjectID: "alice"},
+			{Credential: "bob-key", SubjectID: "bob"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("error creating authentication server: %v", err)
+	}
+
+	srv, err := NewServer(Config{
+		TTL:         testTTL,
+		MaxLifetime: testMaxLifetime,
+		Revocations: revocations,
+	}, authn)
+	if err != nil {
+		t.Fatalf("error creating server: %v", err)
+	}
+
+	clock := &testClock{now: time.Now()}
+	srv.now = clock.Now
+
+	return srv, clock
+}
+
+func createSession(t *testing.T, srv *Server, credential string) *sessions.CreateSessionResponse {
+	t.Helper()
+
+	req := &sessions.CreateSessionRequest{
+		Credential: credential,
+		Attributes: map[string]string{"user_agent": "test"},
+	}
+
+	resp, err := srv.CreateSession(context.Background(), req)
+	if err != nil {
+		t.Fatalf("error creating session: %v", err)
+	}
+
+	return resp
+}
+
+func refreshSession(t *testing.T, srv *Server, token string) *sessions.RefreshSessionResponse {
+	t.Helper()
+
+	resp, err := srv.RefreshSession(context.Background(), &sessions.RefreshSessionRequest{Token: token})
+	if err != nil {
+		t.Fatalf("error refreshing session: %v", err)
+	}
+
+	return resp
+}
+
+// assertToken checks that validating token gives the given reason, or that it is valid if reason
+// is unspecified.
+func assertToken(t *testing.T, srv *Server, token string, want authentication.ValidateCredentialResponse_InvalidReason) {
+	t.Helper()
+
+	req := &authentication.ValidateCredentialRequest{
+		Credential: token,
+	}
+
+	resp, err := srv.Authentication().ValidateCredential(context.Background(), req)
+	if err != nil {
+		t.Fatalf("error validating token: %v", err)
+	}
+
+	if want == authentication.ValidateCredentialResponse_INVALID_REASON_UNSPECIFIED {
+		if resp.GetResult() != authentication.ValidateCredentialResponse_RESULT_VALID {
+			t.Errorf("got result %s, reason %s, want valid", resp.GetResult(), resp.GetInvalidReason())
+		}
+
+		return
+	}
+
+	if resp.GetResult() != authentication.ValidateCredentialResponse_RESULT_INVALID || resp.GetInvalidReason() != want {
+		t.Errorf("got result %s, reason %s, want invalid with reason %s", resp.GetResult(), resp.GetInvalidReason(), want)
+	}
+}
+
+func TestCreateSession(t *testing.T) {
+	srv, clock := newTestServer(t, nil)
+
+	resp := createSession(t, srv, "alice-key")
+
+	sess := resp.GetSession()
+	if sess.GetSubjectId() != "alice" || sess.GetSessionId() == "" || resp.GetToken() == "" {
+		t.Fatalf("got session %v with token %q, want session for alice with token", sess, resp.GetToken())
+	}
+
+	if got, want := sess.GetExpiresAt().AsTime(), clock.now.Add(testTTL); !got.Equal(want) {
+		t.Errorf("got expiry %s, want %s", got, want)
+	}
+
+	if sess.GetAttributes()["user_agent"] != "test" {
+		t.Errorf("got attributes %v, want user_agent", sess.GetAttributes())
+	}
+
+	validateResp, err := srv.Authentication().ValidateCredential(context.Background(), &authentication.ValidateCredentialRequest{
+		Credential:     resp.GetToken(),
+		CredentialType: authentication.CredentialType_CREDENTIAL_TYPE_SESSION,
+	})
+	if err != nil {
+		t.Fatalf("error validating token: %v", err)
+	}
+
+	if validateResp.GetSubject().GetSubjectId() != "alice" {
+		t.Errorf("got subject %v, want alice", validateResp.GetSubject())
+	}
+
+	tests := []struct {
+		name     string
+		req      *sessions.CreateSessionRequest
+		wantCode codes.Code
+	}{
+		{"invalid credential", &sessions.CreateSessionRequest{Credential: "mallory-key"}, codes.Unauthenticated},
+		{"session token", &sessions.CreateSessionRequest{Credential: resp.GetToken(), CredentialType: authentication.CredentialType_CREDENTIAL_TYPE_SESSION}, codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := srv.CreateSession(context.Background(), tt.req); status.Code(err) != tt.wantCode {
+				t.Errorf("got error %v, want %s", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestValidateCredential(t *testing.T) {
+	srv, clock := newTestServer(t, nil)
+
+	// Sessions are pruned when first created, and then once per TTL, so the session is created
+	// after a prune in order that it is seen to expire before the next.
+	createSession(t, srv, "bob-key")
+	clock.advance(testTTL / 2)
+
+	token := createSession(t, srv, "alice-key").GetToken()
+
+	assertToken(t, srv, token, authentication.ValidateCredentialResponse_INVALID_REASON_UNSPECIFIED)
+	assertToken(t, srv, "unknown", authentication.ValidateCredentialResponse_INVALID_REASON_UNKNOWN_CREDENTIAL)
+
+	resp, err := srv.Authentication().ValidateCredential(context.Background(), &authentication.ValidateCredentialRequest{
+		Credential: token,
+		Audience:   "api",
+	})
+	if err != nil || resp.GetInvalidReason() != authentication.ValidateCredentialResponse_INVALID_REASON_AUDIENCE_MISMATCH {
+		t.Errorf("got reason %s, error %v with audience, want %s", resp.GetInvalidReason(), err,
+			authentication.ValidateCredentialResponse_INVALID_REASON_AUDIENCE_MISMATCH)
+	}
+
+	_, err = srv.Authentication().ValidateCredential(context.Background(), &authentication.ValidateCredentialRequest{
+		Credential:     token,
+		CredentialType: authentication.CredentialType_CREDENTIAL_TYPE_JWT,
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("got error %v for JWT credential type, want %s", err, codes.InvalidArgument)
+	}
+
+	clock.advance(testTTL / 2)
+
+	assertToken(t, srv, token, authentication.ValidateCredentialResponse_INVALID_REASON_UNSPECIFIED)
+
+	clock.advance(testTTL / 2)
+
+	assertToken(t, srv, token, authentication.ValidateCredentialResponse_INVALID_REASON_EXPIRED)
+}
+
+func TestRefreshSession(t *testing.T) {
+	srv, clock := newTestServer(t, nil)
+
+	created := createSession(t, srv, "alice-key")
+	createdAt := clock.now
+
+	clock.advance(testTTL / 2)
+
+	refreshed := refreshSession(t, srv, created.GetToken())
+
+	if refreshed.GetSession().GetSessionId() != created.GetSession().GetSessionId() {
+		t.Errorf("got session %s after refreshing, want %s", refreshed.GetSession().GetSessionId(), created.GetSession().GetSessionId())
+	}
+
+	if refreshed.GetToken() == created.GetToken() {
+		t.Error("token not rotated")
+	}
+
+	if got, want := refreshed.GetSession().GetExpiresAt().AsTime(), clock.now.Add(testTTL); !got.Equal(want) {
+		t.Errorf("got expiry %s after refreshing, want %s", got, want)
+	}
+
+	if !refreshed.GetSession().GetRefreshedAt().AsTime().Equal(clock.now) {
+		t.Errorf("got refresh time %s, want %s", refreshed.GetSession().GetRefreshedAt().AsTime(), clock.now)
+	}
+
+	// The previous token is replaced, and cannot be used again.
+	assertToken(t, srv, created.GetToken(), authentication.ValidateCredentialResponse_INVALID_REASON_UNKNOWN_CREDENTIAL)
+	assertToken(t, srv, refreshed.GetToken(), authentication.ValidateCredentialResponse_INVALID_REASON_UNSPECIFIED)
+
+	if _, err := srv.RefreshSession(context.Background(), &sessions.RefreshSessionRequest{Token: created.GetToken()}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("got error %v refreshing with previous token, want %s", err, codes.Unauthenticated)
+	}
+
+	// Refreshes do not extend a session beyond its maximum lifetime.
+	token := refreshed.GetToken()
+
+	for i := 0; i < 3; i++ {
+		clock.advance(testTTL / 2)
+
+		token = refreshSession(t, srv, token).GetToken()
+	}
+
+	clock.advance(testTTL / 2)
+
+	capped := refreshSession(t, srv, token)
+	if got, want := capped.GetSession().GetExpiresAt().AsTime(), createdAt.Add(testMaxLifetime); !got.Equal(want) {
+		t.Errorf("got expiry %s near maximum lifetime, want %s", got, want)
+	}
+
+	clock.advance(testTTL / 2)
+
+	if _, err := srv.RefreshSession(context.Background(), &sessions.RefreshSessionRequest{Token: capped.GetToken()}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("got error %v refreshing expired session, want %s", err, codes.Unauthenticated)
+	}
+}
+
+func TestEndSession(t *testing.T) {
+	srv, _ := newTestServer(t, nil)
+
+	byToken := createSession(t, srv, "alice-key")
+	byID := createSession(t, srv, "alice-key")
+	other := createSession(t, srv, "alice-key")
+
+	if _, err := srv.EndSession(context.Background(), &sessions.EndSessionRequest{Token: byToken.GetToken()}); err != nil {
+		t.Fatalf("error ending session by token: %v", err)
+	}
+
+	if _, err := srv.EndSession(context.Background(), &sessions.EndSessionRequest{SessionId: byID.GetSession().GetSessionId()}); err != nil {
+		t.Fatalf("error ending session by ID: %v", err)
+	}
+
+	assertToken(t, srv, byToken.GetToken(), authentication.ValidateCredentialResponse_INVALID_REASON_REVOKED)
+	assertToken(t, srv, byID.GetToken(), authentication.ValidateCredentialResponse_INVALID_REASON_REVOKED)
+	assertToken(t, srv, other.GetToken(), authentication.ValidateCredentialResponse_INVALID_REASON_UNSPECIFIED)
+
+	if _, err := srv.RefreshSession(context.Background(), &sessions.RefreshSessionRequest{Token: byToken.GetToken()}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("got error %v refreshing ended session, want %s", err, codes.Unauthenticated)
+	}
+
+	// Ending a session which does not exist succeeds.
+	if _, err := srv.EndSession(context.Background(), &sessions.EndSessionRequest{SessionId: "unknown"}); err != nil {
+		t.Errorf("error ending unknown session: %v", err)
+	}
+
+	for _, req := range []*sessions.EndSessionRequest{
+		{},
+		{Token: other.GetToken(), SessionId: other.GetSession().GetSessionId()},
+	} {
+		if _, err := srv.EndSession(context.Background(), req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("got error %v ending session with %v, want %s", err, req, codes.InvalidArgument)
+		}
+	}
+}
+
+func TestListSessions(t *testing.T) {
+	srv, clock := newTestServer(t, nil)
+
+	createSession(t, srv, "bob-key")
+	clock.advance(testTTL / 2)
+
+	expired := createSession(t, srv, "alice-key")
+
+	clock.advance(testTTL / 2)
+
+	first := createSession(t, srv, "alice-key")
+
+	clock.advance(time.Minute)
+
+	ended := createSession(t, srv, "alice-key")
+	createSession(t, srv, "bob-key")
+
+	clock.advance(time.Minute)
+
+	second := createSession(t, srv, "alice-key")
+
+	if _, err := srv.EndSession(context.Background(), &sessions.EndSessionRequest{Token: ended.GetToken()}); err != nil {
+		t.Fatalf("error ending session: %v", err)
+	}
+
+	clock.advance(testTTL/2 - 2*time.Minute)
+
+	assertToken(t, srv, expired.GetToken(), authentication.ValidateCredentialResponse_INVALID_REASON_EXPIRED)
+
+	resp, err := srv.ListSessions(context.Background(), &sessions.ListSessionsRequest{SubjectId: "alice"})
+	if err != nil {
+		t.Fatalf("error listing sessions: %v", err)
+	}
+
+	want := []string{first.GetSession().GetSessionId(), second.GetSession().GetSessionId()}
+
+	var got []string
+	for _, sess := range resp.GetSessions() {
+		got = append(got, sess.GetSessionId())
+	}
+
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("got sessions %v, want %v", got, want)
+	}
+
+	if _, err := srv.ListSessions(context.Background(), &sessions.ListSessionsRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("got error %v without subject, want %s", err, codes.InvalidArgument)
+	}
+}
+
+func TestRevocation(t *testing.T) {
+	revocations, err := revocation.NewFileStore(filepath.Join(t.TempDir(), "revocations.json"), 0)
+	if err != nil {
+		t.Fatalf("error creating revocation store: %v", err)
+	}
+
+	srv, clock := newTestServer(t, revocations)
+
+	byID := createSession(t, srv, "alice-key")
+	before := createSession(t, srv, "alice-key")
+	bob := createSession(t, srv, "bob-key")
+
+	clock.advance(time.Minute)
+
+	revokedAt := clock.now
+
+	clock.advance(time.Minute)
+
+	after := createSession(t, srv, "alice-key")
+
+	for _, r := range []revocation.Revocation{
+		{TokenID: byID.GetSession().GetSessionId()},
+		{SubjectID: "alice", IssuedBefore: revokedAt},
+	} {
+		if err := revocations.Revoke(r); err != nil {
+			t.Fatalf("error revoking: %v", err)
+		}
+	}
+
+	assertToken(t, srv, byID.GetToken(), authentication.ValidateCredentialResponse_INVALID_REASON_REVOKED)
+	assertToken(t, srv, before.GetToken(), authentication.ValidateCredentialResponse_INVALID_REASON_REVOKED)
+	assertToken(t, srv, after.GetToken(), authentication.ValidateCredentialResponse_INVALID_REASON_UNSPECIFIED)
+	assertToken(t, srv, bob.GetToken(), authentication.ValidateCredentialResponse_INVALID_REASON_UNSPECIFIED)
+
+	if _, err := srv.RefreshSession(context.Background(), &sessions.RefreshSessionRequest{Token: before.GetToken()}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("got error %v refreshing revoked session, want %s", err, codes.Unauthenticated)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	tests := []struct {
+		name string
+		rpc  func(srv *Server, token string)
+	}{
+		{
+			name: "ValidateCredential",
+			rpc: func(srv *Server, token string) {
+				_, _ = srv.Authentication().ValidateCredential(context.Background(), &authentication.ValidateCredentialRequest{Credential: token})
+			},
+		},
+		{
+			name: "RefreshSession",
+			rpc: func(srv *Server, token string) {
+				_, _ = srv.RefreshSession(context.Background(), &sessions.RefreshSessionRequest{Token: token})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, clock := newTestServer(t, nil)
+
+			expired := createSession(t, srv, "alice-key")
+			ended := createSession(t, srv, "alice-key")
+
+			if _, err := srv.EndSession(context.Background(), &sessions.EndSessionRequest{Token: ended.GetToken()}); err != nil {
+				t.Fatalf("error ending session: %v", err)
+			}
+
+			clock.advance(testTTL / 2)
+
+			active := createSession(t, srv, "alice-key")
+
+			clock.advance(testTTL / 2)
+
+			tt.rpc(srv, active.GetToken())
+
+			srv.mu.Lock()
+			defer srv.mu.Unlock()
+
+			for _, resp := range []*sessions.CreateSessionResponse{expired, ended} {
+				if _, ok := srv.byID[resp.GetSession().GetSessionId()]; ok {
+					t.Errorf("session %s not pruned", resp.GetSession().GetSessionId())
+				}
+			}
+
+			if len(srv.byID) != 1 || len(srv.byToken) != 1 {
+				t.Errorf("got %d sessions and %d tokens, want 1 of each", len(srv.byID), len(srv.byToken))
+			}
+		})
+	}
+}
@@ -0,0 +1,415 @@
+// Package policy provides an Authorization service implementation which makes access decisions by
+// evaluating attribute-based policies written in CEL (https://cel.dev).
+//
+// Policies are loaded from YAML files, each of which lists rules with a condition evaluated once
+// per requested action:
+//
+//	rules:
+//	  - name: admins
+//	    effect: allow
+//	    condition: subject.claims.groups.exists(g, g == "admins")
+//	  - name: readers
+//	    effect: allow
+//	    condition: action.startsWith("read_") && "reader" in subject.claims.roles
+//	  - name: frozen-tenant
+//	    effect: deny
+//	    condition: resource_id in subject.claims.frozen_tenants
+//
+// Each condition may refer to the following variables:
+//
+//   - subject: the Subject the credential identifies, with fields subject_id and claims, where
+//     claims are those returned by the Authentication service
+//   - action: the action being checked
+//   - resource_id: the ID of the resource the action is on
+//   - now: the time the request was received, as a timestamp
+//   - metadata: the gRPC metadata of the request, as a map from each lowercase key to its values
+//     joined with commas
+//
+// Conditions are compiled and type checked when policies are loaded, so that errors such as
+// referring to an unknown variable or field reject the policy file.
+//
+// An action is allowed if the condition of at least one allow rule holds and the condition of no
+// deny rule holds. A condition which cannot be evaluated, such as one referring to a claim the
+// subject does not have, does not hold for an allow rule but holds for a deny rule, such that
+// errors never grant access. Policy files are reloaded when they change; if the new policies are
+// not valid, the previously loaded policies remain in use.
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
+	authorization "github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization/v2"
+	"github.com/metal-toolbox/iam-runtime/pkg/providers/internal/celenv"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultReloadInterval is the minimum interval between checks of the policy files for changes if
+// no interval is configured.
+const DefaultReloadInterval = 5 * time.Second
+
+// DefaultCostLimit is the maximum cost of evaluating a single condition if no limit is configured.
+const DefaultCostLimit = 1_000_000
+
+// Effect is the effect a rule has on an action when its condition holds.
+type Effect string
+
+const (
+	// EffectAllow allows the action, unless a deny rule applies.
+	EffectAllow Effect = "allow"
+	// EffectDeny denies the action, regardless of any allow rules.
+	EffectDeny Effect = "deny"
+)
+
+// Config represents the configuration for a policy Authorization server.
+type Config struct {
+	// Files is the set of policy files to load. Each entry may be a path or a glob pattern, such as
+	// /etc/iam-runtime/policies/*.yaml.
+	Files []string `yaml:"files"`
+	// ReloadInterval is the minimum interval between checks of the policy files for changes.
+	ReloadInterval time.Duration `yaml:"reload_interval"`
+	// CostLimit is the maximum cost of evaluating a single condition.
+	CostLimit uint64 `yaml:"cost_limit"`
+}
+
+// Rule is a rule in a policy file.
+type Rule struct {
+	// Name identifies the rule in logs.
+	Name string `yaml:"name"`
+	// Effect is the effect of the rule when its condition holds.
+	Effect Effect `yaml:"effect"`
+	// Condition is a CEL expression which must evaluate to a bool.
+	Condition string `yaml:"condition"`
+}
+
+type policyFile struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+type rule struct {
+	name    string
+	program cel.Program
+}
+
+// policySet is the set of rules loaded from the policy files.
+type policySet struct {
+	allow []rule
+	deny  []rule
+}
+
+// Server is an Authorization server which makes access decisions by evaluating policies.
+// Credentials are validated using an Authentication server.
+type Server struct {
+	authorization.UnimplementedAuthorizationServer
+
+	authn          authentication.AuthenticationServer
+	env            *cel.Env
+	patterns       []string
+	reloadInterval time.Duration
+	costLimit      uint64
+
+	policies atomic.Pointer[policySet]
+
+	// reloadMu is held while checking the policy files for changes and reloading them. Requests
+	// do not wait for it, and use the loaded policies while another request reloads them.
+	reloadMu    sync.Mutex
+	fingerprint string
+	lastChecked time.Time
+}
+
+// NewServer creates a new Server using the given config, validating credentials with authn. The
+// policy files must exist and be valid.
+func NewServer(cfg Config, authn authentication.AuthenticationServer) (*Server, error) {
+	if len(cfg.Files) == 0 {
+		return nil, errors.New("files is required")
+	}
+
+	for _, pattern := range cfg.Files {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid file pattern %q: %w", pattern, err)
+		}
+	}
+
+	env, err := celenv.New()
+	if err != nil {
+		return nil, fmt.Errorf("error creating CEL environment: %w", err)
+	}
+
+	out := &Server{
+		authn:          authn,
+		env:            env,
+		patterns:       cfg.Files,
+		reloadInterval: cfg.ReloadInterval,
+		costLimit:      cfg.CostLimit,
+	}
+
+	if out.reloadInterval <= 0 {
+		out.reloadInterval = DefaultReloadInterval
+	}
+
+	if out.costLimit == 0 {
+		out.costLimit = DefaultCostLimit
+	}
+
+	files, fingerprint, err := out.stat()
+	if err != nil {
+		return nil, err
+	}
+
+	policies, err := out.load(files)
+	if err != nil {
+		return nil, err
+	}
+
+	out.policies.Store(policies)
+	out.fingerprint = fingerprint
+
+	return out, nil
+}
+
+// RPCs returns the names of the Authorization RPCs the server implements.
+func (s *Server) RPCs() []string {
+	return []string{"CheckAccess"}
+}
+
+// CheckAccess allows the request if the policies allow every requested action for the subject
+// identified by the given credential.
+func (s *Server) CheckAccess(ctx context.Context, req *authorization.CheckAccessRequest) (*authorization.CheckAccessResponse, error) {
+	if len(req.GetActions()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "actions are required")
+	}
+
+	now := time.Now()
+
+	validateReq := &authentication.ValidateCredentialRequest{
+		Credential: req.GetCredential(),
+	}
+
+	validateResp, err := s.authn.ValidateCredential(ctx, validateReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if validateResp.GetResult() != authentication.ValidateCredentialResponse_RESULT_VALID {
+		return nil, status.Error(codes.InvalidArgument, "invalid credential")
+	}
+
+	request := celenv.NewRequest(ctx, validateResp.GetSubject(), now)
+	policies := s.currentPolicies()
+
+	result := authorization.CheckAccessResponse_RESULT_ALLOWED
+
+	for _, action := range req.GetActions() {
+		vars := request.Vars(action.GetAction(), action.GetResourceId())
+
+		allowed, err := policies.evaluate(ctx, vars)
+		if err != nil {
+			return nil, err
+		}
+
+		if !allowed {
+			result = authorization.CheckAccessResponse_RESULT_DENIED
+
+			break
+		}
+	}
+
+	out := &authorization.CheckAccessResponse{
+		Result: result,
+	}
+
+	return out, nil
+}
+
+// evaluate reports whether the policies allow the action described by the given variables.
+func (p *policySet) evaluate(ctx context.Context, vars map[string]any) (bool, error) {
+	for _, r := range p.deny {
+		holds, err := r.evaluate(ctx, vars)
+		if ctx.Err() != nil {
+			return false, status.FromContextError(ctx.Err()).Err()
+		}
+
+		if holds || err != nil {
+			return false, nil
+		}
+	}
+
+	for _, r := range p.allow {
+		holds, err := r.evaluate(ctx, vars)
+		if ctx.Err() != nil {
+			return false, status.FromContextError(ctx.Err()).Err()
+		}
+
+		if holds && err == nil {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r rule) evaluate(ctx context.Context, vars map[string]any) (bool, error) {
+	val, _, err := r.program.ContextEval(ctx, vars)
+	if err != nil {
+		return false, err
+	}
+
+	holds, ok := val.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("rule %s: condition evaluated to %s, not bool", r.name, val.Type().TypeName())
+	}
+
+	return holds, nil
+}
+
+// currentPolicies returns the loaded policies, reloading the policy files first if they have
+// changed and no other request is already reloading them.
+func (s *Server) currentPolicies() *policySet {
+	if s.reloadMu.TryLock() {
+		s.reload()
+		s.reloadMu.Unlock()
+	}
+
+	return s.policies.Load()
+}
+
+// reload reloads the policy files if they have changed since they were last checked at least
+// reloadInterval ago. It must be called with reloadMu held.
+func (s *Server) reload() {
+	if time.Since(s.lastChecked) < s.reloadInterval {
+		return
+	}
+
+	s.lastChecked = time.Now()
+
+	files, fingerprint, err := s.stat()
+	if err != nil {
+		log.Printf("error checking policy files, keeping previously loaded policies: %v", err)
+
+		return
+	}
+
+	if fingerprint == s.fingerprint {
+		return
+	}
+
+	policies, err := s.load(files)
+	if err != nil {
+		log.Printf("error reloading policy files, keeping previously loaded policies: %v", err)
+
+		return
+	}
+
+	s.policies.Store(policies)
+	s.fingerprint = fingerprint
+
+	log.Printf("reloaded policy files %s", strings.Join(files, ", "))
+}
+
+// stat returns the sorted paths of the policy files along with a fingerprint which changes when any
+// of them is added, removed, or modified.
+func (s *Server) stat() ([]string, string, error) {
+	var files []string
+
+	for _, pattern := range s.patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if len(matches) == 0 {
+			return nil, "", fmt.Errorf("no policy files match %s", pattern)
+		}
+
+		files = append(files, matches...)
+	}
+
+	sort.Strings(files)
+
+	var fingerprint strings.Builder
+
+	for _, path := range files {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, "", err
+		}
+
+		fmt.Fprintf(&fingerprint, "%s:%d:%d\n", path, info.ModTime().UnixNano(), info.Size())
+	}
+
+	return files, fingerprint.String(), nil
+}
+
+// load reads and compiles the given policy files.
+func (s *Server) load(files []string) (*policySet, error) {
+	policies := &policySet{}
+	names := make(map[string]string)
+
+	for _, path := range files {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		var file policyFile
+
+		if err := yaml.Unmarshal(b, &file); err != nil {
+			return nil, fmt.Errorf("error parsing policy file %s: %w", path, err)
+		}
+
+		for i, r := range file.Rules {
+			if r.Name == "" || r.Condition == "" {
+				return nil, fmt.Errorf("policy file %s: rule %d: name and condition are required", path, i)
+			}
+
+			if other, ok := names[r.Name]; ok {
+				return nil, fmt.Errorf("policy file %s: rule %s: duplicate name, also used in %s", path, r.Name, other)
+			}
+
+			names[r.Name] = path
+
+			compiled, err := s.compile(r)
+			if err != nil {
+				return nil, fmt.Errorf("policy file %s: rule %s: %w", path, r.Name, err)
+			}
+
+			switch r.Effect {
+			case EffectAllow:
+				policies.allow = append(policies.allow, compiled)
+			case EffectDeny:
+				policies.deny = append(policies.deny, compiled)
+			default:
+				return nil, fmt.Errorf("policy file %s: rule %s: effect must be %q or %q", path, r.Name, EffectAllow, EffectDeny)
+			}
+		}
+	}
+
+	return policies, nil
+}
+
+func (s *Server) compile(r Rule) (rule, error) {
+	program, err := celenv.Compile(s.env, r.Condition, s.costLimit)
+	if err != nil {
+		return rule{}, err
+	}
+
+	out := rule{
+		name:    r.Name,
+		program: program,
+	}
+
+	return out, nil
+}
@@ -0,0 +1,306 @@
+package policy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	authorization "github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization/v2"
+	"github.com/metal-toolbox/iam-runtime/pkg/providers/static"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const testPolicy = `
+rules:
+  - name: admins
+    effect: allow
+    condition: subject.claims.groups.exists(g, g == "admins")
+  - name: readers
+    effect: allow
+    condition: action.startsWith("read_") && "reader" in subject.claims.roles
+  - name: frozen-tenant
+    effect: deny
+    condition: resource_id in subject.claims.frozen_tenants
+`
+
+// writePolicy writes a policy file, setting its modification time to mtime so that changes are
+// detected regardless of the resolution of the file system's timestamps.
+func writePolicy(t *testing.T, path, contents string, mtime time.Time) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("error writing policy file: %v", err)
+	}
+
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatalf("error setting policy file time: %v", err)
+	}
+}
+
+func newTestServer(t *testing.T, files ...string) *Server {
+	t.Helper()
+
+	authn, err := static.NewAuthenticationServer(static.AuthenticationConfig{
+		Credentials: []static.Credential{
+			{
+				Credential: "admin-token",
+				SubjectID:  "admin",
+				Claims: map[string]any{
+					"groups":         []any{"admins"},
+					"frozen_tenants": []any{"tnnt-frozen"},
+				},
+			},
+			{
+				Credential: "reader-token",
+				SubjectID:  "reader",
+				Claims: map[string]any{
+					"roles":          []any{"reader"},
+					"frozen_tenants": []any{},
+				},
+			},
+			{
+				// Has no frozen_tenants claim, so the deny rule cannot be evaluated.
+				Credential: "unfrozen-admin-token",
+				SubjectID:  "unfrozen-admin",
+				Claims: map[string]any{
+					"groups": []any{"admins"},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("error creating authentication server: %v", err)
+	}
+
+	srv, err := NewServer(Config{
+		Files:          files,
+		ReloadInterval: time.Nanosecond,
+	}, authn)
+	if err != nil {
+		t.Fatalf("error creating server: %v", err)
+	}
+
+	return srv
+}
+
+func checkAccess(t *testing.T, srv *Server, credential, action, resourceID string) authorization.CheckAccessResponse_Result {
+	t.Helper()
+
+	req := &authorization.CheckAccessRequest{
+		Credential: credential,
+		Actions: []*authorization.AccessRequestAction{
+			{Action: action, ResourceId: resourceID},
+		},
+	}
+
+	resp, err := srv.CheckAccess(context.Background(), req)
+	if err != nil {
+		t.Fatalf("error checking access: %v", err)
+	}
+
+	return resp.GetResult()
+}
+
+func TestCheckAccess(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	writePolicy(t, path, testPolicy, time.Now())
+
+	srv := newTestServer(t, path)
+
+	const (
+		allowed = authorization.CheckAccessResponse_RESULT_ALLOWED
+		denied  = authorization.CheckAccessResponse_RESULT_DENIED
+	)
+
+	tests := []struct {
+		name       string
+		credential string
+		action     string
+		resourceID string
+		want       authorization.CheckAccessResponse_Result
+	}{
+		{"allow rule holds", "admin-token", "delete_widget", "tnnt-a", allowed},
+		{"deny overrides allow", "admin-token", "delete_widget", "tnnt-frozen", denied},
+		{"second allow rule holds", "reader-token", "read_widget", "tnnt-a", allowed},
+		{"no allow rule holds", "reader-token", "delete_widget", "tnnt-a", denied},
+		{"allow rule errors", "reader-token", "read_widget", "tnnt-a", allowed},
+		{"deny rule errors", "unfrozen-admin-token", "delete_widget", "tnnt-a", denied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkAccess(t, srv, tt.credential, tt.action, tt.resourceID); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckAccessEveryAction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	writePolicy(t, path, testPolicy, time.Now())
+
+	srv := newTestServer(t, path)
+
+	req := &authorization.CheckAccessRequest{
+		Credential: "reader-token",
+		Actions: []*authorization.AccessRequestAction{
+			{Action: "read_widget", ResourceId: "tnnt-a"},
+			{Action: "delete_widget", ResourceId: "tnnt-a"},
+		},
+	}
+
+	resp, err := srv.CheckAccess(context.Background(), req)
+	if err != nil {
+		t.Fatalf("error checking access: %v", err)
+	}
+
+	if resp.GetResult() != authorization.CheckAccessResponse_RESULT_DENIED {
+		t.Errorf("got %s, want denied", resp.GetResult())
+	}
+
+	req.Credential = "unknown-token"
+
+	if _, err := srv.CheckAccess(context.Background(), req); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("got error %v for invalid credential, want %s", err, codes.InvalidArgument)
+	}
+}
+
+func TestNewServerInvalidPolicies(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+	}{
+		{"not yaml", "rules: ["},
+		{"unknown variable", "rules:\n  - name: a\n    effect: allow\n    condition: subjct.claims.admin\n"},
+		{"not bool", "rules:\n  - name: a\n    effect: allow\n    condition: resource_id\n"},
+		{"unknown effect", "rules:\n  - name: a\n    effect: maybe\n    condition: \"true\"\n"},
+		{"missing condition", "rules:\n  - name: a\n    effect: allow\n"},
+		{"duplicate name", "rules:\n  - name: a\n    effect: allow\n    condition: \"true\"\n  - name: a\n    effect: deny\n    condition: \"false\"\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "policy.yaml")
+			writePolicy(t, path, tt.policy, time.Now())
+
+			if _, err := NewServer(Config{Files: []string{path}}, nil); err == nil {
+				t.Error("got no error")
+			}
+		})
+	}
+
+	if _, err := NewServer(Config{Files: []string{filepath.Join(t.TempDir(), "*.yaml")}}, nil); err == nil {
+		t.Error("got no error when no files match")
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "policy.yaml")
+	mtime := time.Now().Add(-time.Hour)
+
+	writePolicy(t, path, testPolicy, mtime)
+
+	srv := newTestServer(t, filepath.Join(dir, "*.yaml"))
+
+	if got := checkAccess(t, srv, "reader-token", "read_widget", "tnnt-a"); got != authorization.CheckAccessResponse_RESULT_ALLOWED {
+		t.Fatalf("got %s before reloading, want allowed", got)
+	}
+
+	// Invalid policies are not loaded, and the previously loaded policies remain in use.
+	mtime = mtime.Add(time.Minute)
+	writePolicy(t, path, "rules:\n  - name: broken\n    effect: allow\n    condition: subjct.claims\n", mtime)
+
+	if got := checkAccess(t, srv, "reader-token", "read_widget", "tnnt-a"); got != authorization.CheckAccessResponse_RESULT_ALLOWED {
+		t.Errorf("got %s after writing invalid policies, want allowed", got)
+	}
+
+	mtime = mtime.Add(time.Minute)
+	writePolicy(t, path, "rules:\n  - name: admins\n    effect: allow\n    condition: subject.claims.groups.exists(g, g == \"admins\")\n", mtime)
+
+	if got := checkAccess(t, srv, "reader-token", "read_widget", "tnnt-a"); got != authorization.CheckAccessResponse_RESULT_DENIED {
+		t.Errorf("got %s after removing reader rule, want denied", got)
+	}
+
+	// Files matching the pattern are loaded once they are added.
+	writePolicy(t, filepath.Join(dir, "readers.yaml"), "rules:\n  - name: readers\n    effect: allow\n    condition: action.startsWith(\"read_\")\n", mtime)
+
+	if got := checkAccess(t, srv, "reader-token", "read_widget", "tnnt-a"); got != authorization.CheckAccessResponse_RESULT_ALLOWED {
+		t.Errorf("got %s after adding policy file, want allowed", got)
+	}
+
+	// If no files match, the previously loaded policies remain in use.
+	for _, name := range []string{"policy.yaml", "readers.yaml"} {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("error removing policy file: %v", err)
+		}
+	}
+
+	if got := checkAccess(t, srv, "reader-token", "read_widget", "tnnt-a"); got != authorization.CheckAccessResponse_RESULT_ALLOWED {
+		t.Errorf("got %s after removing policy files, want allowed", got)
+	}
+}
+
+func TestReloadConcurrently(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	mtime := time.Now().Add(-time.Hour)
+
+	allowAll := "rules:\n  - name: all\n    effect: allow\n    condition: \"true\"\n"
+	denyAll := "rules:\n  - name: none\n    effect: allow\n    condition: \"false\"\n"
+
+	writePolicy(t, path, allowAll, mtime)
+
+	srv := newTestServer(t, path)
+
+	var wg sync.WaitGroup
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			req := &authorization.CheckAccessRequest{
+				Credential: "reader-token",
+				Actions: []*authorization.AccessRequestAction{
+					{Action: "read_widget", ResourceId: "tnnt-a"},
+				},
+			}
+
+			for ctx.Err() == nil {
+				if _, err := srv.CheckAccess(context.Background(), req); err != nil {
+					t.Errorf("error checking access: %v", err)
+
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 20; i++ {
+		mtime = mtime.Add(time.Minute)
+
+		if i%2 == 0 {
+			writePolicy(t, path, denyAll, mtime)
+		} else {
+			writePolicy(t, path, allowAll, mtime)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	wg.Wait()
+
+	// The final policies are loaded by the next request.
+	if got := checkAccess(t, srv, "reader-token", "read_widget", "tnnt-a"); got != authorization.CheckAccessResponse_RESULT_ALLOWED {
+		t.Errorf("got %s after reloading, want allowed", got)
+	}
+}
@@ -0,0 +1,383 @@
+// Package spicedb provides an Authorization service implementation which delegates access decisions
+// and relationship storage to SpiceDB (https://authzed.com/spicedb).
+//
+// Resource and subject IDs are mapped to SpiceDB object references using the prefix before the first
+// separator in each ID, such that "tnntten-abc" may refer to the object "tenant:tnntten-abc". Subject
+// set IDs of the form "<ID>#<relation>" refer to the given relation on the object. Actions are
+// checked as permissions of the same name, unless mapped to another permission in the config.
+//
+// Checks are made at least as fresh as the latest write made through the server, so that
+// relationships created by a client are visible to its next check.
+package spicedb
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
+	authorization "github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultSeparator separates the prefix of an ID from the rest of it if no separator is configured.
+const DefaultSeparator = "-"
+
+// Config represents the configuration for a SpiceDB Authorization server.
+type Config struct {
+	// Endpoint is the address of the SpiceDB gRPC API.
+	Endpoint string `yaml:"endpoint"`
+	// Insecure disables TLS when connecting to SpiceDB.
+	Insecure bool `yaml:"insecure"`
+	// Token is the preshared key used to authenticate to SpiceDB.
+	Token string `yaml:"token"`
+	// TokenFile is the path of a file containing the preshared key, which is read on each request
+	// so that it may be rotated. It takes precedence over Token.
+	TokenFile string `yaml:"token_file"`
+	// Separator separates the prefix of an ID from the rest of it.
+	Separator string `yaml:"separator"`
+	// Types maps each ID prefix to the SpiceDB object type of IDs with that prefix.
+	Types map[string]string `yaml:"types"`
+	// DefaultSubjectType is the object type of subject IDs with no known prefix, such as the IDs of
+	// users issued by an identity provider. If empty, such subjects are not allowed.
+	DefaultSubjectType string `yaml:"default_subject_type"`
+	// Permissions maps actions to the SpiceDB permissions checked for them. Actions which are not
+	// mapped are checked as the permission of the same name.
+	Permissions map[string]string `yaml:"permissions"`
+	// FullyConsistent makes every check fully consistent, at the cost of latency.
+	FullyConsistent bool `yaml:"fully_consistent"`
+}
+
+// Server is an Authorization server which makes access decisions using SpiceDB. Credentials are
+// validated using an Authentication server.
+type Server struct {
+	authorization.UnimplementedAuthorizationServer
+
+	cfg    Config
+	authn  authentication.AuthenticationServer
+	conn   *grpc.ClientConn
+	client v1.PermissionsServiceClient
+
+	// writtenAt is the ZedToken of the latest write made through the server.
+	writtenAt atomic.Pointer[v1.ZedToken]
+}
+
+// NewServer creates a new Server using the given config, validating credentials with authn.
+// Connections to SpiceDB are made lazily.
+func NewServer(cfg Config, authn authentication.AuthenticationServer) (*Server, error) {
+	if cfg.Endpoint == "" {
+		return nil, errors.New("endpoint is required")
+	}
+
+	if cfg.Separator == "" {
+		cfg.Separator = DefaultSeparator
+	}
+
+	transportCreds := credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	if cfg.Insecure {
+		transportCreds = insecure.NewCredentials()
+	}
+
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(transportCreds),
+	}
+
+	if cfg.Token != "" || cfg.TokenFile != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(&presharedKey{cfg: cfg}))
+	}
+
+	conn, err := grpc.NewClient(cfg.Endpoint, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating SpiceDB client: %w", err)
+	}
+
+	out := &Server{
+		cfg:    cfg,
+		authn:  authn,
+		conn:   conn,
+		client: v1.NewPermissionsServiceClient(conn),
+	}
+
+	return out, nil
+}
+
+// Close closes the connection to SpiceDB.
+func (s *Server) Close() error {
+	return s.conn.Close()
+}
+
+// Ready checks that SpiceDB is reachable and reports itself as serving using gRPC health checking.
+func (s *Server) Ready(ctx context.Context) error {
+	resp, err := healthpb.NewHealthClient(s.conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		return fmt.Errorf("error checking SpiceDB health: %w", err)
+	}
+
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("SpiceDB is %s", resp.GetStatus())
+	}
+
+	return nil
+}
+
+// RPCs returns the names of the Authorization RPCs the server implements.
+func (s *Server) RPCs() []string {
+	return []string{"CheckAccess", "CreateRelationships", "DeleteRelationships"}
+}
+
+// CheckAccess allows the request if SpiceDB finds the subject identified by the given credential has
+// the permission for every requested action.
+func (s *Server) CheckAccess(ctx context.Context, req *authorization.CheckAccessRequest) (*authorization.CheckAccessResponse, error) {
+	if len(req.GetActions()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "actions are required")
+	}
+
+	validateReq := &authentication.ValidateCredentialRequest{
+		Credential: req.GetCredential(),
+	}
+
+	validateResp, err := s.authn.ValidateCredential(ctx, validateReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if validateResp.GetResult() != authentication.ValidateCredentialResponse_RESULT_VALID {
+		return nil, status.Error(codes.InvalidArgument, "invalid credential")
+	}
+
+	subject, err := s.subjectReference(validateResp.GetSubject().GetSubjectId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	consistency := s.consistency()
+
+	result := authorization.CheckAccessResponse_RESULT_ALLOWED
+
+	for _, action := range req.GetActions() {
+		resource, err := s.objectReference(action.GetResourceId(), false)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		checkReq := &v1.CheckPermissionRequest{
+			Consistency: consistency,
+			Resource:    resource,
+			Permission:  s.permission(action.GetAction()),
+			Subject:     subject,
+		}
+
+		checkResp, err := s.client.CheckPermission(ctx, checkReq)
+		if err != nil {
+			return nil, toStatus("checking permission", err)
+		}
+
+		// Conditional permission depends on caveat context which the Authorization service does not
+		// provide, so it is not sufficient to allow the action.
+		if checkResp.GetPermissionship() != v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION {
+			result = authorization.CheckAccessResponse_RESULT_DENIED
+
+			break
+		}
+	}
+
+	out := &authorization.CheckAccessResponse{
+		Result: result,
+	}
+
+	return out, nil
+}
+
+// CreateRelationships creates the given relationships in SpiceDB.
+func (s *Server) CreateRelationships(ctx context.Context, req *authorization.CreateRelationshipsRequest) (*authorization.CreateRelationshipsResponse, error) {
+	// Relationships are touched rather than created, since creating a relationship which already
+	// exists must succeed.
+	if err := s.write(ctx, v1.RelationshipUpdate_OPERATION_TOUCH, req.GetResourceId(), req.GetRelationships()); err != nil {
+		return nil, err
+	}
+
+	return &authorization.CreateRelationshipsResponse{}, nil
+}
+
+// DeleteRelationships deletes the given relationships from SpiceDB.
+func (s *Server) DeleteRelationships(ctx context.Context, req *authorization.DeleteRelationshipsRequest) (*authorization.DeleteRelationshipsResponse, error) {
+	if err := s.write(ctx, v1.RelationshipUpdate_OPERATION_DELETE, req.GetResourceId(), req.GetRelationships()); err != nil {
+		return nil, err
+	}
+
+	return &authorization.DeleteRelationshipsResponse{}, nil
+}
+
+func (s *Server) write(ctx context.Context, op v1.RelationshipUpdate_Operation, resourceID string, relationships []*authorization.Relationship) error {
+	if len(relationships) == 0 {
+		return status.Error(codes.InvalidArgument, "relationships are required")
+	}
+
+	resource, err := s.objectReference(resourceID, false)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	writeReq := &v1.WriteRelationshipsRequest{
+		Updates: make([]*v1.RelationshipUpdate, 0, len(relationships)),
+	}
+
+	for _, rel := range relationships {
+		if rel.GetRelation() == "" {
+			return status.Error(codes.InvalidArgument, "relation is required")
+		}
+
+		subject, err := s.subjectReference(rel.GetSubjectId())
+		if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		update := &v1.RelationshipUpdate{
+			Operation: op,
+			Relationship: &v1.Relationship{
+				Resource: resource,
+				Relation: rel.GetRelation(),
+				Subject:  subject,
+			},
+		}
+
+		writeReq.Updates = append(writeReq.Updates, update)
+	}
+
+	writeResp, err := s.client.WriteRelationships(ctx, writeReq)
+	if err != nil {
+		return toStatus("writing relationships", err)
+	}
+
+	if token := writeResp.GetWrittenAt(); token != nil {
+		s.writtenAt.Store(token)
+	}
+
+	return nil
+}
+
+// consistency returns the consistency required of checks.
+func (s *Server) consistency() *v1.Consistency {
+	if s.cfg.FullyConsistent {
+		return &v1.Consistency{
+			Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true},
+		}
+	}
+
+	if token := s.writtenAt.Load(); token != nil {
+		return &v1.Consistency{
+			Requirement: &v1.Consistency_AtLeastAsFresh{AtLeastAsFresh: token},
+		}
+	}
+
+	return &v1.Consistency{
+		Requirement: &v1.Consistency_MinimizeLatency{MinimizeLatency: true},
+	}
+}
+
+// permission returns the SpiceDB permission checked for the given action.
+func (s *Server) permission(action string) string {
+	if permission, ok := s.cfg.Permissions[action]; ok {
+		return permission
+	}
+
+	return action
+}
+
+// objectReference returns the SpiceDB object with the given ID. If subject is true and the ID has no
+// known prefix, the object has the default subject type, if any.
+func (s *Server) objectReference(id string, subject bool) (*v1.ObjectReference, error) {
+	if id == "" {
+		return nil, errors.New("id is required")
+	}
+
+	if prefix, _, ok := strings.Cut(id, s.cfg.Separator); ok {
+		if objectType, ok := s.cfg.Types[prefix]; ok {
+			out := &v1.ObjectReference{
+				ObjectType: objectType,
+				ObjectId:   id,
+			}
+
+			return out, nil
+		}
+	}
+
+	if subject && s.cfg.DefaultSubjectType != "" {
+		out := &v1.ObjectReference{
+			ObjectType: s.cfg.DefaultSubjectType,
+			ObjectId:   id,
+		}
+
+		return out, nil
+	}
+
+	return nil, fmt.Errorf("unknown type for id %q", id)
+}
+
+// subjectReference returns the SpiceDB subject with the given ID, which may be a subject set.
+func (s *Server) subjectReference(subjectID string) (*v1.SubjectReference, error) {
+	id, relation, _ := strings.Cut(subjectID, "#")
+
+	object, err := s.objectReference(id, relation == "")
+	if err != nil {
+		return nil, err
+	}
+
+	out := &v1.SubjectReference{
+		Object:           object,
+		OptionalRelation: relation,
+	}
+
+	return out, nil
+}
+
+// toStatus converts an error returned by SpiceDB to a gRPC status error. Errors caused by the
+// request, such as referring to an unknown type or permission, are returned as InvalidArgument.
+func toStatus(doing string, err error) error {
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.NotFound:
+		return status.Errorf(codes.InvalidArgument, "error %s: %s", doing, status.Convert(err).Message())
+	case codes.Canceled, codes.DeadlineExceeded:
+		return err
+	default:
+		return status.Errorf(codes.Unavailable, "error %s: %s", doing, status.Convert(err).Message())
+	}
+}
+
+// presharedKey authenticates requests to SpiceDB with a preshared key.
+type presharedKey struct {
+	cfg Config
+}
+
+func (k *presharedKey) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	token := k.cfg.Token
+
+	if k.cfg.TokenFile != "" {
+		b, err := os.ReadFile(k.cfg.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading token file: %w", err)
+		}
+
+		token = strings.TrimSpace(string(b))
+	}
+
+	out := map[string]string{
+		"authorization": "Bearer " + token,
+	}
+
+	return out, nil
+}
+
+func (k *presharedKey) RequireTransportSecurity() bool {
+	return !k.cfg.Insecure
+}
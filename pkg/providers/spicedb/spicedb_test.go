@@ -0,0 +1,478 @@
+package spicedb
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	authorization "github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization/v2"
+	"github.com/metal-toolbox/iam-runtime/pkg/providers/static"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// testSpiceDB is a fake SpiceDB PermissionsService. Permissions are checked as relations, and
+// permissions named "unknown" fail as they would if not defined in the schema.
+type testSpiceDB struct {
+	v1.UnimplementedPermissionsServiceServer
+
+	addr   string
+	grpc   *grpc.Server
+	health *health.Server
+
+	mu            sync.Mutex
+	relationships map[string]bool
+	revision      int
+	checks        []*v1.CheckPermissionRequest
+	authorization []string
+}
+
+func newTestSpiceDB(t *testing.T) *testSpiceDB {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+
+	srv := &testSpiceDB{
+		addr:          lis.Addr().String(),
+		grpc:          grpc.NewServer(),
+		health:        health.NewServer(),
+		relationships: make(map[string]bool),
+	}
+
+	v1.RegisterPermissionsServiceServer(srv.grpc, srv)
+	healthpb.RegisterHealthServer(srv.grpc, srv.health)
+
+	go func() {
+		_ = srv.grpc.Serve(lis)
+	}()
+
+	t.Cleanup(srv.grpc.Stop)
+
+	return srv
+}
+
+func (s *testSpiceDB) CheckPermission(ctx context.Context, req *v1.CheckPermissionRequest) (*v1.CheckPermissionResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.record(ctx)
+	s.checks = append(s.checks, req)
+
+	if req.GetPermission() == "unknown" {
+		return nil, status.Error(codes.FailedPrecondition, "permission unknown not found")
+	}
+
+	rel := &v1.Relationship{
+		Resource: req.GetResource(),
+		Relation: req.GetPermission(),
+		Subject:  req.GetSubject(),
+	}
+
+	permissionship := v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION
+	if s.relationships[relationshipKey(rel)] {
+		permissionship = v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION
+	}
+
+	out := &v1.CheckPermissionResponse{
+		Permissionship: permissionship,
+	}
+
+	return out, nil
+}
+
+func (s *testSpiceDB) WriteRelationships(ctx context.Context, req *v1.WriteRelationshipsRequest) (*v1.WriteRelationshipsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.record(ctx)
+
+	for _, update := range req.GetUpdates() {
+		key := relationshipKey(update.GetRelationship())
+
+		switch update.GetOperation() {
+		case v1.RelationshipUpdate_OPERATION_TOUCH:
+			s.relationships[key] = true
+		case v1.RelationshipUpdate_OPERATION_DELETE:
+			delete(s.relationships, key)
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "unexpected operation %s", update.GetOperation())
+		}
+	}
+
+	s.revision++
+
+	out := &v1.WriteRelationshipsResponse{
+		WrittenAt: &v1.ZedToken{Token: strconv.Itoa(s.revision)},
+	}
+
+	return out, nil
+}
+
+// record records the authorization metadata of a request.
+func (s *testSpiceDB) record(ctx context.Context) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	s.authorization = append(s.authorization, md.Get("authorization")...)
+}
+
+func (s *testSpiceDB) lastCheck() *v1.CheckPermissionRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.checks) == 0 {
+		return nil
+	}
+
+	return s.checks[len(s.checks)-1]
+}
+
+func relationshipKey(rel *v1.Relationship) string {
+	subject := rel.GetSubject()
+
+	out := rel.GetResource().GetObjectType() + ":" + rel.GetResource().GetObjectId() + "#" + rel.GetRelation() +
+		"@" + subject.GetObject().GetObjectType() + ":" + subject.GetObject().GetObjectId()
+
+	if subject.GetOptionalRelation() != "" {
+		out += "#" + subject.GetOptionalRelation()
+	}
+
+	return out
+}
+
+func newTestServer(t *testing.T, spicedb *testSpiceDB, cfg Config) *Server {
+	t.Helper()
+
+	authn, err := static.NewAuthenticationServer(static.AuthenticationConfig{
+		Credentials: []static.Credential{
+			{Credential: "alice-token", SubjectID: "alice"},
+			{Credential: "bob-token", SubjectID: "bob"},
+			{Credential: "builder-token", SubjectID: "svc-builder"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("error creating authentication server: %v", err)
+	}
+
+	cfg.Endpoint = spicedb.addr
+	cfg.Insecure = true
+	cfg.Types = map[string]string{
+		"tnnt": "tenant",
+		"lb":   "loadbalancer",
+		"grp":  "group",
+		"svc":  "service_account",
+	}
+
+	srv, err := NewServer(cfg, authn)
+	if err != nil {
+		t.Fatalf("error creating server: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_ = srv.Close()
+	})
+
+	return srv
+}
+
+func createRelationships(t *testing.T, srv *Server, resourceID string, rels ...*authorization.Relationship) {
+	t.Helper()
+
+	req := &authorization.CreateRelationshipsRequest{
+		ResourceId:    resourceID,
+		Relationships: rels,
+	}
+
+	if _, err := srv.CreateRelationships(context.Background(), req); err != nil {
+		t.Fatalf("error creating relationships on %s: %v", resourceID, err)
+	}
+}
+
+func checkAccess(srv *Server, credential string, actions ...*authorization.AccessRequestAction) (authorization.CheckAccessResponse_Result, error) {
+	req := &authorization.CheckAccessRequest{
+		Credential: credential,
+		Actions:    actions,
+	}
+
+	resp, err := srv.CheckAccess(context.Background(), req)
+
+	return resp.GetResult(), err
+}
+
+func TestCheckAccess(t *testing.T) {
+	spicedb := newTestSpiceDB(t)
+	srv := newTestServer(t, spicedb, Config{
+		DefaultSubjectType: "user",
+		Permissions: map[string]string{
+			"loadbalancer_get": "view",
+		},
+	})
+
+	createRelationships(t, srv, "tnnt-a",
+		&authorization.Relationship{Relation: "member", SubjectId: "alice"},
+		&authorization.Relationship{Relation: "deploy", SubjectId: "svc-builder"},
+	)
+	createRelationships(t, srv, "lb-a",
+		&authorization.Relationship{Relation: "view", SubjectId: "alice"},
+	)
+
+	const (
+		allowed = authorization.CheckAccessResponse_RESULT_ALLOWED
+		denied  = authorization.CheckAccessResponse_RESULT_DENIED
+	)
+
+	tests := []struct {
+		name       string
+		credential string
+		actions    []*authorization.AccessRequestAction
+		want       authorization.CheckAccessResponse_Result
+		wantCode   codes.Code
+	}{
+		{
+			name:       "default subject type",
+			credential: "alice-token",
+			actions:    []*authorization.AccessRequestAction{{Action: "member", ResourceId: "tnnt-a"}},
+			want:       allowed,
+		},
+		{
+			name:       "prefixed subject type",
+			credential: "builder-token",
+			actions:    []*authorization.AccessRequestAction{{Action: "deploy", ResourceId: "tnnt-a"}},
+			want:       allowed,
+		},
+		{
+			name:       "mapped permission",
+			credential: "alice-token",
+			actions:    []*authorization.AccessRequestAction{{Action: "loadbalancer_get", ResourceId: "lb-a"}},
+			want:       allowed,
+		},
+		{
+			name:       "no permission",
+			credential: "bob-token",
+			actions:    []*authorization.AccessRequestAction{{Action: "member", ResourceId: "tnnt-a"}},
+			want:       denied,
+		},
+		{
+			name:       "every action required",
+			credential: "alice-token",
+			actions: []*authorization.AccessRequestAction{
+				{Action: "member", ResourceId: "tnnt-a"},
+				{Action: "deploy", ResourceId: "tnnt-a"},
+			},
+			want: denied,
+		},
+		{
+			name:       "invalid credential",
+			credential: "mallory-token",
+			actions:    []*authorization.AccessRequestAction{{Action: "member", ResourceId: "tnnt-a"}},
+			wantCode:   codes.InvalidArgument,
+		},
+		{
+			name:       "unknown resource type",
+			credential: "alice-token",
+			actions:    []*authorization.AccessRequestAction{{Action: "member", ResourceId: "unknown-a"}},
+			wantCode:   codes.InvalidArgument,
+		},
+		{
+			name:       "unknown permission",
+			credential: "alice-token",
+			actions:    []*authorization.AccessRequestAction{{Action: "unknown", ResourceId: "tnnt-a"}},
+			wantCode:   codes.InvalidArgument,
+		},
+		{
+			name:       "no actions",
+			credential: "alice-token",
+			wantCode:   codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := checkAccess(srv, tt.credential, tt.actions...)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("got code %s, want %s: %v", code, tt.wantCode, err)
+			}
+
+			if err == nil && got != tt.want {
+				t.Errorf("got result %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubjectSets(t *testing.T) {
+	spicedb := newTestSpiceDB(t)
+	srv := newTestServer(t, spicedb, Config{})
+
+	createRelationships(t, srv, "tnnt-a",
+		&authorization.Relationship{Relation: "audit", SubjectId: "grp-auditors#member"},
+	)
+
+	want := "tenant:tnnt-a#audit@group:grp-auditors#member"
+
+	spicedb.mu.Lock()
+	got := spicedb.relationships[want]
+	spicedb.mu.Unlock()
+
+	if !got {
+		t.Errorf("relationship %s not written", want)
+	}
+
+	// Subjects without a known prefix are not allowed without a default subject type.
+	req := &authorization.CreateRelationshipsRequest{
+		ResourceId: "tnnt-a",
+		Relationships: []*authorization.Relationship{
+			{Relation: "member", SubjectId: "alice"},
+		},
+	}
+
+	if _, err := srv.CreateRelationships(context.Background(), req); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("got error %v, want %s", err, codes.InvalidArgument)
+	}
+}
+
+func TestDeleteRelationships(t *testing.T) {
+	spicedb := newTestSpiceDB(t)
+	srv := newTestServer(t, spicedb, Config{DefaultSubjectType: "user"})
+
+	rel := &authorization.Relationship{Relation: "member", SubjectId: "alice"}
+
+	createRelationships(t, srv, "tnnt-a", rel)
+
+	// Creating a relationship which already exists succeeds.
+	createRelationships(t, srv, "tnnt-a", rel)
+
+	action := &authorization.AccessRequestAction{Action: "member", ResourceId: "tnnt-a"}
+
+	if got, err := checkAccess(srv, "alice-token", action); err != nil || got != authorization.CheckAccessResponse_RESULT_ALLOWED {
+		t.Fatalf("got result %s, error %v before deleting, want allowed", got, err)
+	}
+
+	req := &authorization.DeleteRelationshipsRequest{
+		ResourceId:    "tnnt-a",
+		Relationships: []*authorization.Relationship{rel},
+	}
+
+	if _, err := srv.DeleteRelationships(context.Background(), req); err != nil {
+		t.Fatalf("error deleting relationships: %v", err)
+	}
+
+	if got, err := checkAccess(srv, "alice-token", action); err != nil || got != authorization.CheckAccessResponse_RESULT_DENIED {
+		t.Errorf("got result %s, error %v after deleting, want denied", got, err)
+	}
+}
+
+func TestConsistency(t *testing.T) {
+	action := &authorization.AccessRequestAction{Action: "member", ResourceId: "tnnt-a"}
+
+	spicedb := newTestSpiceDB(t)
+	srv := newTestServer(t, spicedb, Config{DefaultSubjectType: "user"})
+
+	if _, err := checkAccess(srv, "alice-token", action); err != nil {
+		t.Fatalf("error checking access: %v", err)
+	}
+
+	if !spicedb.lastCheck().GetConsistency().GetMinimizeLatency() {
+		t.Errorf("got consistency %v before writing, want minimize latency", spicedb.lastCheck().GetConsistency())
+	}
+
+	createRelationships(t, srv, "tnnt-a", &authorization.Relationship{Relation: "member", SubjectId: "alice"})
+	createRelationships(t, srv, "tnnt-a", &authorization.Relationship{Relation: "member", SubjectId: "bob"})
+
+	if _, err := checkAccess(srv, "alice-token", action); err != nil {
+		t.Fatalf("error checking access: %v", err)
+	}
+
+	if got := spicedb.lastCheck().GetConsistency().GetAtLeastAsFresh().GetToken(); got != "2" {
+		t.Errorf("got at least as fresh as %q after writing, want %q", got, "2")
+	}
+
+	srv = newTestServer(t, spicedb, Config{DefaultSubjectType: "user", FullyConsistent: true})
+
+	if _, err := checkAccess(srv, "alice-token", action); err != nil {
+		t.Fatalf("error checking access: %v", err)
+	}
+
+	if !spicedb.lastCheck().GetConsistency().GetFullyConsistent() {
+		t.Errorf("got consistency %v, want fully consistent", spicedb.lastCheck().GetConsistency())
+	}
+}
+
+func TestPresharedKey(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+
+	if err := os.WriteFile(tokenFile, []byte("first\n"), 0o600); err != nil {
+		t.Fatalf("error writing token file: %v", err)
+	}
+
+	spicedb := newTestSpiceDB(t)
+	srv := newTestServer(t, spicedb, Config{
+		Token:              "ignored",
+		TokenFile:          tokenFile,
+		DefaultSubjectType: "user",
+	})
+
+	action := &authorization.AccessRequestAction{Action: "member", ResourceId: "tnnt-a"}
+
+	if _, err := checkAccess(srv, "alice-token", action); err != nil {
+		t.Fatalf("error checking access: %v", err)
+	}
+
+	// The token file is read on each request, so that the key may be rotated.
+	if err := os.WriteFile(tokenFile, []byte("second\n"), 0o600); err != nil {
+		t.Fatalf("error writing token file: %v", err)
+	}
+
+	if _, err := checkAccess(srv, "alice-token", action); err != nil {
+		t.Fatalf("error checking access: %v", err)
+	}
+
+	want := []string{"Bearer first", "Bearer second"}
+
+	spicedb.mu.Lock()
+	got := spicedb.authorization
+	spicedb.mu.Unlock()
+
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("got authorization %q, want %q", got, want)
+	}
+}
+
+func TestUnavailable(t *testing.T) {
+	spicedb := newTestSpiceDB(t)
+	srv := newTestServer(t, spicedb, Config{DefaultSubjectType: "user"})
+
+	ctx := context.Background()
+
+	if err := srv.Ready(ctx); err != nil {
+		t.Fatalf("got error %v while serving, want nil", err)
+	}
+
+	spicedb.health.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	if err := srv.Ready(ctx); err == nil {
+		t.Error("got no error while not serving")
+	}
+
+	spicedb.grpc.Stop()
+
+	if err := srv.Ready(ctx); err == nil {
+		t.Error("got no error after stopping")
+	}
+
+	_, err := checkAccess(srv, "alice-token", &authorization.AccessRequestAction{Action: "member", ResourceId: "tnnt-a"})
+	if code := status.Code(err); code != codes.Unavailable {
+		t.Errorf("got code %s after stopping, want %s: %v", code, codes.Unavailable, err)
+	}
+}
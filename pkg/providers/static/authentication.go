@@ -0,0 +1,196 @@
+// Package static provides IAM runtime service implementations configured entirely with static
+// data, suitable for development environments and small deployments.
+package static
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
+	"github.com/metal-toolbox/iam-runtime/pkg/revocation"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// AuthenticationConfig represents the configuration for a static Authentication server.
+type AuthenticationConfig struct {
+	// Credentials is the set of credentials the server accepts.
+	Credentials []Credential `yaml:"credentials"`
+	// Revocations is the store of revoked credentials, if any. Credentials are matched against
+	// revocations by their subject ID.
+	Revocations *revocation.Store `yaml:"-"`
+}
+
+// Credential describes a credential accepted by a static Authentication server and the subject it
+// identifies.
+type Credential struct {
+	// Credential is the literal credential, such as an API key.
+	Credential string `yaml:"credential"`
+	// SubjectID is the ID of the subject the credential identifies.
+	SubjectID string `yaml:"subject_id"`
+	// Audiences is the set of audiences the credential is valid for. If empty, the credential is
+	// only valid for requests which do not specify an audience.
+	Audiences []string `yaml:"audiences"`
+	// Claims is the set of claims returned for the subject.
+	Claims map[string]any `yaml:"claims"`
+	// DisplayName is the subject's display name.
+	DisplayName string `yaml:"display_name"`
+	// Email is the subject's email address.
+	Email string `yaml:"email"`
+	// Groups is the set of groups the subject is a member of.
+	Groups []string `yaml:"groups"`
+}
+
+type credential struct {
+	Credential
+
+	claims *structpb.Struct
+}
+
+// AuthenticationServer is an Authentication server which accepts a fixed set of credentials.
+type AuthenticationServer struct {
+	authentication.UnimplementedAuthenticationServer
+
+	credentials map[string]*credential
+	subjects    map[string]*credential
+	revocations *revocation.Store
+}
+
+// NewAuthenticationServer creates a new AuthenticationServer using the given config.
+func NewAuthenticationServer(cfg AuthenticationConfig) (*AuthenticationServer, error) {
+	out := &AuthenticationServer{
+		credentials: make(map[string]*credential, len(cfg.Credentials)),
+		subjects:    make(map[string]*credential, len(cfg.Credentials)),
+		revocations: cfg.Revocations,
+	}
+
+	for i, c := range cfg.Credentials {
+		if c.Credential == "" || c.SubjectID == "" {
+			return nil, fmt.Errorf("credential %d: credential and subject_id are required", i)
+		}
+
+		if _, ok := out.credentials[c.Credential]; ok {
+			return nil, fmt.Errorf("credential %d: duplicate credential for subject %q", i, c.SubjectID)
+		}
+
+		claims, err := structpb.NewStruct(c.Claims)
+		if err != nil {
+			return nil, fmt.Errorf("credential %d: error converting claims: %w", i, err)
+		}
+
+		cred := &credential{
+			Credential: c,
+			claims:     claims,
+		}
+
+		out.credentials[c.Credential] = cred
+
+		// The first credential configured for a subject describes its profile.
+		if _, ok := out.subjects[c.SubjectID]; !ok {
+			out.subjects[c.SubjectID] = cred
+		}
+	}
+
+	return out, nil
+}
+
+// RPCs returns the names of the Authentication RPCs the server implements.
+func (s *AuthenticationServer) RPCs() []string {
+	return []string{"ValidateCredential", "GetSubject"}
+}
+
+// CredentialTypes returns the types of credential the server accepts.
+func (s *AuthenticationServer) CredentialTypes() []authentication.CredentialType {
+	return []authentication.CredentialType{authentication.CredentialType_CREDENTIAL_TYPE_API_KEY}
+}
+
+// ValidateCredential validates the given credential against the configured credentials.
+func (s *AuthenticationServer) ValidateCredential(ctx context.Context, req *authentication.ValidateCredentialRequest) (*authentication.ValidateCredentialResponse, error) {
+	switch req.GetCredentialType() {
+	case authentication.CredentialType_CREDENTIAL_TYPE_UNSPECIFIED, authentication.CredentialType_CREDENTIAL_TYPE_API_KEY:
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported credential type %s", req.GetCredentialType())
+	}
+
+	cred, ok := s.credentials[req.GetCredential()]
+	if !ok {
+		return invalid(authentication.ValidateCredentialResponse_INVALID_REASON_UNKNOWN_CREDENTIAL), nil
+	}
+
+	if !cred.hasAudience(req.GetAudience()) {
+		return invalid(authentication.ValidateCredentialResponse_INVALID_REASON_AUDIENCE_MISMATCH), nil
+	}
+
+	if s.isRevoked(cred) {
+		return invalid(authentication.ValidateCredentialResponse_INVALID_REASON_REVOKED), nil
+	}
+
+	out := &authentication.ValidateCredentialResponse{
+		Result: authentication.ValidateCredentialResponse_RESULT_VALID,
+		Subject: &authentication.Subject{
+			SubjectId: cred.SubjectID,
+			Claims:    cred.claims,
+		},
+	}
+
+	return out, nil
+}
+
+// GetSubject returns the profile of a subject identified by any configured credential.
+func (s *AuthenticationServer) GetSubject(ctx context.Context, req *authentication.GetSubjectRequest) (*authentication.GetSubjectResponse, error) {
+	if req.GetSubjectId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "subject_id is required")
+	}
+
+	cred, ok := s.subjects[req.GetSubjectId()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "subject %q not found", req.GetSubjectId())
+	}
+
+	out := &authentication.GetSubjectResponse{
+		Subject: &authentication.Subject{
+			SubjectId: cred.SubjectID,
+			Claims:    cred.claims,
+		},
+		Profile: &authentication.SubjectProfile{
+			DisplayName: cred.DisplayName,
+			Email:       cred.Email,
+		},
+		Groups: cred.Groups,
+	}
+
+	return out, nil
+}
+
+// isRevoked reports whether the given credential has been revoked.
+func (s *AuthenticationServer) isRevoked(cred *credential) bool {
+	if s.revocations == nil {
+		return false
+	}
+
+	_, revoked := s.revocations.IsRevoked(revocation.Credential{SubjectID: cred.SubjectID})
+
+	return revoked
+}
+
+func (c *credential) hasAudience(audience string) bool {
+	if audience == "" {
+		return true
+	}
+
+	for _, aud := range c.Audiences {
+		if aud == audience {
+			return true
+		}
+	}
+
+	return false
+}
+
+func invalid(reason authentication.ValidateCredentialResponse_InvalidReason) *authentication.ValidateCredentialResponse {
+	return &authentication.ValidateCredentialResponse{
+		Result:        authentication.ValidateCredentialResponse_RESULT_INVALID,
+		InvalidReason: reason,
+	}
+}
@@ -0,0 +1,123 @@
+package static
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
+	authorization "github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Wildcard matches any action or resource ID in a Rule.
+const Wildcard = "*"
+
+// AuthorizationConfig represents the configuration for a static Authorization server.
+type AuthorizationConfig struct {
+	// Rules is the set of rules describing which actions subjects are allowed to perform.
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule allows a subject to perform each of a set of actions on each of a set of resources.
+type Rule struct {
+	// SubjectID is the ID of the subject the rule applies to.
+	SubjectID string `yaml:"subject_id"`
+	// Actions is the set of actions the rule allows. Wildcard matches any action.
+	Actions []string `yaml:"actions"`
+	// ResourceIDs is the set of resources the rule allows actions on. Wildcard matches any
+	// resource.
+	ResourceIDs []string `yaml:"resource_ids"`
+}
+
+// AuthorizationServer is an Authorization server which makes access decisions using a fixed set
+// of rules. Credentials are validated using an Authentication server.
+type AuthorizationServer struct {
+	authorization.UnimplementedAuthorizationServer
+
+	authn authentication.AuthenticationServer
+	rules map[string][]Rule
+}
+
+// NewAuthorizationServer creates a new AuthorizationServer using the given config, validating
+// credentials with authn.
+func NewAuthorizationServer(cfg AuthorizationConfig, authn authentication.AuthenticationServer) (*AuthorizationServer, error) {
+	out := &AuthorizationServer{
+		authn: authn,
+		rules: make(map[string][]Rule),
+	}
+
+	for i, rule := range cfg.Rules {
+		if rule.SubjectID == "" || len(rule.Actions) == 0 || len(rule.ResourceIDs) == 0 {
+			return nil, fmt.Errorf("rule %d: subject_id, actions, and resource_ids are required", i)
+		}
+
+		out.rules[rule.SubjectID] = append(out.rules[rule.SubjectID], rule)
+	}
+
+	return out, nil
+}
+
+// RPCs returns the names of the Authorization RPCs the server implements.
+func (s *AuthorizationServer) RPCs() []string {
+	return []string{"CheckAccess"}
+}
+
+// CheckAccess allows the request if every requested action is allowed by a rule for the subject
+// identified by the given credential.
+func (s *AuthorizationServer) CheckAccess(ctx context.Context, req *authorization.CheckAccessRequest) (*authorization.CheckAccessResponse, error) {
+	if len(req.GetActions()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "actions are required")
+	}
+
+	validateReq := &authentication.ValidateCredentialRequest{
+		Credential: req.GetCredential(),
+	}
+
+	validateResp, err := s.authn.ValidateCredential(ctx, validateReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if validateResp.GetResult() != authentication.ValidateCredentialResponse_RESULT_VALID {
+		return nil, status.Error(codes.InvalidArgument, "invalid credential")
+	}
+
+	rules := s.rules[validateResp.GetSubject().GetSubjectId()]
+
+	result := authorization.CheckAccessResponse_RESULT_ALLOWED
+
+	for _, action := range req.GetActions() {
+		if !allowed(rules, action) {
+			result = authorization.CheckAccessResponse_RESULT_DENIED
+
+			break
+		}
+	}
+
+	out := &authorization.CheckAccessResponse{
+		Result: result,
+	}
+
+	return out, nil
+}
+
+func allowed(rules []Rule, action *authorization.AccessRequestAction) bool {
+	for _, rule := range rules {
+		if matches(rule.Actions, action.GetAction()) && matches(rule.ResourceIDs, action.GetResourceId()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matches(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if pattern == Wildcard || pattern == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,61 @@
+package static
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/identity"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// IdentityConfig represents the configuration for a static Identity server.
+type IdentityConfig struct {
+	// TokenFile is the path to a file containing the access token to return. The file is read on
+	// each request, such that the token may be rotated by replacing the file.
+	TokenFile string `yaml:"token_file"`
+}
+
+// IdentityServer is an Identity server which returns an access token read from a file.
+type IdentityServer struct {
+	identity.UnimplementedIdentityServer
+
+	tokenFile string
+}
+
+// NewIdentityServer creates a new IdentityServer using the given config.
+func NewIdentityServer(cfg IdentityConfig) (*IdentityServer, error) {
+	if cfg.TokenFile == "" {
+		return nil, errors.New("token_file is required")
+	}
+
+	out := &IdentityServer{
+		tokenFile: cfg.TokenFile,
+	}
+
+	return out, nil
+}
+
+// GetAccessToken returns the current contents of the token file.
+func (s *IdentityServer) GetAccessToken(ctx context.Context, req *identity.GetAccessTokenRequest) (*identity.GetAccessTokenResponse, error) {
+	b, err := os.ReadFile(s.tokenFile)
+	if err != nil {
+		log.Printf("error reading access token: %v", err)
+
+		return nil, status.Error(codes.Internal, "error reading access token")
+	}
+
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return nil, status.Error(codes.Internal, "access token is empty")
+	}
+
+	out := &identity.GetAccessTokenResponse{
+		Token: token,
+	}
+
+	return out, nil
+}
@@ -0,0 +1,641 @@
+// Package rebac provides an embeddable relationship-based access control engine, in which access is
+// computed from relationships between resources according to a Schema.
+package rebac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	authorization "github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization/v2"
+	"github.com/metal-toolbox/iam-runtime/pkg/relationships"
+)
+
+// ParentRelation is the relation used to record the parent of a registered resource. Schemas refer
+// to it to inherit access from parents, such as with "parent->view". It is managed by
+// RegisterResource and UnregisterResource, and cannot be created or deleted directly.
+const ParentRelation = relationships.ParentRelation
+
+// maxDepth is the maximum number of relations followed when checking a permission.
+const maxDepth = 50
+
+var (
+	// ErrInvalid is returned when a request refers to types, relations, or permissions which are not
+	// valid according to the schema.
+	ErrInvalid = errors.New("invalid request")
+	// ErrNotFound is returned when a resource is not known.
+	ErrNotFound = relationships.ErrNotFound
+	// ErrAlreadyExists is returned when registering a resource which is already registered with a
+	// different type or parent.
+	ErrAlreadyExists = relationships.ErrAlreadyExists
+	// ErrParentNotFound is returned when registering a resource whose parent is not registered.
+	ErrParentNotFound = relationships.ErrParentNotFound
+	// ErrHasChildren is returned when unregistering a resource which is the parent of another.
+	ErrHasChildren = relationships.ErrHasChildren
+	// ErrMaxDepth is returned when checking a permission requires following too many relations.
+	ErrMaxDepth = errors.New("maximum check depth exceeded")
+	// ErrRevisionMismatch is returned when applying a schema in place of a revision which is not the
+	// current revision.
+	ErrRevisionMismatch = errors.New("schema revision mismatch")
+	// ErrSchemaConflict is returned when applying a schema under which existing relationships would
+	// not be valid.
+	ErrSchemaConflict = errors.New("existing relationships are not valid under schema")
+
+	errParentRelation = fmt.Errorf("%w: relation %q is managed by RegisterResource and UnregisterResource", ErrInvalid, ParentRelation)
+)
+
+// Resource is a resource registered with the engine.
+type Resource = relationships.Resource
+
+// Store is the storage relationships and resources are read from and written to. Subjects of
+// relationships are either resource IDs or subject sets of the form "<resource ID>#<relation>".
+type Store interface {
+	// CreateRelationships creates the given relationships on the resource with the given ID.
+	// Creating a relationship which already exists must succeed.
+	CreateRelationships(ctx context.Context, resourceID string, relationships []*authorization.Relationship) error
+
+	// DeleteRelationships deletes the given relationships from the resource with the given ID.
+	// Deleting a relationship which does not exist must succeed.
+	DeleteRelationships(ctx context.Context, resourceID string, relationships []*authorization.Relationship) error
+
+	// ListRelationships returns every relationship on the resource with the given ID.
+	ListRelationships(ctx context.Context, resourceID string) ([]*authorization.Relationship, error)
+
+	// ListAllRelationships returns every relationship in the store.
+	ListAllRelationships(ctx context.Context) ([]relationships.Relationship, error)
+
+	// GetResource returns the registered resource with the given ID, or an error wrapping
+	// ErrNotFound if it is not registered.
+	GetResource(ctx context.Context, id string) (Resource, error)
+
+	// RegisterResource registers the given resource and, if it has a parent, creates the
+	// ParentRelation relationship from the resource to its parent. Registering a resource which is
+	// already registered with the same type and parent must succeed without making changes. It
+	// returns an error wrapping ErrAlreadyExists if the resource is registered with a different type
+	// or parent, or ErrParentNotFound if the parent is not registered.
+	RegisterResource(ctx context.Context, resource Resource) error
+
+	// UnregisterResource removes the registered resource with the given ID, along with every
+	// relationship in which it is the resource or the subject. It returns an error wrapping
+	// ErrNotFound if the resource is not registered, or ErrHasChildren if it is the parent of
+	// another registered resource.
+	UnregisterResource(ctx context.Context, id string) error
+}
+
+// Config represents the configuration for an Engine.
+type Config struct {
+	// Schema is the schema access is computed with.
+	Schema *Schema
+	// Store is the storage relationships and resources are read from and written to.
+	Store Store
+	// ResourceType returns the type of a resource which is not registered, if it can be determined
+	// from its ID alone. If nil, only registered resources have a type.
+	ResourceType func(id string) (string, bool)
+}
+
+// Engine computes access from relationships according to a schema.
+type Engine struct {
+	schema       atomic.Pointer[Schema]
+	store        Store
+	resourceType func(id string) (string, bool)
+
+	// writeMu is held for reading by writes validated against the schema, and for writing while the
+	// schema is replaced, so that nothing is written which is valid only under the previous schema.
+	writeMu sync.RWMutex
+}
+
+// New creates a new Engine using the given config.
+func New(cfg Config) (*Engine, error) {
+	if cfg.Schema == nil || cfg.Store == nil {
+		return nil, errors.New("schema and store are required")
+	}
+
+	out := &Engine{
+		store:        cfg.Store,
+		resourceType: cfg.ResourceType,
+	}
+
+	out.schema.Store(cfg.Schema)
+
+	return out, nil
+}
+
+// Schema returns the schema currently in use.
+func (e *Engine) Schema() *Schema {
+	return e.schema.Load()
+}
+
+// ValidateSchema checks that every existing relationship is valid under the given schema, returning
+// an error wrapping ErrSchemaConflict if not.
+func (e *Engine) ValidateSchema(ctx context.Context, schema *Schema) error {
+	rels, err := e.store.ListAllRelationships(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, rel := range rels {
+		typ, err := e.ResourceType(ctx, rel.ResourceID)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		def, ok := schema.definitions[typ]
+		if !ok {
+			return fmt.Errorf("%w: %s: unknown resource type %q", ErrSchemaConflict, rel, typ)
+		}
+
+		if err := e.validateRelationship(ctx, def, rel.Relation, rel.SubjectID); err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrSchemaConflict, rel, err)
+		}
+	}
+
+	return nil
+}
+
+// ApplySchema replaces the schema used for all subsequent requests, returning its revision. If
+// expectedRevision is set, the schema is only applied in place of that revision, and an error
+// wrapping ErrRevisionMismatch is returned otherwise. It returns an error wrapping
+// ErrSchemaConflict if existing relationships are not valid under the schema.
+func (e *Engine) ApplySchema(ctx context.Context, schema *Schema, expectedRevision string) (string, error) {
+	e.writeMu.Lock()
+	defer e.writeMu.Unlock()
+
+	if current := e.Schema().Revision(); expectedRevision != "" && expectedRevision != current {
+		return "", fmt.Errorf("%w: current revision is %s", ErrRevisionMismatch, current)
+	}
+
+	if err := e.ValidateSchema(ctx, schema); err != nil {
+		return "", err
+	}
+
+	e.schema.Store(schema)
+
+	return schema.Revision(), nil
+}
+
+// ResourceType returns the type of the resource with the given ID, or an error wrapping
+// ErrNotFound if it is not known.
+func (e *Engine) ResourceType(ctx context.Context, id string) (string, error) {
+	res, err := e.store.GetResource(ctx, id)
+	if err == nil {
+		return res.Type, nil
+	}
+
+	if !errors.Is(err, ErrNotFound) {
+		return "", err
+	}
+
+	if e.resourceType != nil {
+		if typ, ok := e.resourceType(id); ok {
+			return typ, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrNotFound, id)
+}
+
+// Check reports whether the subject with the given ID has the named relation or permission on the
+// resource with the given ID. It returns an error wrapping ErrNotFound if the resource is not known,
+// or ErrInvalid if the resource's type has no such relation or permission.
+func (e *Engine) Check(ctx context.Context, resourceID, permission, subjectID string) (bool, error) {
+	c := &checker{
+		ctx:           ctx,
+		engine:        e,
+		schema:        e.Schema(),
+		subjectID:     subjectID,
+		relationships: make(map[string][]*authorization.Relationship),
+		results:       make(map[checkKey]checkResult),
+	}
+
+	typ, err := e.ResourceType(ctx, resourceID)
+	if err != nil {
+		return false, err
+	}
+
+	if !c.schema.HasPermission(typ, permission) {
+		return false, fmt.Errorf("%w: type %q has no relation or permission %q", ErrInvalid, typ, permission)
+	}
+
+	result, err := c.check(resourceID, permission, 0)
+	if err != nil {
+		return false, err
+	}
+
+	// A check which remains unresolved depends only on itself through cycles, and so is denied.
+	return result == resultAllowed, nil
+}
+
+// CreateRelationships validates the given relationships against the schema and creates them.
+func (e *Engine) CreateRelationships(ctx context.Context, resourceID string, relationships []*authorization.Relationship) error {
+	e.writeMu.RLock()
+	defer e.writeMu.RUnlock()
+
+	if err := e.validateRelationships(ctx, resourceID, relationships); err != nil {
+		return err
+	}
+
+	return e.store.CreateRelationships(ctx, resourceID, relationships)
+}
+
+// DeleteRelationships deletes the given relationships.
+func (e *Engine) DeleteRelationships(ctx context.Context, resourceID string, relationships []*authorization.Relationship) error {
+	if resourceID == "" {
+		return fmt.Errorf("%w: resource_id is required", ErrInvalid)
+	}
+
+	for _, rel := range relationships {
+		if rel.GetRelation() == "" || rel.GetSubjectId() == "" {
+			return fmt.Errorf("%w: relation and subject_id are required", ErrInvalid)
+		}
+
+		if rel.GetRelation() == ParentRelation {
+			return errParentRelation
+		}
+	}
+
+	return e.store.DeleteRelationships(ctx, resourceID, relationships)
+}
+
+// RegisterResource validates the given resource's type and parent against the schema and
+// registers it.
+func (e *Engine) RegisterResource(ctx context.Context, resource Resource) error {
+	e.writeMu.RLock()
+	defer e.writeMu.RUnlock()
+
+	schema := e.Schema()
+
+	if resource.ID == "" {
+		return fmt.Errorf("%w: resource_id is required", ErrInvalid)
+	}
+
+	def, ok := schema.definitions[resource.Type]
+	if !ok {
+		return fmt.Errorf("%w: unknown resource type %q", ErrInvalid, resource.Type)
+	}
+
+	if resource.ParentID == "" {
+		return e.store.RegisterResource(ctx, resource)
+	}
+
+	parent, err := e.store.GetResource(ctx, resource.ParentID)
+	if errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("%w: %s", ErrParentNotFound, resource.ParentID)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	rel, ok := def.relations[ParentRelation]
+	if !ok || !rel.allows(parent.Type, "") {
+		return fmt.Errorf("%w: type %q is not a valid parent for type %q", ErrInvalid, parent.Type, resource.Type)
+	}
+
+	return e.store.RegisterResource(ctx, resource)
+}
+
+// UnregisterResource unregisters the resource with the given ID, which must be registered with the
+// given type.
+func (e *Engine) UnregisterResource(ctx context.Context, id, resourceType string) error {
+	if id == "" {
+		return fmt.Errorf("%w: resource_id is required", ErrInvalid)
+	}
+
+	res, err := e.store.GetResource(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if res.Type != resourceType {
+		return fmt.Errorf("%w: resource %q has type %q, not %q", ErrInvalid, id, res.Type, resourceType)
+	}
+
+	return e.store.UnregisterResource(ctx, id)
+}
+
+func (e *Engine) validateRelationships(ctx context.Context, resourceID string, relationships []*authorization.Relationship) error {
+	typ, err := e.ResourceType(ctx, resourceID)
+	if errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("%w: unknown resource %q", ErrInvalid, resourceID)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	def, ok := e.Schema().definitions[typ]
+	if !ok {
+		return fmt.Errorf("%w: unknown resource type %q", ErrInvalid, typ)
+	}
+
+	for _, r := range relationships {
+		if r.GetRelation() == ParentRelation {
+			return errParentRelation
+		}
+
+		if err := e.validateRelationship(ctx, def, r.GetRelation(), r.GetSubjectId()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateRelationship checks that a relationship with the given relation and subject is valid on
+// resources of the given definition.
+func (e *Engine) validateRelationship(ctx context.Context, def *definition, relation, subject string) error {
+	rel, ok := def.relations[relation]
+	if !ok {
+		return fmt.Errorf("%w: type %q has no relation %q", ErrInvalid, def.name, relation)
+	}
+
+	subjectID, subjectRel, isSet := strings.Cut(subject, "#")
+	if subjectID == "" || (isSet && subjectRel == "") {
+		return fmt.Errorf("%w: invalid subject %q", ErrInvalid, subject)
+	}
+
+	if len(rel.subjectTypes) == 0 {
+		return nil
+	}
+
+	// Subjects whose type is not known, such as users which are never registered, are allowed.
+	subjectType, err := e.ResourceType(ctx, subjectID)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !rel.allows(subjectType, subjectRel) {
+		return fmt.Errorf("%w: relation %q on type %q does not allow subject %q", ErrInvalid, rel.name, def.name, subject)
+	}
+
+	return nil
+}
+
+func (r *relation) allows(typ, subjectRel string) bool {
+	// Relations without subject types allow any subject.
+	if len(r.subjectTypes) == 0 {
+		return true
+	}
+
+	for _, st := range r.subjectTypes {
+		if st.typ == typ && st.relation == subjectRel {
+			return true
+		}
+	}
+
+	return false
+}
+
+type checkKey struct {
+	resourceID string
+	name       string
+}
+
+// checkResult is the result of checking a relation or permission. Checks which depend on a check
+// still in progress, through a cycle in the relationship graph, may be unresolved.
+type checkResult int
+
+const (
+	resultPending checkResult = iota + 1
+	resultAllowed
+	resultDenied
+	resultUnresolved
+)
+
+// union returns the result of r + other. An unresolved result is combined as if it could be either
+// allowed or denied, such that the result is only resolved if it is the same either way.
+func (r checkResult) union(other checkResult) checkResult {
+	switch {
+	case r == resultAllowed || other == resultAllowed:
+		return resultAllowed
+	case r == resultUnresolved || other == resultUnresolved:
+		return resultUnresolved
+	default:
+		return resultDenied
+	}
+}
+
+// intersection returns the result of r & other.
+func (r checkResult) intersection(other checkResult) checkResult {
+	switch {
+	case r == resultDenied || other == resultDenied:
+		return resultDenied
+	case r == resultUnresolved || other == resultUnresolved:
+		return resultUnresolved
+	default:
+		return resultAllowed
+	}
+}
+
+// exclusion returns the result of r - other.
+func (r checkResult) exclusion(other checkResult) checkResult {
+	switch other {
+	case resultAllowed:
+		return resultDenied
+	case resultDenied:
+		return r
+	default:
+		return r.intersection(resultUnresolved)
+	}
+}
+
+// checker evaluates a single Check request, caching relationships and intermediate results.
+type checker struct {
+	ctx           context.Context
+	engine        *Engine
+	schema        *Schema
+	subjectID     string
+	relationships map[string][]*authorization.Relationship
+	results       map[checkKey]checkResult
+	// cycles counts the cycles cut while checking.
+	cycles int
+}
+
+// check returns whether the subject has the named relation or permission on the resource.
+// Resources whose type is not known, and types without the named relation or permission, do not
+// grant access. Checks which are reached again while still in progress are cut as unresolved, and
+// only results which did not depend on an unresolved check are cached.
+func (c *checker) check(resourceID, name string, depth int) (checkResult, error) {
+	if depth > maxDepth {
+		return resultDenied, ErrMaxDepth
+	}
+
+	key := checkKey{resourceID, name}
+
+	switch result := c.results[key]; result {
+	case resultAllowed, resultDenied:
+		return result, nil
+	case resultPending:
+		c.cycles++
+
+		return resultUnresolved, nil
+	}
+
+	c.results[key] = resultPending
+	cycles := c.cycles
+
+	result, err := c.evaluate(resourceID, name, depth)
+	if err != nil {
+		return resultDenied, err
+	}
+
+	if c.cycles == cycles {
+		c.results[key] = result
+	} else {
+		delete(c.results, key)
+	}
+
+	return result, nil
+}
+
+func (c *checker) evaluate(resourceID, name string, depth int) (checkResult, error) {
+	typ, err := c.engine.ResourceType(c.ctx, resourceID)
+	if errors.Is(err, ErrNotFound) {
+		return resultDenied, nil
+	}
+
+	if err != nil {
+		return resultDenied, err
+	}
+
+	def, ok := c.schema.definitions[typ]
+	if !ok {
+		return resultDenied, nil
+	}
+
+	if _, ok := def.relations[name]; ok {
+		return c.checkRelation(resourceID, name, depth)
+	}
+
+	if e, ok := def.permissions[name]; ok {
+		return c.evaluateExpr(resourceID, e, depth)
+	}
+
+	return resultDenied, nil
+}
+
+func (c *checker) checkRelation(resourceID, relation string, depth int) (checkResult, error) {
+	subjects, err := c.subjects(resourceID, relation)
+	if err != nil {
+		return resultDenied, err
+	}
+
+	out := resultDenied
+
+	for _, subject := range subjects {
+		subjectID, subjectRel, isSet := strings.Cut(subject, "#")
+		if !isSet {
+			if subjectID == c.subjectID {
+				return resultAllowed, nil
+			}
+
+			continue
+		}
+
+		result, err := c.check(subjectID, subjectRel, depth+1)
+		if err != nil {
+			return resultDenied, err
+		}
+
+		if out = out.union(result); out == resultAllowed {
+			return out, nil
+		}
+	}
+
+	return out, nil
+}
+
+func (c *checker) evaluateExpr(resourceID string, e *expr, depth int) (checkResult, error) {
+	switch e.op {
+	case opRef:
+		return c.check(resourceID, e.name, depth+1)
+	case opArrow:
+		subjects, err := c.subjects(resourceID, e.name)
+		if err != nil {
+			return resultDenied, err
+		}
+
+		out := resultDenied
+
+		for _, subject := range subjects {
+			// Arrows follow relationships to resources, not to subject sets.
+			if strings.Contains(subject, "#") {
+				continue
+			}
+
+			result, err := c.check(subject, e.target, depth+1)
+			if err != nil {
+				return resultDenied, err
+			}
+
+			if out = out.union(result); out == resultAllowed {
+				return out, nil
+			}
+		}
+
+		return out, nil
+	}
+
+	left, err := c.evaluateExpr(resourceID, e.left, depth)
+	if err != nil {
+		return resultDenied, err
+	}
+
+	// Skip evaluating the right side when it cannot change the result.
+	switch {
+	case e.op == opUnion && left == resultAllowed:
+		return resultAllowed, nil
+	case e.op != opUnion && left == resultDenied:
+		return resultDenied, nil
+	}
+
+	right, err := c.evaluateExpr(resourceID, e.right, depth)
+	if err != nil {
+		return resultDenied, err
+	}
+
+	switch e.op {
+	case opUnion:
+		return left.union(right), nil
+	case opIntersection:
+		return left.intersection(right), nil
+	default:
+		return left.exclusion(right), nil
+	}
+}
+
+// subjects returns the subjects of the given relation on the resource.
+func (c *checker) subjects(resourceID, relation string) ([]string, error) {
+	rels, ok := c.relationships[resourceID]
+	if !ok {
+		var err error
+
+		rels, err = c.engine.store.ListRelationships(c.ctx, resourceID)
+		if err != nil {
+			return nil, err
+		}
+
+		c.relationships[resourceID] = rels
+	}
+
+	var out []string
+
+	for _, rel := range rels {
+		if rel.GetRelation() == relation {
+			out = append(out, rel.GetSubjectId())
+		}
+	}
+
+	return out, nil
+}
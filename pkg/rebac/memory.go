@@ -0,0 +1,182 @@
+package rebac
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	authorization "github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization/v2"
+	"github.com/metal-toolbox/iam-runtime/pkg/relationships"
+)
+
+type relationshipKey struct {
+	relation  string
+	subjectID string
+}
+
+// MemoryStore is a Store which keeps relationships and resources in memory.
+type MemoryStore struct {
+	mu            sync.RWMutex
+	relationships map[string]map[relationshipKey]struct{}
+	resources     map[string]Resource
+}
+
+// NewMemoryStore creates a new, empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		relationships: make(map[string]map[relationshipKey]struct{}),
+		resources:     make(map[string]Resource),
+	}
+}
+
+// CreateRelationships creates the given relationships on the resource with the given ID.
+func (s *MemoryStore) CreateRelationships(ctx context.Context, resourceID string, relationships []*authorization.Relationship) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.createRelationships(resourceID, relationships)
+
+	return nil
+}
+
+func (s *MemoryStore) createRelationships(resourceID string, relationships []*authorization.Relationship) {
+	rels, ok := s.relationships[resourceID]
+	if !ok {
+		rels = make(map[relationshipKey]struct{})
+		s.relationships[resourceID] = rels
+	}
+
+	for _, rel := range relationships {
+		rels[relationshipKey{rel.GetRelation(), rel.GetSubjectId()}] = struct{}{}
+	}
+}
+
+// DeleteRelationships deletes the given relationships from the resource with the given ID.
+func (s *MemoryStore) DeleteRelationships(ctx context.Context, resourceID string, relationships []*authorization.Relationship) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rels := s.relationships[resourceID]
+
+	for _, rel := range relationships {
+		delete(rels, relationshipKey{rel.GetRelation(), rel.GetSubjectId()})
+	}
+
+	return nil
+}
+
+// ListRelationships returns every relationship on the resource with the given ID.
+func (s *MemoryStore) ListRelationships(ctx context.Context, resourceID string) ([]*authorization.Relationship, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var out []*authorization.Relationship
+
+	for key := range s.relationships[resourceID] {
+		rel := &authorization.Relationship{
+			Relation:  key.relation,
+			SubjectId: key.subjectID,
+		}
+
+		out = append(out, rel)
+	}
+
+	return out, nil
+}
+
+// ListAllRelationships returns every relationship in the store.
+func (s *MemoryStore) ListAllRelationships(ctx context.Context) ([]relationships.Relationship, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var out []relationships.Relationship
+
+	for resourceID, rels := range s.relationships {
+		for key := range rels {
+			rel := relationships.Relationship{
+				ResourceID: resourceID,
+				Relation:   key.relation,
+				SubjectID:  key.subjectID,
+			}
+
+			out = append(out, rel)
+		}
+	}
+
+	return out, nil
+}
+
+// GetResource returns the registered resource with the given ID.
+func (s *MemoryStore) GetResource(ctx context.Context, id string) (Resource, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res, ok := s.resources[id]
+	if !ok {
+		return Resource{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	return res, nil
+}
+
+// RegisterResource registers the given resource.
+func (s *MemoryStore) RegisterResource(ctx context.Context, resource Resource) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.resources[resource.ID]; ok {
+		if existing != resource {
+			return fmt.Errorf("%w: %s", ErrAlreadyExists, resource.ID)
+		}
+
+		return nil
+	}
+
+	if resource.ParentID != "" {
+		if _, ok := s.resources[resource.ParentID]; !ok {
+			return fmt.Errorf("%w: %s", ErrParentNotFound, resource.ParentID)
+		}
+
+		parent := &authorization.Relationship{
+			Relation:  ParentRelation,
+			SubjectId: resource.ParentID,
+		}
+
+		s.createRelationships(resource.ID, []*authorization.Relationship{parent})
+	}
+
+	s.resources[resource.ID] = resource
+
+	return nil
+}
+
+// UnregisterResource removes the registered resource with the given ID and its relationships.
+func (s *MemoryStore) UnregisterResource(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.resources[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	for _, res := range s.resources {
+		if res.ParentID == id {
+			return fmt.Errorf("%w: %s", ErrHasChildren, id)
+		}
+	}
+
+	delete(s.resources, id)
+	delete(s.relationships, id)
+
+	for _, rels := range s.relationships {
+		for key := range rels {
+			subjectID, _, _ := strings.Cut(key.subjectID, "#")
+			if subjectID == id {
+				delete(rels, key)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package rebac
+
+import (
+	"context"
+	"errors"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/policyadmin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// PolicyAdminServer is a PolicyAdmin server which manages the schema used by an Engine. Schemas are
+// written in the SchemaFormat format.
+type PolicyAdminServer struct {
+	policyadmin.UnimplementedPolicyAdminServer
+
+	engine *Engine
+}
+
+// NewPolicyAdminServer creates a new PolicyAdminServer managing the schema of the given engine.
+func NewPolicyAdminServer(engine *Engine) *PolicyAdminServer {
+	return &PolicyAdminServer{
+		engine: engine,
+	}
+}
+
+// GetSchema returns the schema currently used by the engine.
+func (s *PolicyAdminServer) GetSchema(ctx context.Context, req *policyadmin.GetSchemaRequest) (*policyadmin.GetSchemaResponse, error) {
+	schema := s.engine.Schema()
+
+	out := &policyadmin.GetSchemaResponse{
+		Schema: &policyadmin.Schema{
+			Format:  SchemaFormat,
+			Content: schema.String(),
+		},
+		Revision: schema.Revision(),
+	}
+
+	return out, nil
+}
+
+// ValidateSchema reports whether the given schema could be applied, which requires it to parse and
+// every existing relationship to be valid under it.
+func (s *PolicyAdminServer) ValidateSchema(ctx context.Context, req *policyadmin.ValidateSchemaRequest) (*policyadmin.ValidateSchemaResponse, error) {
+	schema, err := parseSchema(req.GetSchema())
+
+	var schemaErr *SchemaError
+
+	switch {
+	case errors.As(err, &schemaErr):
+		out := &policyadmin.ValidateSchemaResponse{
+			Errors: []*policyadmin.SchemaError{
+				{
+					Message: schemaErr.Message,
+					Line:    uint32(schemaErr.Line),
+					Column:  uint32(schemaErr.Column),
+				},
+			},
+		}
+
+		return out, nil
+	case err != nil:
+		return nil, err
+	}
+
+	err = s.engine.ValidateSchema(ctx, schema)
+	if errors.Is(err, ErrSchemaConflict) {
+		out := &policyadmin.ValidateSchemaResponse{
+			Errors: []*policyadmin.SchemaError{
+				{Message: err.Error()},
+			},
+		}
+
+		return out, nil
+	}
+
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	out := &policyadmin.ValidateSchemaResponse{
+		Valid: true,
+	}
+
+	return out, nil
+}
+
+// ApplySchema replaces the schema used by the engine.
+func (s *PolicyAdminServer) ApplySchema(ctx context.Context, req *policyadmin.ApplySchemaRequest) (*policyadmin.ApplySchemaResponse, error) {
+	schema, err := parseSchema(req.GetSchema())
+
+	var schemaErr *SchemaError
+	if errors.As(err, &schemaErr) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid schema: %v", schemaErr)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	revision, err := s.engine.ApplySchema(ctx, schema, req.GetExpectedRevision())
+	if errors.Is(err, ErrRevisionMismatch) || errors.Is(err, ErrSchemaConflict) {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	out := &policyadmin.ApplySchemaResponse{
+		Revision: revision,
+	}
+
+	return out, nil
+}
+
+// parseSchema parses the given schema, returning an InvalidArgument error if it is not in the
+// SchemaFormat format, or a *SchemaError if it is not valid.
+func parseSchema(schema *policyadmin.Schema) (*Schema, error) {
+	if schema.GetFormat() != SchemaFormat {
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported schema format %q, expected %q", schema.GetFormat(), SchemaFormat)
+	}
+
+	return ParseSchema(schema.GetContent())
+}
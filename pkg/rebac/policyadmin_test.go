@@ -0,0 +1,118 @@
+package rebac
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/policyadmin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGetSchema(t *testing.T) {
+	srv := NewPolicyAdminServer(newTestEngine(t, prefixType))
+
+	resp, err := srv.GetSchema(context.Background(), &policyadmin.GetSchemaRequest{})
+	if err != nil {
+		t.Fatalf("error getting schema: %v", err)
+	}
+
+	if resp.GetSchema().GetFormat() != SchemaFormat || resp.GetSchema().GetContent() != testSchema {
+		t.Errorf("got schema %v, want the test schema", resp.GetSchema())
+	}
+
+	if resp.GetRevision() == "" {
+		t.Error("got no revision")
+	}
+}
+
+func TestApplySchema(t *testing.T) {
+	engine := newTestEngine(t, prefixType)
+	srv := NewPolicyAdminServer(engine)
+
+	ctx := context.Background()
+
+	relate(t, engine, "doc-a#viewer@user-alice", "folder-x#viewer@group-eng#member")
+
+	getResp, err := srv.GetSchema(ctx, &policyadmin.GetSchemaRequest{})
+	if err != nil {
+		t.Fatalf("error getting schema: %v", err)
+	}
+
+	revision := getResp.GetRevision()
+
+	// Adds an editor relation to docs, which views them.
+	editable := strings.Replace(testSchema, "relation banned: user | group#member\n  permission see", "relation banned: user | group#member\n  relation editor: user\n  permission see", 1)
+	editable = strings.Replace(editable, "(viewer + folder->view)", "(viewer + editor + folder->view)", 1)
+
+	// Removes the viewer relation from folders, which existing relationships use.
+	noFolderViewer := strings.Replace(testSchema, "  relation viewer: user | group#member\n  permission view = viewer + parent->view", "  permission view = parent->view", 1)
+
+	// Allows only users to view folders, which the existing group viewer is not.
+	userFolderViewer := strings.Replace(testSchema, "  relation viewer: user | group#member\n  permission view = viewer + parent->view", "  relation viewer: user\n  permission view = viewer + parent->view", 1)
+
+	tests := []struct {
+		name             string
+		format           string
+		content          string
+		expectedRevision string
+		wantCode         codes.Code
+	}{
+		{"unsupported format", "spicedb", editable, "", codes.InvalidArgument},
+		{"invalid schema", SchemaFormat, "definition doc {", "", codes.InvalidArgument},
+		{"removes used relation", SchemaFormat, noFolderViewer, "", codes.FailedPrecondition},
+		{"disallows used subject", SchemaFormat, userFolderViewer, "", codes.FailedPrecondition},
+		{"stale revision", SchemaFormat, editable, "0000000000000000", codes.FailedPrecondition},
+		{"valid", SchemaFormat, editable, revision, codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema := &policyadmin.Schema{
+				Format:  tt.format,
+				Content: tt.content,
+			}
+
+			validateResp, err := srv.ValidateSchema(ctx, &policyadmin.ValidateSchemaRequest{Schema: schema})
+
+			switch {
+			case tt.format != SchemaFormat:
+				if status.Code(err) != codes.InvalidArgument {
+					t.Errorf("validating: got error %v, want %s", err, codes.InvalidArgument)
+				}
+			case err != nil:
+				t.Errorf("error validating: %v", err)
+			case tt.expectedRevision == "" && validateResp.GetValid() != (tt.wantCode == codes.OK):
+				t.Errorf("validating: got valid %t, want %t", validateResp.GetValid(), tt.wantCode == codes.OK)
+			case !validateResp.GetValid() && len(validateResp.GetErrors()) == 0:
+				t.Error("validating: got invalid result without errors")
+			}
+
+			req := &policyadmin.ApplySchemaRequest{
+				Schema:           schema,
+				ExpectedRevision: tt.expectedRevision,
+			}
+
+			resp, err := srv.ApplySchema(ctx, req)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("applying: got code %s, want %s: %v", code, tt.wantCode, err)
+			}
+
+			if err == nil && resp.GetRevision() == revision {
+				t.Error("got unchanged revision")
+			}
+		})
+	}
+
+	if got := engine.Schema().String(); got != editable {
+		t.Fatalf("got schema %q after applying, want %q", got, editable)
+	}
+
+	relate(t, engine, "doc-b#editor@user-bob")
+
+	runChecks(t, engine, []checkTest{
+		{"doc-a", "view", "user-alice", true},
+		{"doc-b", "view", "user-bob", true},
+	})
+}
@@ -0,0 +1,102 @@
+package rebac
+
+import (
+	"context"
+	"fmt"
+
+	authorization "github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization/v2"
+	"github.com/metal-toolbox/iam-runtime/pkg/roles"
+)
+
+// newRoles creates a new roles.Roles which assigns roles by writing relationships with an engine.
+// Each role's relation must be defined on its resource type, and its actions must be relations or
+// permissions of that type, so that assigning a role grants the actions it lists.
+func newRoles(engine *Engine, definitions []roles.Definition) (*roles.Roles, error) {
+	schema := engine.Schema()
+
+	for _, role := range definitions {
+		def, ok := schema.definitions[role.ResourceType]
+		if !ok {
+			return nil, fmt.Errorf("role %s: unknown resource type %q", role.Name, role.ResourceType)
+		}
+
+		relation := role.Relation
+		if relation == "" {
+			relation = role.Name
+		}
+
+		if _, ok := def.relations[relation]; !ok || relation == ParentRelation {
+			return nil, fmt.Errorf("role %s: type %q has no relation %q", role.Name, role.ResourceType, relation)
+		}
+
+		for _, action := range role.Actions {
+			if !def.has(action) {
+				return nil, fmt.Errorf("role %s: type %q has no relation or permission %q", role.Name, role.ResourceType, action)
+			}
+		}
+	}
+
+	return roles.New(roleStore{engine}, definitions...)
+}
+
+// roleStore is a roles.RelationshipStore which writes relationships with an engine. Resources are
+// identified by ID alone in the engine, so each resource must be known to have the type it is
+// given with.
+type roleStore struct {
+	engine *Engine
+}
+
+// CreateRelationships validates the given relationships against the schema and creates them.
+func (s roleStore) CreateRelationships(ctx context.Context, resource *authorization.ResourceReference, relationships []*authorization.Relationship) error {
+	if err := s.checkType(ctx, resource); err != nil {
+		return toStatus(err)
+	}
+
+	if err := s.engine.CreateRelationships(ctx, resource.GetResourceId(), relationships); err != nil {
+		return toStatus(err)
+	}
+
+	return nil
+}
+
+// DeleteRelationships deletes the given relationships.
+func (s roleStore) DeleteRelationships(ctx context.Context, resource *authorization.ResourceReference, relationships []*authorization.Relationship) error {
+	if err := s.checkType(ctx, resource); err != nil {
+		return toStatus(err)
+	}
+
+	if err := s.engine.DeleteRelationships(ctx, resource.GetResourceId(), relationships); err != nil {
+		return toStatus(err)
+	}
+
+	return nil
+}
+
+// ListRelationships returns every relationship on the given resource.
+func (s roleStore) ListRelationships(ctx context.Context, resource *authorization.ResourceReference) ([]*authorization.Relationship, error) {
+	if err := s.checkType(ctx, resource); err != nil {
+		return nil, toStatus(err)
+	}
+
+	out, err := s.engine.store.ListRelationships(ctx, resource.GetResourceId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return out, nil
+}
+
+// checkType returns an error wrapping ErrNotFound if the given resource is not known, or ErrInvalid
+// if it is known with another type.
+func (s roleStore) checkType(ctx context.Context, resource *authorization.ResourceReference) error {
+	typ, err := s.engine.ResourceType(ctx, resource.GetResourceId())
+	if err != nil {
+		return err
+	}
+
+	if typ != resource.GetResourceType() {
+		return fmt.Errorf("%w: resource %q has type %q, not %q", ErrInvalid, resource.GetResourceId(), typ, resource.GetResourceType())
+	}
+
+	return nil
+}
@@ -0,0 +1,147 @@
+package rebac
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	authorization "github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization/v2"
+	"github.com/metal-toolbox/iam-runtime/pkg/roles"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var testRoles = []roles.Definition{
+	{ResourceType: "folder", Name: "viewer", Description: "Views the folder", Actions: []string{"view", "restricted"}},
+	{ResourceType: "doc", Name: "reader", Relation: "viewer", Actions: []string{"view"}},
+}
+
+func TestNewServerRoles(t *testing.T) {
+	engine := newTestEngine(t, nil)
+
+	tests := []struct {
+		name string
+		role roles.Definition
+	}{
+		{"unknown type", roles.Definition{ResourceType: "project", Name: "viewer"}},
+		{"unknown relation", roles.Definition{ResourceType: "folder", Name: "editor"}},
+		{"permission", roles.Definition{ResourceType: "folder", Name: "view"}},
+		{"parent relation", roles.Definition{ResourceType: "folder", Name: "parent"}},
+		{"unknown action", roles.Definition{ResourceType: "folder", Name: "viewer", Actions: []string{"edit"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewServer(engine, nil, tt.role); err == nil {
+				t.Error("got no error")
+			}
+		})
+	}
+
+	srv, err := NewServer(engine, nil)
+	if err != nil {
+		t.Fatalf("error creating server: %v", err)
+	}
+
+	if slices.Contains(srv.RPCs(), "AssignRole") {
+		t.Errorf("got RPCs %v without roles, want no role RPCs", srv.RPCs())
+	}
+
+	if _, err := srv.ListRoles(context.Background(), &authorization.ListRolesRequest{ResourceType: "folder"}); status.Code(err) != codes.Unimplemented {
+		t.Errorf("got error %v listing roles without roles, want %s", err, codes.Unimplemented)
+	}
+}
+
+func TestRoles(t *testing.T) {
+	engine := newTestEngine(t, nil)
+
+	ctx := context.Background()
+
+	for _, resource := range []Resource{{ID: "folder-a", Type: "folder"}, {ID: "doc-a", Type: "doc"}} {
+		if err := engine.RegisterResource(ctx, resource); err != nil {
+			t.Fatalf("error registering %s: %v", resource.ID, err)
+		}
+	}
+
+	srv, err := NewServer(engine, nil, testRoles...)
+	if err != nil {
+		t.Fatalf("error creating server: %v", err)
+	}
+
+	for _, rpc := range []string{"ListRoles", "AssignRole", "UnassignRole", "ListRoleBindings"} {
+		if !slices.Contains(srv.RPCs(), rpc) {
+			t.Errorf("RPCs %v do not include %s", srv.RPCs(), rpc)
+		}
+	}
+
+	folder := &authorization.ResourceReference{ResourceType: "folder", ResourceId: "folder-a"}
+	doc := &authorization.ResourceReference{ResourceType: "doc", ResourceId: "doc-a"}
+
+	for _, req := range []*authorization.AssignRoleRequest{
+		{Resource: folder, Role: "viewer", SubjectId: "user-alice"},
+		{Resource: doc, Role: "reader", SubjectId: "user-bob"},
+	} {
+		if _, err := srv.AssignRole(ctx, req); err != nil {
+			t.Fatalf("error assigning role: %v", err)
+		}
+	}
+
+	// Roles are assigned by relationships, from which the schema computes permissions.
+	runChecks(t, engine, []checkTest{
+		{"folder-a", "view", "user-alice", true},
+		{"doc-a", "view", "user-bob", true},
+		{"doc-a", "view", "user-alice", false},
+	})
+
+	resp, err := srv.ListRoleBindings(ctx, &authorization.ListRoleBindingsRequest{Resource: doc})
+	if err != nil {
+		t.Fatalf("error listing role bindings: %v", err)
+	}
+
+	if len(resp.GetRoleBindings()) != 1 || resp.GetRoleBindings()[0].GetRole() != "reader" || resp.GetRoleBindings()[0].GetSubjectId() != "user-bob" {
+		t.Errorf("got role bindings %v, want reader for user-bob", resp.GetRoleBindings())
+	}
+
+	if _, err := srv.UnassignRole(ctx, &authorization.UnassignRoleRequest{Resource: doc, Role: "reader", SubjectId: "user-bob"}); err != nil {
+		t.Fatalf("error unassigning role: %v", err)
+	}
+
+	runChecks(t, engine, []checkTest{
+		{"doc-a", "view", "user-bob", false},
+	})
+
+	tests := []struct {
+		name     string
+		req      *authorization.AssignRoleRequest
+		wantCode codes.Code
+	}{
+		{
+			name:     "resource of another type",
+			req:      &authorization.AssignRoleRequest{Resource: &authorization.ResourceReference{ResourceType: "doc", ResourceId: "folder-a"}, Role: "reader", SubjectId: "user-bob"},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "unknown resource",
+			req:      &authorization.AssignRoleRequest{Resource: &authorization.ResourceReference{ResourceType: "doc", ResourceId: "doc-b"}, Role: "reader", SubjectId: "user-bob"},
+			wantCode: codes.NotFound,
+		},
+		{
+			name:     "subject of disallowed type",
+			req:      &authorization.AssignRoleRequest{Resource: doc, Role: "reader", SubjectId: "folder-a"},
+			wantCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := srv.AssignRole(ctx, tt.req); status.Code(err) != tt.wantCode {
+				t.Errorf("got error %v, want %s", err, tt.wantCode)
+			}
+		})
+	}
+
+	_, err = srv.ListRoleBindings(ctx, &authorization.ListRoleBindingsRequest{Resource: &authorization.ResourceReference{ResourceType: "doc", ResourceId: "folder-a"}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("got error %v listing bindings of resource of another type, want %s", err, codes.InvalidArgument)
+	}
+}
@@ -0,0 +1,549 @@
+package rebac
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// SchemaFormat is the format name of schemas written in the language parsed by ParseSchema.
+const SchemaFormat = "rebac"
+
+// Schema describes the types of resources, the relations each type of resource may have, and the
+// permissions computed from them. Schemas are written in a language similar to that of SpiceDB:
+//
+//	// Subjects are defined like any other type.
+//	definition user {}
+//
+//	definition tenant {
+//	  relation admin: user
+//	  relation member: user | group#member
+//	  permission view = member + admin
+//	}
+//
+//	definition project {
+//	  relation parent: tenant
+//	  relation editor: user
+//	  relation banned: user
+//	  permission edit = (editor + parent->admin) - banned
+//	  permission view = edit + parent->view
+//	}
+//
+// A relation may list the types of subject it allows, where "type#relation" allows the set of
+// subjects with the given relation on a resource of the given type. A permission is an expression
+// over relations and permissions of the same type, combined with the union (+), intersection (&),
+// and exclusion (-) operators, which are left-associative and of equal precedence. The arrow
+// operator (relation->permission) evaluates a permission on each subject of a relation, such as a
+// resource's parent.
+type Schema struct {
+	source      string
+	definitions map[string]*definition
+}
+
+type definition struct {
+	name        string
+	relations   map[string]*relation
+	permissions map[string]*expr
+}
+
+type relation struct {
+	name         string
+	subjectTypes []subjectType
+}
+
+type subjectType struct {
+	typ      string
+	relation string
+}
+
+type op int
+
+const (
+	opRef op = iota
+	opArrow
+	opUnion
+	opIntersection
+	opExclusion
+)
+
+// expr is a node in a permission expression. References use name, arrows use name for the relation
+// and target for the permission evaluated on its subjects, and operators use left and right.
+type expr struct {
+	op          op
+	name        string
+	target      string
+	left, right *expr
+	pos         position
+}
+
+// SchemaError describes an error in a schema.
+type SchemaError struct {
+	// Line is the 1-based line of the error.
+	Line int
+	// Column is the 1-based column of the error.
+	Column int
+	// Message describes the error.
+	Message string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+type position struct {
+	line, column int
+}
+
+func (p position) errorf(format string, args ...any) *SchemaError {
+	return &SchemaError{
+		Line:    p.line,
+		Column:  p.column,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// ParseSchema parses and validates a schema. Errors in the schema are returned as *SchemaError.
+func ParseSchema(src string) (*Schema, error) {
+	p := &parser{
+		lexer: lexer{src: src, pos: position{line: 1, column: 1}},
+	}
+
+	p.next()
+
+	out := &Schema{
+		source:      src,
+		definitions: make(map[string]*definition),
+	}
+
+	positions := make(map[string]position)
+
+	for p.tok.kind != tokEOF {
+		def, pos, err := p.parseDefinition(positions)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := out.definitions[def.name]; ok {
+			return nil, pos.errorf("duplicate definition %q", def.name)
+		}
+
+		out.definitions[def.name] = def
+	}
+
+	if err := out.validate(positions); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// String returns the source the schema was parsed from.
+func (s *Schema) String() string {
+	return s.source
+}
+
+// Revision returns an identifier for the schema, derived from its source.
+func (s *Schema) Revision() string {
+	sum := sha256.Sum256([]byte(s.source))
+
+	return hex.EncodeToString(sum[:8])
+}
+
+// Types returns the names of the types defined in the schema, in sorted order.
+func (s *Schema) Types() []string {
+	out := make([]string, 0, len(s.definitions))
+	for name := range s.definitions {
+		out = append(out, name)
+	}
+
+	sort.Strings(out)
+
+	return out
+}
+
+// HasPermission reports whether resources of the given type have a relation or permission with
+// the given name.
+func (s *Schema) HasPermission(resourceType, name string) bool {
+	def, ok := s.definitions[resourceType]
+	if !ok {
+		return false
+	}
+
+	return def.has(name)
+}
+
+func (d *definition) has(name string) bool {
+	if _, ok := d.relations[name]; ok {
+		return true
+	}
+
+	_, ok := d.permissions[name]
+
+	return ok
+}
+
+// validate checks references between definitions. positions holds the position of each relation
+// and permission, keyed by "type#name".
+func (s *Schema) validate(positions map[string]position) error {
+	for _, def := range s.definitions {
+		for _, rel := range def.relations {
+			for _, st := range rel.subjectTypes {
+				target, ok := s.definitions[st.typ]
+				if !ok {
+					return positions[def.name+"#"+rel.name].errorf("relation %q allows unknown type %q", rel.name, st.typ)
+				}
+
+				if st.relation != "" && !target.has(st.relation) {
+					return positions[def.name+"#"+rel.name].errorf("relation %q allows unknown relation %q on type %q", rel.name, st.relation, st.typ)
+				}
+			}
+		}
+
+		for _, e := range def.permissions {
+			if err := s.validateExpr(def, e); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *Schema) validateExpr(def *definition, e *expr) error {
+	switch e.op {
+	case opRef:
+		if !def.has(e.name) {
+			return e.pos.errorf("unknown relation or permission %q on type %q", e.name, def.name)
+		}
+	case opArrow:
+		rel, ok := def.relations[e.name]
+		if !ok {
+			return e.pos.errorf("arrow must start from a relation of type %q, not %q", def.name, e.name)
+		}
+
+		// If the relation's subject types are known, at least one of them must have the target.
+		if len(rel.subjectTypes) == 0 {
+			return nil
+		}
+
+		for _, st := range rel.subjectTypes {
+			if st.relation == "" && s.definitions[st.typ].has(e.target) {
+				return nil
+			}
+		}
+
+		return e.pos.errorf("no subject type of relation %q has relation or permission %q", e.name, e.target)
+	default:
+		if err := s.validateExpr(def, e.left); err != nil {
+			return err
+		}
+
+		return s.validateExpr(def, e.right)
+	}
+
+	return nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  position
+}
+
+type lexer struct {
+	src string
+	off int
+	pos position
+}
+
+func (l *lexer) advance(n int) {
+	for _, r := range l.src[l.off : l.off+n] {
+		if r == '\n' {
+			l.pos.line++
+			l.pos.column = 1
+		} else {
+			l.pos.column++
+		}
+	}
+
+	l.off += n
+}
+
+func (l *lexer) next() (token, error) {
+	for l.off < len(l.src) {
+		rest := l.src[l.off:]
+
+		switch {
+		case unicode.IsSpace(rune(rest[0])):
+			l.advance(1)
+		case strings.HasPrefix(rest, "//"):
+			n := strings.IndexByte(rest, '\n')
+			if n < 0 {
+				n = len(rest)
+			}
+
+			l.advance(n)
+		default:
+			return l.scan(rest)
+		}
+	}
+
+	return token{kind: tokEOF, pos: l.pos}, nil
+}
+
+func (l *lexer) scan(rest string) (token, error) {
+	start := l.pos
+
+	if isIdentChar(rest[0]) {
+		n := 1
+		for n < len(rest) && isIdentChar(rest[n]) {
+			n++
+		}
+
+		l.advance(n)
+
+		return token{kind: tokIdent, text: rest[:n], pos: start}, nil
+	}
+
+	if strings.HasPrefix(rest, "->") {
+		l.advance(2)
+
+		return token{kind: tokPunct, text: "->", pos: start}, nil
+	}
+
+	if strings.ContainsRune("{}():|#=+&-", rune(rest[0])) {
+		l.advance(1)
+
+		return token{kind: tokPunct, text: rest[:1], pos: start}, nil
+	}
+
+	return token{}, start.errorf("unexpected character %q", rest[0])
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+type parser struct {
+	lexer lexer
+	tok   token
+	err   error
+}
+
+func (p *parser) next() {
+	if p.err != nil {
+		return
+	}
+
+	p.tok, p.err = p.lexer.next()
+}
+
+// expect consumes the current token if it has the given kind and text (if text is not empty).
+func (p *parser) expect(kind tokenKind, text, what string) (token, error) {
+	if p.err != nil {
+		return token{}, p.err
+	}
+
+	if p.tok.kind != kind || (text != "" && p.tok.text != text) {
+		found := p.tok.text
+		if p.tok.kind == tokEOF {
+			found = "end of schema"
+		}
+
+		return token{}, p.tok.pos.errorf("expected %s, found %q", what, found)
+	}
+
+	tok := p.tok
+	p.next()
+
+	return tok, p.err
+}
+
+func (p *parser) parseDefinition(positions map[string]position) (*definition, position, error) {
+	kw, err := p.expect(tokIdent, "definition", `"definition"`)
+	if err != nil {
+		return nil, position{}, err
+	}
+
+	name, err := p.expect(tokIdent, "", "type name")
+	if err != nil {
+		return nil, position{}, err
+	}
+
+	if _, err := p.expect(tokPunct, "{", `"{"`); err != nil {
+		return nil, position{}, err
+	}
+
+	def := &definition{
+		name:        name.text,
+		relations:   make(map[string]*relation),
+		permissions: make(map[string]*expr),
+	}
+
+	for p.err == nil && !(p.tok.kind == tokPunct && p.tok.text == "}") {
+		kind, err := p.expect(tokIdent, "", `"relation", "permission", or "}"`)
+		if err != nil {
+			return nil, position{}, err
+		}
+
+		member, err := p.expect(tokIdent, "", kind.text+" name")
+		if err != nil {
+			return nil, position{}, err
+		}
+
+		if def.has(member.text) {
+			return nil, position{}, member.pos.errorf("duplicate relation or permission %q on type %q", member.text, def.name)
+		}
+
+		positions[def.name+"#"+member.text] = member.pos
+
+		switch kind.text {
+		case "relation":
+			rel, err := p.parseRelation(member.text)
+			if err != nil {
+				return nil, position{}, err
+			}
+
+			def.relations[rel.name] = rel
+		case "permission":
+			if _, err := p.expect(tokPunct, "=", `"="`); err != nil {
+				return nil, position{}, err
+			}
+
+			e, err := p.parseExpr()
+			if err != nil {
+				return nil, position{}, err
+			}
+
+			def.permissions[member.text] = e
+		default:
+			return nil, position{}, kind.pos.errorf(`expected "relation" or "permission", found %q`, kind.text)
+		}
+	}
+
+	if _, err := p.expect(tokPunct, "}", `"}"`); err != nil {
+		return nil, position{}, err
+	}
+
+	return def, kw.pos, nil
+}
+
+func (p *parser) parseRelation(name string) (*relation, error) {
+	rel := &relation{
+		name: name,
+	}
+
+	if p.tok.kind != tokPunct || p.tok.text != ":" {
+		return rel, p.err
+	}
+
+	for {
+		p.next()
+
+		typ, err := p.expect(tokIdent, "", "subject type")
+		if err != nil {
+			return nil, err
+		}
+
+		st := subjectType{
+			typ: typ.text,
+		}
+
+		if p.tok.kind == tokPunct && p.tok.text == "#" {
+			p.next()
+
+			subjectRel, err := p.expect(tokIdent, "", "relation name")
+			if err != nil {
+				return nil, err
+			}
+
+			st.relation = subjectRel.text
+		}
+
+		rel.subjectTypes = append(rel.subjectTypes, st)
+
+		if p.tok.kind != tokPunct || p.tok.text != "|" {
+			return rel, p.err
+		}
+	}
+}
+
+func (p *parser) parseExpr() (*expr, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.tok.kind == tokPunct {
+		var o op
+
+		switch p.tok.text {
+		case "+":
+			o = opUnion
+		case "&":
+			o = opIntersection
+		case "-":
+			o = opExclusion
+		default:
+			return left, nil
+		}
+
+		pos := p.tok.pos
+		p.next()
+
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &expr{op: o, left: left, right: right, pos: pos}
+	}
+
+	return left, p.err
+}
+
+func (p *parser) parseTerm() (*expr, error) {
+	if p.tok.kind == tokPunct && p.tok.text == "(" {
+		p.next()
+
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := p.expect(tokPunct, ")", `")"`); err != nil {
+			return nil, err
+		}
+
+		return e, nil
+	}
+
+	name, err := p.expect(tokIdent, "", "relation or permission name")
+	if err != nil {
+		return nil, err
+	}
+
+	if p.tok.kind != tokPunct || p.tok.text != "->" {
+		return &expr{op: opRef, name: name.text, pos: name.pos}, p.err
+	}
+
+	p.next()
+
+	target, err := p.expect(tokIdent, "", "relation or permission name")
+	if err != nil {
+		return nil, err
+	}
+
+	return &expr{op: opArrow, name: name.text, target: target.text, pos: name.pos}, nil
+}
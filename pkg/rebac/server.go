@@ -0,0 +1,236 @@
+package rebac
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
+	authorization "github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization/v2"
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/policyadmin"
+	"github.com/metal-toolbox/iam-runtime/pkg/roles"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server is an Authorization server which makes access decisions using an Engine. Each action in a
+// CheckAccess request names a relation or permission on the type of the requested resource.
+//
+// If roles are defined, the server also serves the role RPCs. A role is assigned by creating a
+// relationship with its relation, so the permissions it grants are those the schema computes from
+// that relation.
+type Server struct {
+	authorization.UnimplementedAuthorizationServer
+
+	engine *Engine
+	authn  authentication.AuthenticationServer
+	roles  *roles.Roles
+}
+
+// NewServer creates a new Server using the given engine, validating credentials with authn, and
+// serving the given roles.
+func NewServer(engine *Engine, authn authentication.AuthenticationServer, roleDefinitions ...roles.Definition) (*Server, error) {
+	out := &Server{
+		engine: engine,
+		authn:  authn,
+	}
+
+	if len(roleDefinitions) > 0 {
+		r, err := newRoles(engine, roleDefinitions)
+		if err != nil {
+			return nil, err
+		}
+
+		out.roles = r
+	}
+
+	return out, nil
+}
+
+// PolicyAdmin returns a PolicyAdmin server which manages the engine's schema.
+func (s *Server) PolicyAdmin() policyadmin.PolicyAdminServer {
+	return NewPolicyAdminServer(s.engine)
+}
+
+// RPCs returns the names of the Authorization RPCs the server implements.
+func (s *Server) RPCs() []string {
+	out := []string{
+		"CheckAccess",
+		"CreateRelationships",
+		"DeleteRelationships",
+		"RegisterResource",
+		"UnregisterResource",
+	}
+
+	if s.roles != nil {
+		out = append(out, "ListRoles", "AssignRole", "UnassignRole", "ListRoleBindings")
+	}
+
+	return out
+}
+
+// CheckAccess allows the request if the subject identified by the given credential has every
+// requested permission.
+func (s *Server) CheckAccess(ctx context.Context, req *authorization.CheckAccessRequest) (*authorization.CheckAccessResponse, error) {
+	if len(req.GetActions()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "actions are required")
+	}
+
+	validateReq := &authentication.ValidateCredentialRequest{
+		Credential: req.GetCredential(),
+	}
+
+	validateResp, err := s.authn.ValidateCredential(ctx, validateReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if validateResp.GetResult() != authentication.ValidateCredentialResponse_RESULT_VALID {
+		return nil, status.Error(codes.InvalidArgument, "invalid credential")
+	}
+
+	subjectID := validateResp.GetSubject().GetSubjectId()
+
+	result := authorization.CheckAccessResponse_RESULT_ALLOWED
+
+	for _, action := range req.GetActions() {
+		allowed, err := s.engine.Check(ctx, action.GetResourceId(), action.GetAction(), subjectID)
+		if errors.Is(err, ErrNotFound) || errors.Is(err, ErrInvalid) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		if err != nil {
+			return nil, toStatus(err)
+		}
+
+		if !allowed {
+			result = authorization.CheckAccessResponse_RESULT_DENIED
+
+			break
+		}
+	}
+
+	out := &authorization.CheckAccessResponse{
+		Result: result,
+	}
+
+	return out, nil
+}
+
+// CreateRelationships creates the given relationships.
+func (s *Server) CreateRelationships(ctx context.Context, req *authorization.CreateRelationshipsRequest) (*authorization.CreateRelationshipsResponse, error) {
+	if err := s.engine.CreateRelationships(ctx, req.GetResourceId(), req.GetRelationships()); err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &authorization.CreateRelationshipsResponse{}, nil
+}
+
+// DeleteRelationships deletes the given relationships.
+func (s *Server) DeleteRelationships(ctx context.Context, req *authorization.DeleteRelationshipsRequest) (*authorization.DeleteRelationshipsResponse, error) {
+	if err := s.engine.DeleteRelationships(ctx, req.GetResourceId(), req.GetRelationships()); err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &authorization.DeleteRelationshipsResponse{}, nil
+}
+
+// RegisterResource registers the given resource along with its parent.
+func (s *Server) RegisterResource(ctx context.Context, req *authorization.RegisterResourceRequest) (*authorization.RegisterResourceResponse, error) {
+	resource := Resource{
+		ID:   req.GetResource().GetResourceId(),
+		Type: req.GetResource().GetResourceType(),
+	}
+
+	if parent := req.GetParent(); parent != nil {
+		parentType, err := s.engine.ResourceType(ctx, parent.GetResourceId())
+		if errors.Is(err, ErrNotFound) {
+			return nil, status.Errorf(codes.FailedPrecondition, "parent resource %q not found", parent.GetResourceId())
+		}
+
+		if err != nil {
+			return nil, toStatus(err)
+		}
+
+		if parentType != parent.GetResourceType() {
+			return nil, status.Errorf(codes.InvalidArgument, "parent resource %q has type %q, not %q", parent.GetResourceId(), parentType, parent.GetResourceType())
+		}
+
+		resource.ParentID = parent.GetResourceId()
+	}
+
+	if err := s.engine.RegisterResource(ctx, resource); err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &authorization.RegisterResourceResponse{}, nil
+}
+
+// UnregisterResource unregisters the given resource and deletes its relationships.
+func (s *Server) UnregisterResource(ctx context.Context, req *authorization.UnregisterResourceRequest) (*authorization.UnregisterResourceResponse, error) {
+	resource := req.GetResource()
+
+	if err := s.engine.UnregisterResource(ctx, resource.GetResourceId(), resource.GetResourceType()); err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &authorization.UnregisterResourceResponse{}, nil
+}
+
+// ListRoles lists the roles defined for the requested resource type.
+func (s *Server) ListRoles(ctx context.Context, req *authorization.ListRolesRequest) (*authorization.ListRolesResponse, error) {
+	if s.roles == nil {
+		return s.UnimplementedAuthorizationServer.ListRoles(ctx, req)
+	}
+
+	return s.roles.ListRoles(ctx, req)
+}
+
+// AssignRole creates the relationship representing the requested role assignment.
+func (s *Server) AssignRole(ctx context.Context, req *authorization.AssignRoleRequest) (*authorization.AssignRoleResponse, error) {
+	if s.roles == nil {
+		return s.UnimplementedAuthorizationServer.AssignRole(ctx, req)
+	}
+
+	return s.roles.AssignRole(ctx, req)
+}
+
+// UnassignRole deletes the relationship representing the requested role assignment.
+func (s *Server) UnassignRole(ctx context.Context, req *authorization.UnassignRoleRequest) (*authorization.UnassignRoleResponse, error) {
+	if s.roles == nil {
+		return s.UnimplementedAuthorizationServer.UnassignRole(ctx, req)
+	}
+
+	return s.roles.UnassignRole(ctx, req)
+}
+
+// ListRoleBindings lists the roles assigned on the requested resource.
+func (s *Server) ListRoleBindings(ctx context.Context, req *authorization.ListRoleBindingsRequest) (*authorization.ListRoleBindingsResponse, error) {
+	if s.roles == nil {
+		return s.UnimplementedAuthorizationServer.ListRoleBindings(ctx, req)
+	}
+
+	return s.roles.ListRoleBindings(ctx, req)
+}
+
+// toStatus converts an error returned by an Engine to a gRPC status error.
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, ErrInvalid):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrAlreadyExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, ErrParentNotFound), errors.Is(err, ErrHasChildren):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	log.Printf("error handling request: %v", err)
+
+	return status.Error(codes.Internal, "internal error")
+}
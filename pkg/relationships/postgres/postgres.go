@@ -0,0 +1,711 @@
+// Package postgres provides a relationship store backed by a PostgreSQL database, for deployments in
+// which several runtimes share relationships.
+//
+// The store implements relationships.RevisionedStore and relationships.ChangeFeed, along with
+// rebac.Store. Each write increments the store's revision and records the changes it made in a
+// transaction log, which consumers read or watch to follow changes.
+// Writes are serialized by locking the row holding the current revision, so that revisions become
+// visible in order and a consumer which has read every change up to a revision never misses one
+// committed later. The database schema is created and migrated when the store is opened.
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	authorization "github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization/v2"
+	"github.com/metal-toolbox/iam-runtime/pkg/relationships"
+)
+
+// NotifyChannel is the channel notified with the new revision after each write.
+const NotifyChannel = "iam_runtime_relationship_changes"
+
+const (
+	// migrationLockID is the key of the advisory lock held while migrating the schema, such that
+	// runtimes starting at the same time do not migrate it concurrently.
+	migrationLockID = 0x69616d72756e74
+
+	// watchBatchSize is the maximum number of change sets read at once when watching.
+	watchBatchSize = 100
+
+	// watchPollInterval is the interval at which watchers check for changes if no notification is
+	// received, in case a notification is lost.
+	watchPollInterval = 30 * time.Second
+)
+
+// migrations is the list of statements which create the database schema. Each entry migrates the
+// schema from the version equal to its index to the next, and entries must never be changed once
+// released.
+var migrations = []string{
+	`
+	CREATE TABLE relationships (
+		resource_id      TEXT NOT NULL CHECK (resource_id <> ''),
+		relation         TEXT NOT NULL CHECK (relation <> ''),
+		subject_id       TEXT NOT NULL CHECK (subject_id <> ''),
+		subject_relation TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (resource_id, relation, subject_id, subject_relation)
+	);
+
+	CREATE INDEX relationships_by_subject
+		ON relationships (subject_id, subject_relation, resource_id, relation);
+
+	CREATE TABLE resources (
+		id        TEXT NOT NULL PRIMARY KEY CHECK (id <> ''),
+		type      TEXT NOT NULL,
+		parent_id TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE INDEX resources_by_parent ON resources (parent_id);
+
+	CREATE TABLE store_revision (
+		id               BOOLEAN NOT NULL PRIMARY KEY DEFAULT TRUE CHECK (id),
+		revision         BIGINT NOT NULL,
+		expired_revision BIGINT NOT NULL
+	);
+
+	INSERT INTO store_revision (revision, expired_revision) VALUES (0, 0);
+
+	CREATE TABLE transactions (
+		revision     BIGINT NOT NULL PRIMARY KEY,
+		committed_at TIMESTAMPTZ NOT NULL
+	);
+
+	CREATE INDEX transactions_by_committed_at ON transactions (committed_at);
+
+	CREATE TABLE changes (
+		revision         BIGINT NOT NULL REFERENCES transactions (revision) ON DELETE CASCADE,
+		seq              INTEGER NOT NULL,
+		operation        TEXT NOT NULL CHECK (operation IN ('create', 'delete')),
+		resource_id      TEXT NOT NULL,
+		relation         TEXT NOT NULL,
+		subject_id       TEXT NOT NULL,
+		subject_relation TEXT NOT NULL,
+		PRIMARY KEY (revision, seq)
+	);
+	`,
+}
+
+var operationNames = map[relationships.Operation]string{
+	relationships.OperationCreate: "create",
+	relationships.OperationDelete: "delete",
+}
+
+// errUnchanged is returned within a write transaction to roll it back without an error when it
+// would not change anything.
+var errUnchanged = errors.New("unchanged")
+
+// Store is a relationship store backed by a PostgreSQL database.
+type Store struct {
+	pool *pgxpool.Pool
+}
+
+// Open connects to the PostgreSQL database with the given connection string and migrates its
+// schema.
+func Open(ctx context.Context, connString string) (*Store, error) {
+	pool, err := pgxpool.New(ctx, connString)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to database: %w", err)
+	}
+
+	out, err := New(ctx, pool)
+	if err != nil {
+		pool.Close()
+
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// New creates a new Store using the given connection pool, migrating the database's schema.
+func New(ctx context.Context, pool *pgxpool.Pool) (*Store, error) {
+	if err := migrate(ctx, pool); err != nil {
+		return nil, err
+	}
+
+	out := &Store{
+		pool: pool,
+	}
+
+	return out, nil
+}
+
+func migrate(ctx context.Context, pool *pgxpool.Pool) error {
+	return pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", migrationLockID); err != nil {
+			return fmt.Errorf("error locking schema: %w", err)
+		}
+
+		if _, err := tx.Exec(ctx, "CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL)"); err != nil {
+			return fmt.Errorf("error creating schema_migrations: %w", err)
+		}
+
+		var version int
+
+		if err := tx.QueryRow(ctx, "SELECT coalesce(max(version), 0) FROM schema_migrations").Scan(&version); err != nil {
+			return fmt.Errorf("error reading schema version: %w", err)
+		}
+
+		if version > len(migrations) {
+			return fmt.Errorf("database schema version %d is newer than the latest supported version %d", version, len(migrations))
+		}
+
+		for i := version; i < len(migrations); i++ {
+			if _, err := tx.Exec(ctx, migrations[i]); err != nil {
+				return fmt.Errorf("error migrating schema to version %d: %w", i+1, err)
+			}
+
+			if _, err := tx.Exec(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", i+1); err != nil {
+				return fmt.Errorf("error writing schema version: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Close closes the connection pool.
+func (s *Store) Close() {
+	s.pool.Close()
+}
+
+// Ping checks that the database can be reached.
+func (s *Store) Ping(ctx context.Context) error {
+	return s.pool.Ping(ctx)
+}
+
+// Revision returns the store's current revision.
+func (s *Store) Revision(ctx context.Context) (relationships.Revision, error) {
+	var out relationships.Revision
+
+	if err := s.pool.QueryRow(ctx, "SELECT revision FROM store_revision").Scan(&out); err != nil {
+		return 0, fmt.Errorf("error reading revision: %w", err)
+	}
+
+	return out, nil
+}
+
+// Write applies the given updates in order in a single transaction.
+func (s *Store) Write(ctx context.Context, updates []relationships.Update) error {
+	if len(updates) == 0 {
+		return nil
+	}
+
+	_, err := s.write(ctx, nil, func(ctx context.Context, w *writer) error {
+		return w.apply(ctx, updates)
+	})
+
+	return err
+}
+
+// WriteAt applies the given updates in a single transaction if the store's current revision is the
+// given revision, returning the new revision.
+func (s *Store) WriteAt(ctx context.Context, revision relationships.Revision, updates []relationships.Update) (relationships.Revision, error) {
+	return s.write(ctx, &revision, func(ctx context.Context, w *writer) error {
+		return w.apply(ctx, updates)
+	})
+}
+
+// writer records the changes made by a write transaction.
+type writer struct {
+	tx       pgx.Tx
+	revision relationships.Revision
+	seq      int
+}
+
+// write calls fn in a transaction which increments the store's revision, returning the new
+// revision. If expected is not nil, the transaction fails with ErrRevisionConflict unless the
+// current revision is *expected.
+func (s *Store) write(ctx context.Context, expected *relationships.Revision, fn func(ctx context.Context, w *writer) error) (relationships.Revision, error) {
+	var revision relationships.Revision
+
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		// Updating the row locks it until the transaction ends, which serializes writes.
+		var err error
+
+		if expected == nil {
+			err = tx.QueryRow(ctx, "UPDATE store_revision SET revision = revision + 1 RETURNING revision").Scan(&revision)
+		} else {
+			err = tx.QueryRow(ctx, "UPDATE store_revision SET revision = revision + 1 WHERE revision = $1 RETURNING revision", *expected).Scan(&revision)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return fmt.Errorf("%w: store is not at revision %d", relationships.ErrRevisionConflict, *expected)
+			}
+		}
+
+		if err != nil {
+			return fmt.Errorf("error incrementing revision: %w", err)
+		}
+
+		if _, err := tx.Exec(ctx, "INSERT INTO transactions (revision, committed_at) VALUES ($1, clock_timestamp())", revision); err != nil {
+			return fmt.Errorf("error recording transaction: %w", err)
+		}
+
+		w := &writer{
+			tx:       tx,
+			revision: revision,
+		}
+
+		if err := fn(ctx, w); err != nil {
+			return err
+		}
+
+		// Notifications are only delivered once the transaction commits.
+		if _, err := tx.Exec(ctx, "SELECT pg_notify($1, $2)", NotifyChannel, fmt.Sprint(revision)); err != nil {
+			return fmt.Errorf("error sending notification: %w", err)
+		}
+
+		return nil
+	})
+
+	if errors.Is(err, errUnchanged) {
+		return s.Revision(ctx)
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return revision, nil
+}
+
+// apply applies the given updates, recording those which change a relationship.
+func (w *writer) apply(ctx context.Context, updates []relationships.Update) error {
+	for _, update := range updates {
+		rel := update.Relationship
+		subjectID, subjectRelation := relationships.SplitSubject(rel.SubjectID)
+
+		var query string
+
+		switch update.Operation {
+		case relationships.OperationCreate:
+			query = `
+				INSERT INTO relationships (resource_id, relation, subject_id, subject_relation)
+				VALUES ($1, $2, $3, $4)
+				ON CONFLICT DO NOTHING`
+		case relationships.OperationDelete:
+			query = `
+				DELETE FROM relationships
+				WHERE resource_id = $1 AND relation = $2 AND subject_id = $3 AND subject_relation = $4`
+		default:
+			return fmt.Errorf("unknown operation %d", update.Operation)
+		}
+
+		tag, err := w.tx.Exec(ctx, query, rel.ResourceID, rel.Relation, subjectID, subjectRelation)
+		if err != nil {
+			return fmt.Errorf("error writing relationship %s: %w", rel, err)
+		}
+
+		if tag.RowsAffected() > 0 {
+			if err := w.record(ctx, update); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// record adds the given update to the transaction log.
+func (w *writer) record(ctx context.Context, update relationships.Update) error {
+	rel := update.Relationship
+	subjectID, subjectRelation := relationships.SplitSubject(rel.SubjectID)
+
+	w.seq++
+
+	_, err := w.tx.Exec(ctx, `
+		INSERT INTO changes (revision, seq, operation, resource_id, relation, subject_id, subject_relation)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		w.revision, w.seq, operationNames[update.Operation], rel.ResourceID, rel.Relation, subjectID, subjectRelation)
+	if err != nil {
+		return fmt.Errorf("error recording change to relationship %s: %w", rel, err)
+	}
+
+	return nil
+}
+
+// ListByResource returns every relationship on the resource with the given ID.
+func (s *Store) ListByResource(ctx context.Context, resourceID string) ([]relationships.Relationship, error) {
+	return s.query(ctx, `
+		SELECT resource_id, relation, subject_id, subject_relation FROM relationships
+		WHERE resource_id = $1
+		ORDER BY relation, subject_id, subject_relation`, resourceID)
+}
+
+// ListBySubject returns every relationship whose subject is the given subject ID.
+func (s *Store) ListBySubject(ctx context.Context, subjectID string) ([]relationships.Relationship, error) {
+	id, relation := relationships.SplitSubject(subjectID)
+
+	return s.query(ctx, `
+		SELECT resource_id, relation, subject_id, subject_relation FROM relationships
+		WHERE subject_id = $1 AND subject_relation = $2
+		ORDER BY resource_id, relation`, id, relation)
+}
+
+// ListAllRelationships returns every relationship in the store.
+func (s *Store) ListAllRelationships(ctx context.Context) ([]relationships.Relationship, error) {
+	return s.query(ctx, `
+		SELECT resource_id, relation, subject_id, subject_relation FROM relationships
+		ORDER BY resource_id, relation, subject_id, subject_relation`)
+}
+
+func (s *Store) query(ctx context.Context, query string, args ...any) ([]relationships.Relationship, error) {
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing relationships: %w", err)
+	}
+
+	out, err := pgx.CollectRows(rows, scanRelationship)
+	if err != nil {
+		return nil, fmt.Errorf("error listing relationships: %w", err)
+	}
+
+	return out, nil
+}
+
+func scanRelationship(row pgx.CollectableRow) (relationships.Relationship, error) {
+	var (
+		out             relationships.Relationship
+		subjectRelation string
+	)
+
+	if err := row.Scan(&out.ResourceID, &out.Relation, &out.SubjectID, &subjectRelation); err != nil {
+		return relationships.Relationship{}, err
+	}
+
+	if subjectRelation != "" {
+		out.SubjectID += "#" + subjectRelation
+	}
+
+	return out, nil
+}
+
+// CreateRelationships creates the given relationships on the resource with the given ID.
+func (s *Store) CreateRelationships(ctx context.Context, resourceID string, rels []*authorization.Relationship) error {
+	return s.Write(ctx, relationships.Updates(relationships.OperationCreate, resourceID, rels))
+}
+
+// DeleteRelationships deletes the given relationships from the resource with the given ID.
+func (s *Store) DeleteRelationships(ctx context.Context, resourceID string, rels []*authorization.Relationship) error {
+	return s.Write(ctx, relationships.Updates(relationships.OperationDelete, resourceID, rels))
+}
+
+// ListRelationships returns every relationship on the resource with the given ID.
+func (s *Store) ListRelationships(ctx context.Context, resourceID string) ([]*authorization.Relationship, error) {
+	rels, err := s.ListByResource(ctx, resourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*authorization.Relationship, 0, len(rels))
+
+	for _, rel := range rels {
+		out = append(out, &authorization.Relationship{
+			Relation:  rel.Relation,
+			SubjectId: rel.SubjectID,
+		})
+	}
+
+	return out, nil
+}
+
+// queryer is implemented by both *pgxpool.Pool and pgx.Tx.
+type queryer interface {
+	QueryRow(ctx context.Context, query string, args ...any) pgx.Row
+}
+
+// GetResource returns the registered resource with the given ID.
+func (s *Store) GetResource(ctx context.Context, id string) (relationships.Resource, error) {
+	return getResource(ctx, s.pool, id)
+}
+
+func getResource(ctx context.Context, q queryer, id string) (relationships.Resource, error) {
+	out := relationships.Resource{
+		ID: id,
+	}
+
+	err := q.QueryRow(ctx, "SELECT type, parent_id FROM resources WHERE id = $1", id).Scan(&out.Type, &out.ParentID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return relationships.Resource{}, fmt.Errorf("%w: %s", relationships.ErrNotFound, id)
+	}
+
+	if err != nil {
+		return relationships.Resource{}, fmt.Errorf("error getting resource %s: %w", id, err)
+	}
+
+	return out, nil
+}
+
+// RegisterResource registers the given resource and creates the relationship to its parent.
+func (s *Store) RegisterResource(ctx context.Context, resource relationships.Resource) error {
+	_, err := s.write(ctx, nil, func(ctx context.Context, w *writer) error {
+		existing, err := getResource(ctx, w.tx, resource.ID)
+
+		switch {
+		case err == nil:
+			if existing != resource {
+				return fmt.Errorf("%w: %s", relationships.ErrAlreadyExists, resource.ID)
+			}
+
+			return errUnchanged
+		case !errors.Is(err, relationships.ErrNotFound):
+			return err
+		}
+
+		if resource.ParentID != "" {
+			if _, err := getResource(ctx, w.tx, resource.ParentID); errors.Is(err, relationships.ErrNotFound) {
+				return fmt.Errorf("%w: %s", relationships.ErrParentNotFound, resource.ParentID)
+			} else if err != nil {
+				return err
+			}
+
+			parent := relationships.Update{
+				Operation: relationships.OperationCreate,
+				Relationship: relationships.Relationship{
+					ResourceID: resource.ID,
+					Relation:   relationships.ParentRelation,
+					SubjectID:  resource.ParentID,
+				},
+			}
+
+			if err := w.apply(ctx, []relationships.Update{parent}); err != nil {
+				return err
+			}
+		}
+
+		_, err = w.tx.Exec(ctx, "INSERT INTO resources (id, type, parent_id) VALUES ($1, $2, $3)", resource.ID, resource.Type, resource.ParentID)
+		if err != nil {
+			return fmt.Errorf("error registering resource %s: %w", resource.ID, err)
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+// UnregisterResource removes the registered resource with the given ID and its relationships.
+func (s *Store) UnregisterResource(ctx context.Context, id string) error {
+	_, err := s.write(ctx, nil, func(ctx context.Context, w *writer) error {
+		if _, err := getResource(ctx, w.tx, id); err != nil {
+			return err
+		}
+
+		var hasChildren bool
+
+		err := w.tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM resources WHERE parent_id = $1)", id).Scan(&hasChildren)
+		if err != nil {
+			return fmt.Errorf("error checking children of resource %s: %w", id, err)
+		}
+
+		if hasChildren {
+			return fmt.Errorf("%w: %s", relationships.ErrHasChildren, id)
+		}
+
+		if _, err := w.tx.Exec(ctx, "DELETE FROM resources WHERE id = $1", id); err != nil {
+			return fmt.Errorf("error unregistering resource %s: %w", id, err)
+		}
+
+		rows, err := w.tx.Query(ctx, `
+			DELETE FROM relationships WHERE resource_id = $1 OR subject_id = $1
+			RETURNING resource_id, relation, subject_id, subject_relation`, id)
+		if err != nil {
+			return fmt.Errorf("error unregistering resource %s: %w", id, err)
+		}
+
+		deleted, err := pgx.CollectRows(rows, scanRelationship)
+		if err != nil {
+			return fmt.Errorf("error unregistering resource %s: %w", id, err)
+		}
+
+		for _, rel := range deleted {
+			update := relationships.Update{
+				Operation:    relationships.OperationDelete,
+				Relationship: rel,
+			}
+
+			if err := w.record(ctx, update); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+// Changes returns up to limit change sets with revisions greater than the given revision.
+func (s *Store) Changes(ctx context.Context, after relationships.Revision, limit int) ([]relationships.ChangeSet, error) {
+	var out []relationships.ChangeSet
+
+	// The expired revision and the transaction log are read from the same snapshot, so that changes
+	// expired in between are not silently skipped.
+	txOptions := pgx.TxOptions{
+		IsoLevel:   pgx.RepeatableRead,
+		AccessMode: pgx.ReadOnly,
+	}
+
+	err := pgx.BeginTxFunc(ctx, s.pool, txOptions, func(tx pgx.Tx) error {
+		var expired relationships.Revision
+
+		if err := tx.QueryRow(ctx, "SELECT expired_revision FROM store_revision").Scan(&expired); err != nil {
+			return fmt.Errorf("error reading expired revision: %w", err)
+		}
+
+		if after < expired {
+			return fmt.Errorf("%w: changes are retained after revision %d", relationships.ErrRevisionExpired, expired)
+		}
+
+		rows, err := tx.Query(ctx, `
+			SELECT
+				t.revision, t.committed_at, c.operation, coalesce(c.resource_id, ''), coalesce(c.relation, ''),
+				coalesce(c.subject_id, ''), coalesce(c.subject_relation, '')
+			FROM (
+				SELECT revision, committed_at FROM transactions
+				WHERE revision > $1
+				ORDER BY revision
+				LIMIT $2
+			) t
+			LEFT JOIN changes c ON c.revision = t.revision
+			ORDER BY t.revision, c.seq`, after, limit)
+		if err != nil {
+			return fmt.Errorf("error reading changes: %w", err)
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			var (
+				revision        relationships.Revision
+				committedAt     time.Time
+				operation       *string
+				rel             relationships.Relationship
+				subjectRelation string
+			)
+
+			if err := rows.Scan(&revision, &committedAt, &operation, &rel.ResourceID, &rel.Relation, &rel.SubjectID, &subjectRelation); err != nil {
+				return fmt.Errorf("error reading changes: %w", err)
+			}
+
+			if len(out) == 0 || out[len(out)-1].Revision != revision {
+				out = append(out, relationships.ChangeSet{
+					Revision:    revision,
+					CommittedAt: committedAt,
+				})
+			}
+
+			// Writes which changed nothing have no changes to join.
+			if operation == nil {
+				continue
+			}
+
+			if subjectRelation != "" {
+				rel.SubjectID += "#" + subjectRelation
+			}
+
+			update := relationships.Update{
+				Operation:    relationships.OperationCreate,
+				Relationship: rel,
+			}
+
+			if *operation == operationNames[relationships.OperationDelete] {
+				update.Operation = relationships.OperationDelete
+			}
+
+			set := &out[len(out)-1]
+			set.Updates = append(set.Updates, update)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// Watch calls fn with each change set with a revision greater than the given revision, waiting for
+// notifications of new writes on a dedicated connection.
+func (s *Store) Watch(ctx context.Context, after relationships.Revision, fn func(relationships.ChangeSet) error) error {
+	poolConn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("error acquiring connection: %w", err)
+	}
+
+	// The connection is removed from the pool, since it is left listening for notifications.
+	conn := poolConn.Hijack()
+
+	defer conn.Close(context.Background())
+
+	// Listening before reading changes ensures that no write committed in between is missed.
+	if _, err := conn.Exec(ctx, "LISTEN "+NotifyChannel); err != nil {
+		return fmt.Errorf("error listening for changes: %w", err)
+	}
+
+	for {
+		sets, err := s.Changes(ctx, after, watchBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, set := range sets {
+			if err := fn(set); err != nil {
+				return err
+			}
+
+			after = set.Revision
+		}
+
+		if len(sets) == watchBatchSize {
+			continue
+		}
+
+		waitCtx, cancel := context.WithTimeout(ctx, watchPollInterval)
+		_, err = conn.WaitForNotification(waitCtx)
+
+		cancel()
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err != nil && !pgconn.Timeout(err) {
+			return fmt.Errorf("error waiting for changes: %w", err)
+		}
+	}
+}
+
+// ExpireChanges removes change sets committed before the given time from the transaction log.
+// Reading changes after a revision which has been removed fails with ErrRevisionExpired.
+func (s *Store) ExpireChanges(ctx context.Context, before time.Time) error {
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		var expired *relationships.Revision
+
+		err := tx.QueryRow(ctx, `
+			WITH deleted AS (
+				DELETE FROM transactions WHERE committed_at < $1
+				RETURNING revision
+			)
+			SELECT max(revision) FROM deleted`, before).Scan(&expired)
+		if err != nil {
+			return fmt.Errorf("error expiring changes: %w", err)
+		}
+
+		if expired == nil {
+			return nil
+		}
+
+		_, err = tx.Exec(ctx, "UPDATE store_revision SET expired_revision = greatest(expired_revision, $1)", *expired)
+		if err != nil {
+			return fmt.Errorf("error expiring changes: %w", err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,489 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/metal-toolbox/iam-runtime/pkg/relationships"
+)
+
+// testURLEnv is the environment variable holding the connection string of the database the tests
+// run against. The tests are skipped if it is not set; "make test-postgres" runs them against a
+// database in a container.
+const testURLEnv = "POSTGRES_TEST_URL"
+
+// newTestStore returns a store in a new schema of the test database, which is dropped when the test
+// finishes.
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	connString := os.Getenv(testURLEnv)
+	if connString == "" {
+		t.Skipf("%s is not set", testURLEnv)
+	}
+
+	ctx := context.Background()
+
+	admin, err := pgx.Connect(ctx, connString)
+	if err != nil {
+		t.Fatalf("error connecting to database: %v", err)
+	}
+
+	t.Cleanup(func() {
+		admin.Close(context.Background())
+	})
+
+	schema := pgx.Identifier{fmt.Sprintf("test_%d", time.Now().UnixNano())}.Sanitize()
+
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatalf("error creating schema: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if _, err := admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE"); err != nil {
+			t.Errorf("error dropping schema: %v", err)
+		}
+	})
+
+	config, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		t.Fatalf("error parsing connection string: %v", err)
+	}
+
+	config.ConnConfig.RuntimeParams["search_path"] = schema
+
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		t.Fatalf("error connecting to database: %v", err)
+	}
+
+	store, err := New(ctx, pool)
+	if err != nil {
+		pool.Close()
+
+		t.Fatalf("error creating store: %v", err)
+	}
+
+	// Registered first, so that the pool is closed before the schema is dropped.
+	t.Cleanup(store.Close)
+
+	return store
+}
+
+func create(tuples ...relationships.Relationship) []relationships.Update {
+	out := make([]relationships.Update, 0, len(tuples))
+
+	for _, rel := range tuples {
+		out = append(out, relationships.Update{
+			Operation:    relationships.OperationCreate,
+			Relationship: rel,
+		})
+	}
+
+	return out
+}
+
+func rel(resourceID, relation, subjectID string) relationships.Relationship {
+	return relationships.Relationship{
+		ResourceID: resourceID,
+		Relation:   relation,
+		SubjectID:  subjectID,
+	}
+}
+
+func writeAt(t *testing.T, store *Store, revision relationships.Revision, updates []relationships.Update) relationships.Revision {
+	t.Helper()
+
+	out, err := store.WriteAt(context.Background(), revision, updates)
+	if err != nil {
+		t.Fatalf("error writing at revision %d: %v", revision, err)
+	}
+
+	return out
+}
+
+func TestRevisions(t *testing.T) {
+	store := newTestStore(t)
+
+	ctx := context.Background()
+
+	start, err := store.Revision(ctx)
+	if err != nil {
+		t.Fatalf("error reading revision: %v", err)
+	}
+
+	first := writeAt(t, store, start, create(rel("doc-1", "viewer", "user-alice")))
+	if first <= start {
+		t.Errorf("got revision %d after writing at %d, want greater", first, start)
+	}
+
+	// Writes which change nothing still produce a new revision.
+	second := writeAt(t, store, first, create(rel("doc-1", "viewer", "user-alice")))
+	if second <= first {
+		t.Errorf("got revision %d after writing at %d, want greater", second, first)
+	}
+
+	_, err = store.WriteAt(ctx, first, create(rel("doc-1", "viewer", "user-bob")))
+	if !errors.Is(err, relationships.ErrRevisionConflict) {
+		t.Fatalf("writing at stale revision: got error %v, want %v", err, relationships.ErrRevisionConflict)
+	}
+
+	current, err := store.Revision(ctx)
+	if err != nil {
+		t.Fatalf("error reading revision: %v", err)
+	}
+
+	if current != second {
+		t.Errorf("got revision %d after conflicting write, want %d", current, second)
+	}
+
+	rels, err := store.ListByResource(ctx, "doc-1")
+	if err != nil {
+		t.Fatalf("error listing relationships: %v", err)
+	}
+
+	if len(rels) != 1 {
+		t.Errorf("got relationships %v, want only %v", rels, rel("doc-1", "viewer", "user-alice"))
+	}
+}
+
+func TestChanges(t *testing.T) {
+	store := newTestStore(t)
+
+	ctx := context.Background()
+
+	start, err := store.Revision(ctx)
+	if err != nil {
+		t.Fatalf("error reading revision: %v", err)
+	}
+
+	first := writeAt(t, store, start, create(
+		rel("doc-1", "viewer", "user-alice"),
+		rel("doc-1", "viewer", "group-staff#member"),
+	))
+
+	second := writeAt(t, store, first, []relationships.Update{
+		{Operation: relationships.OperationCreate, Relationship: rel("doc-1", "viewer", "user-alice")},
+		{Operation: relationships.OperationDelete, Relationship: rel("doc-1", "viewer", "user-alice")},
+		{Operation: relationships.OperationDelete, Relationship: rel("doc-1", "viewer", "user-bob")},
+	})
+
+	third := writeAt(t, store, second, create(rel("doc-1", "viewer", "group-staff#member")))
+
+	sets, err := store.Changes(ctx, start, 10)
+	if err != nil {
+		t.Fatalf("error reading changes: %v", err)
+	}
+
+	want := []relationships.ChangeSet{
+		{
+			Revision: first,
+			Updates: create(
+				rel("doc-1", "viewer", "user-alice"),
+				rel("doc-1", "viewer", "group-staff#member"),
+			),
+		},
+		{
+			Revision: second,
+			Updates: []relationships.Update{
+				{Operation: relationships.OperationDelete, Relationship: rel("doc-1", "viewer", "user-alice")},
+			},
+		},
+		{
+			Revision: third,
+		},
+	}
+
+	assertChangeSets(t, sets, want)
+
+	sets, err = store.Changes(ctx, start, 2)
+	if err != nil {
+		t.Fatalf("error reading changes: %v", err)
+	}
+
+	assertChangeSets(t, sets, want[:2])
+
+	sets, err = store.Changes(ctx, first, 10)
+	if err != nil {
+		t.Fatalf("error reading changes: %v", err)
+	}
+
+	assertChangeSets(t, sets, want[1:])
+
+	sets, err = store.Changes(ctx, third, 10)
+	if err != nil {
+		t.Fatalf("error reading changes: %v", err)
+	}
+
+	assertChangeSets(t, sets, nil)
+}
+
+func TestExpireChanges(t *testing.T) {
+	store := newTestStore(t)
+
+	ctx := context.Background()
+
+	start, err := store.Revision(ctx)
+	if err != nil {
+		t.Fatalf("error reading revision: %v", err)
+	}
+
+	first := writeAt(t, store, start, create(rel("doc-1", "viewer", "user-alice")))
+
+	if err := store.ExpireChanges(ctx, time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("error expiring changes: %v", err)
+	}
+
+	second := writeAt(t, store, first, create(rel("doc-1", "viewer", "user-bob")))
+
+	_, err = store.Changes(ctx, start, 10)
+	if !errors.Is(err, relationships.ErrRevisionExpired) {
+		t.Fatalf("reading expired changes: got error %v, want %v", err, relationships.ErrRevisionExpired)
+	}
+
+	sets, err := store.Changes(ctx, first, 10)
+	if err != nil {
+		t.Fatalf("error reading changes: %v", err)
+	}
+
+	assertChangeSets(t, sets, []relationships.ChangeSet{
+		{
+			Revision: second,
+			Updates:  create(rel("doc-1", "viewer", "user-bob")),
+		},
+	})
+}
+
+func TestResourceChanges(t *testing.T) {
+	store := newTestStore(t)
+
+	ctx := context.Background()
+
+	revision := func() relationships.Revision {
+		t.Helper()
+
+		out, err := store.Revision(ctx)
+		if err != nil {
+			t.Fatalf("error reading revision: %v", err)
+		}
+
+		return out
+	}
+
+	register := func(resource relationships.Resource) relationships.Revision {
+		t.Helper()
+
+		if err := store.RegisterResource(ctx, resource); err != nil {
+			t.Fatalf("error registering %s: %v", resource.ID, err)
+		}
+
+		return revision()
+	}
+
+	start := revision()
+
+	tenant := register(relationships.Resource{ID: "tnnt-a", Type: "tenant"})
+	doc := register(relationships.Resource{ID: "doc-1", Type: "document", ParentID: "tnnt-a"})
+
+	// Registering a resource again, or failing to register one, records no changes.
+	if got := register(relationships.Resource{ID: "doc-1", Type: "document", ParentID: "tnnt-a"}); got != doc {
+		t.Errorf("got revision %d after registering resource again, want %d", got, doc)
+	}
+
+	if err := store.RegisterResource(ctx, relationships.Resource{ID: "doc-1", Type: "folder"}); !errors.Is(err, relationships.ErrAlreadyExists) {
+		t.Fatalf("got error %v, want %v", err, relationships.ErrAlreadyExists)
+	}
+
+	if err := store.UnregisterResource(ctx, "tnnt-a"); !errors.Is(err, relationships.ErrHasChildren) {
+		t.Fatalf("got error %v, want %v", err, relationships.ErrHasChildren)
+	}
+
+	written := writeAt(t, store, revision(), create(
+		rel("doc-1", "viewer", "user-alice"),
+		rel("doc-2", "viewer", "doc-1#viewer"),
+	))
+
+	if err := store.UnregisterResource(ctx, "doc-1"); err != nil {
+		t.Fatalf("error unregistering resource: %v", err)
+	}
+
+	unregistered := revision()
+
+	sets, err := store.Changes(ctx, start, 10)
+	if err != nil {
+		t.Fatalf("error reading changes: %v", err)
+	}
+
+	// The relationships deleted by unregistering a resource are recorded in no particular order.
+	if len(sets) == 4 {
+		sort.Slice(sets[3].Updates, func(i, j int) bool {
+			return sets[3].Updates[i].Relationship.String() < sets[3].Updates[j].Relationship.String()
+		})
+	}
+
+	assertChangeSets(t, sets, []relationships.ChangeSet{
+		{
+			Revision: tenant,
+		},
+		{
+			Revision: doc,
+			Updates:  create(rel("doc-1", relationships.ParentRelation, "tnnt-a")),
+		},
+		{
+			Revision: written,
+			Updates: create(
+				rel("doc-1", "viewer", "user-alice"),
+				rel("doc-2", "viewer", "doc-1#viewer"),
+			),
+		},
+		{
+			Revision: unregistered,
+			Updates: []relationships.Update{
+				{Operation: relationships.OperationDelete, Relationship: rel("doc-1", relationships.ParentRelation, "tnnt-a")},
+				{Operation: relationships.OperationDelete, Relationship: rel("doc-1", "viewer", "user-alice")},
+				{Operation: relationships.OperationDelete, Relationship: rel("doc-2", "viewer", "doc-1#viewer")},
+			},
+		},
+	})
+}
+
+func TestWatch(t *testing.T) {
+	store := newTestStore(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	start, err := store.Revision(ctx)
+	if err != nil {
+		t.Fatalf("error reading revision: %v", err)
+	}
+
+	// One write is committed before watching, and the rest once the watch has caught up to it.
+	first := writeAt(t, store, start, create(rel("doc-1", "viewer", "user-alice")))
+
+	sets := make(chan relationships.ChangeSet)
+	done := make(chan error, 1)
+
+	go func() {
+		done <- store.Watch(ctx, start, func(set relationships.ChangeSet) error {
+			select {
+			case sets <- set:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	want := []relationships.ChangeSet{
+		{
+			Revision: first,
+			Updates:  create(rel("doc-1", "viewer", "user-alice")),
+		},
+	}
+
+	got := []relationships.ChangeSet{receive(t, sets)}
+
+	revision := first
+
+	for _, subject := range []string{"user-bob", "user-carol"} {
+		revision = writeAt(t, store, revision, create(rel("doc-1", "viewer", subject)))
+
+		want = append(want, relationships.ChangeSet{
+			Revision: revision,
+			Updates:  create(rel("doc-1", "viewer", subject)),
+		})
+
+		got = append(got, receive(t, sets))
+	}
+
+	assertChangeSets(t, got, want)
+
+	cancel()
+
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v after cancelling, want %v", err, context.Canceled)
+	}
+}
+
+func TestWatchStopsOnError(t *testing.T) {
+	store := newTestStore(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	start, err := store.Revision(ctx)
+	if err != nil {
+		t.Fatalf("error reading revision: %v", err)
+	}
+
+	revision := writeAt(t, store, start, create(rel("doc-1", "viewer", "user-alice")))
+	writeAt(t, store, revision, create(rel("doc-1", "viewer", "user-bob")))
+
+	errStop := errors.New("stop")
+
+	var calls int
+
+	err = store.Watch(ctx, start, func(relationships.ChangeSet) error {
+		calls++
+
+		return errStop
+	})
+	if !errors.Is(err, errStop) {
+		t.Errorf("got error %v, want %v", err, errStop)
+	}
+
+	if calls != 1 {
+		t.Errorf("got %d calls after returning an error, want 1", calls)
+	}
+}
+
+func receive(t *testing.T, sets <-chan relationships.ChangeSet) relationships.ChangeSet {
+	t.Helper()
+
+	select {
+	case set := <-sets:
+		return set
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for change set")
+	}
+
+	return relationships.ChangeSet{}
+}
+
+func assertChangeSets(t *testing.T, got, want []relationships.ChangeSet) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d change sets, want %d: %v", len(got), len(want), got)
+	}
+
+	for i := range want {
+		if got[i].Revision != want[i].Revision {
+			t.Errorf("change set %d: got revision %d, want %d", i, got[i].Revision, want[i].Revision)
+		}
+
+		if got[i].CommittedAt.IsZero() {
+			t.Errorf("change set %d: commit time not set", i)
+		}
+
+		if len(got[i].Updates) != len(want[i].Updates) {
+			t.Errorf("change set %d: got updates %v, want %v", i, got[i].Updates, want[i].Updates)
+
+			continue
+		}
+
+		for j := range want[i].Updates {
+			if got[i].Updates[j] != want[i].Updates[j] {
+				t.Errorf("change set %d: got update %d %v, want %v", i, j, got[i].Updates[j], want[i].Updates[j])
+			}
+		}
+	}
+}
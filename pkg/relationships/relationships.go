@@ -0,0 +1,171 @@
+// Package relationships defines storage for relationship tuples, which record that a subject has a
+// relation to a resource. Stores are shared by the authorization engines in this module, such that
+// relationships written by one engine survive restarts regardless of how access is computed.
+package relationships
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	authorization "github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization/v2"
+)
+
+// ParentRelation is the relation used to record the parent of a registered resource. Stores create
+// and delete relationships with it when resources are registered and unregistered.
+const ParentRelation = "parent"
+
+var (
+	// ErrNotFound is returned when a resource is not registered.
+	ErrNotFound = errors.New("resource not found")
+	// ErrAlreadyExists is returned when registering a resource which is already registered with a
+	// different type or parent.
+	ErrAlreadyExists = errors.New("resource already registered")
+	// ErrParentNotFound is returned when registering a resource whose parent is not registered.
+	ErrParentNotFound = errors.New("parent resource not found")
+	// ErrHasChildren is returned when unregistering a resource which is the parent of another.
+	ErrHasChildren = errors.New("resource has children")
+)
+
+// Resource is a registered resource.
+type Resource struct {
+	// ID is the ID of the resource.
+	ID string
+	// Type is the type of the resource.
+	Type string
+	// ParentID is the ID of the resource's parent, if any.
+	ParentID string
+}
+
+// Relationship is a relationship tuple.
+type Relationship struct {
+	// ResourceID is the ID of the resource the relationship is on.
+	ResourceID string
+	// Relation is the name of the relation.
+	Relation string
+	// SubjectID is the ID of the subject, which may be a subject set of the form
+	// "<resource ID>#<relation>".
+	SubjectID string
+}
+
+// String returns the relationship in the form "<resource ID>#<relation>@<subject ID>".
+func (r Relationship) String() string {
+	return r.ResourceID + "#" + r.Relation + "@" + r.SubjectID
+}
+
+// SplitSubject splits a subject ID into the ID of the subject and, for a subject set, the relation
+// on it.
+func SplitSubject(subjectID string) (id, relation string) {
+	id, relation, _ = strings.Cut(subjectID, "#")
+
+	return id, relation
+}
+
+// Operation is the operation an Update performs.
+type Operation int
+
+const (
+	// OperationCreate creates the relationship. Creating a relationship which already exists
+	// succeeds without making changes.
+	OperationCreate Operation = iota
+	// OperationDelete deletes the relationship. Deleting a relationship which does not exist
+	// succeeds without making changes.
+	OperationDelete
+)
+
+// Update is a change to a single relationship.
+type Update struct {
+	// Operation is the operation to perform.
+	Operation Operation
+	// Relationship is the relationship the operation is performed on.
+	Relationship Relationship
+}
+
+// Store is the storage relationship tuples are read from and written to.
+type Store interface {
+	// Write applies the given updates in order in a single transaction, such that either every
+	// update is applied or none is.
+	Write(ctx context.Context, updates []Update) error
+
+	// ListByResource returns every relationship on the resource with the given ID.
+	ListByResource(ctx context.Context, resourceID string) ([]Relationship, error)
+
+	// ListBySubject returns every relationship whose subject is the given subject ID. Subject sets
+	// only match subject IDs naming the same relation.
+	ListBySubject(ctx context.Context, subjectID string) ([]Relationship, error)
+}
+
+// Revision identifies the state of a store after a write. Each write to a store with revisions
+// produces a revision greater than that of every write before it.
+type Revision int64
+
+var (
+	// ErrRevisionConflict is returned when writing at a revision which is not the store's current
+	// revision.
+	ErrRevisionConflict = errors.New("revision conflict")
+	// ErrRevisionExpired is returned when reading changes after a revision which is no longer
+	// retained by the store.
+	ErrRevisionExpired = errors.New("revision expired")
+)
+
+// RevisionedStore is a Store which numbers each write with a revision, so that clients can make
+// writes conditional on no other writes having happened since they last read.
+type RevisionedStore interface {
+	Store
+
+	// Revision returns the store's current revision.
+	Revision(ctx context.Context) (Revision, error)
+
+	// WriteAt applies the given updates in a single transaction if the store's current revision is
+	// the given revision, returning the new revision. Otherwise, it returns an error wrapping
+	// ErrRevisionConflict without applying any update.
+	WriteAt(ctx context.Context, revision Revision, updates []Update) (Revision, error)
+}
+
+// ChangeSet is the set of changes made to relationships by a single write.
+type ChangeSet struct {
+	// Revision is the revision produced by the write.
+	Revision Revision
+	// CommittedAt is the time the write was committed.
+	CommittedAt time.Time
+	// Updates is the list of updates which changed relationships, in the order they were applied.
+	// Updates which made no changes, such as creating a relationship which already existed, are
+	// omitted, and so the list may be empty.
+	Updates []Update
+}
+
+// ChangeFeed is implemented by stores which record the changes made by each write, for consumers
+// which maintain their own view of relationships.
+type ChangeFeed interface {
+	// Changes returns up to limit change sets with revisions greater than the given revision, in
+	// order of revision. It returns an error wrapping ErrRevisionExpired if changes after the given
+	// revision are no longer retained.
+	Changes(ctx context.Context, after Revision, limit int) ([]ChangeSet, error)
+
+	// Watch calls fn with each change set with a revision greater than the given revision, in order
+	// of revision, waiting for new writes until ctx is done or fn returns an error, which is then
+	// returned.
+	Watch(ctx context.Context, after Revision, fn func(ChangeSet) error) error
+}
+
+// Updates returns an update performing the given operation for each of the given relationships on
+// the resource with the given ID.
+func Updates(op Operation, resourceID string, relationships []*authorization.Relationship) []Update {
+	out := make([]Update, 0, len(relationships))
+
+	for _, rel := range relationships {
+		update := Update{
+			Operation: op,
+			Relationship: Relationship{
+				ResourceID: resourceID,
+				Relation:   rel.GetRelation(),
+				SubjectID:  rel.GetSubjectId(),
+			},
+		}
+
+		out = append(out, update)
+	}
+
+	return out
+}
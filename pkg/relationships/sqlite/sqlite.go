@@ -0,0 +1,428 @@
+// Package sqlite provides a relationship store backed by a SQLite database, for runtimes which must
+// keep relationships across restarts without running a database server.
+//
+// The store implements relationships.Store, along with rebac.Store, such that it may be used with
+// the rebac engine. The database schema is created and migrated when the store is opened.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	authorization "github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization/v2"
+	"github.com/metal-toolbox/iam-runtime/pkg/relationships"
+
+	// The SQLite driver, which does not require cgo, is registered as "sqlite".
+	_ "modernc.org/sqlite"
+)
+
+// DriverName is the name of the database/sql driver used by Open.
+const DriverName = "sqlite"
+
+// migrations is the list of statements which create the database schema. Each entry migrates the
+// schema from the version equal to its index to the next, and entries must never be changed once
+// released. The current version is recorded in the database's user_version.
+var migrations = []string{
+	`
+	CREATE TABLE relationships (
+		resource_id      TEXT NOT NULL CHECK (resource_id <> ''),
+		relation         TEXT NOT NULL CHECK (relation <> ''),
+		subject_id       TEXT NOT NULL CHECK (subject_id <> ''),
+		subject_relation TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (resource_id, relation, subject_id, subject_relation)
+	) WITHOUT ROWID;
+
+	CREATE INDEX relationships_by_subject
+		ON relationships (subject_id, subject_relation, resource_id, relation);
+
+	CREATE TABLE resources (
+		id        TEXT NOT NULL PRIMARY KEY CHECK (id <> ''),
+		type      TEXT NOT NULL,
+		parent_id TEXT NOT NULL DEFAULT ''
+	) WITHOUT ROWID;
+
+	CREATE INDEX resources_by_parent ON resources (parent_id);
+	`,
+}
+
+// Store is a relationship store backed by a SQLite database.
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens the SQLite database at the given path, creating it if it does not exist, and migrates
+// its schema.
+func Open(ctx context.Context, path string) (*Store, error) {
+	dsn, err := dataSourceName(path)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open(DriverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error opening database %s: %w", path, err)
+	}
+
+	out, err := New(ctx, db)
+	if err != nil {
+		db.Close()
+
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// dataSourceName returns the data source name opening the database at the given path, which may
+// be a file path or a SQLite URI beginning with "file:". Paths are given as URIs, so that they may
+// contain characters such as "?" which the driver would otherwise treat as starting parameters.
+func dataSourceName(path string) (string, error) {
+	u := &url.URL{
+		Scheme: "file",
+		Path:   path,
+	}
+
+	if strings.HasPrefix(path, "file:") {
+		parsed, err := url.Parse(path)
+		if err != nil {
+			return "", fmt.Errorf("error parsing database URI %s: %w", path, err)
+		}
+
+		u = parsed
+	}
+
+	// Write transactions lock the database when they begin, rather than on their first write, so
+	// that concurrent writers wait for each other instead of failing.
+	query := u.Query()
+	query.Add("_pragma", "busy_timeout(5000)")
+	query.Add("_pragma", "journal_mode(WAL)")
+	query.Add("_pragma", "synchronous(NORMAL)")
+	query.Set("_txlock", "immediate")
+
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+// New creates a new Store using the given SQLite database, migrating its schema.
+func New(ctx context.Context, db *sql.DB) (*Store, error) {
+	if err := migrate(ctx, db); err != nil {
+		return nil, err
+	}
+
+	out := &Store{
+		db: db,
+	}
+
+	return out, nil
+}
+
+func migrate(ctx context.Context, db *sql.DB) error {
+	return withTx(ctx, db, func(tx *sql.Tx) error {
+		var version int
+
+		if err := tx.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+			return fmt.Errorf("error reading schema version: %w", err)
+		}
+
+		if version > len(migrations) {
+			return fmt.Errorf("database schema version %d is newer than the latest supported version %d", version, len(migrations))
+		}
+
+		for i := version; i < len(migrations); i++ {
+			if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+				return fmt.Errorf("error migrating schema to version %d: %w", i+1, err)
+			}
+		}
+
+		// PRAGMA statements cannot take parameters.
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", len(migrations))); err != nil {
+			return fmt.Errorf("error writing schema version: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Ping checks that the database can be reached.
+func (s *Store) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Write applies the given updates in order in a single transaction.
+func (s *Store) Write(ctx context.Context, updates []relationships.Update) error {
+	if len(updates) == 0 {
+		return nil
+	}
+
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		return write(ctx, tx, updates)
+	})
+}
+
+func write(ctx context.Context, tx *sql.Tx, updates []relationships.Update) error {
+	insert, err := tx.PrepareContext(ctx, `
+		INSERT INTO relationships (resource_id, relation, subject_id, subject_relation)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT DO NOTHING`)
+	if err != nil {
+		return err
+	}
+
+	defer insert.Close()
+
+	del, err := tx.PrepareContext(ctx, `
+		DELETE FROM relationships
+		WHERE resource_id = ? AND relation = ? AND subject_id = ? AND subject_relation = ?`)
+	if err != nil {
+		return err
+	}
+
+	defer del.Close()
+
+	for _, update := range updates {
+		rel := update.Relationship
+		subjectID, subjectRelation := relationships.SplitSubject(rel.SubjectID)
+
+		var stmt *sql.Stmt
+
+		switch update.Operation {
+		case relationships.OperationCreate:
+			stmt = insert
+		case relationships.OperationDelete:
+			stmt = del
+		default:
+			return fmt.Errorf("unknown operation %d", update.Operation)
+		}
+
+		if _, err := stmt.ExecContext(ctx, rel.ResourceID, rel.Relation, subjectID, subjectRelation); err != nil {
+			return fmt.Errorf("error writing relationship %s: %w", rel, err)
+		}
+	}
+
+	return nil
+}
+
+// ListByResource returns every relationship on the resource with the given ID.
+func (s *Store) ListByResource(ctx context.Context, resourceID string) ([]relationships.Relationship, error) {
+	return s.query(ctx, `
+		SELECT resource_id, relation, subject_id, subject_relation FROM relationships
+		WHERE resource_id = ?
+		ORDER BY relation, subject_id, subject_relation`, resourceID)
+}
+
+// ListBySubject returns every relationship whose subject is the given subject ID.
+func (s *Store) ListBySubject(ctx context.Context, subjectID string) ([]relationships.Relationship, error) {
+	id, relation := relationships.SplitSubject(subjectID)
+
+	return s.query(ctx, `
+		SELECT resource_id, relation, subject_id, subject_relation FROM relationships
+		WHERE subject_id = ? AND subject_relation = ?
+		ORDER BY resource_id, relation`, id, relation)
+}
+
+// ListAllRelationships returns every relationship in the store.
+func (s *Store) ListAllRelationships(ctx context.Context) ([]relationships.Relationship, error) {
+	return s.query(ctx, `
+		SELECT resource_id, relation, subject_id, subject_relation FROM relationships
+		ORDER BY resource_id, relation, subject_id, subject_relation`)
+}
+
+func (s *Store) query(ctx context.Context, query string, args ...any) ([]relationships.Relationship, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing relationships: %w", err)
+	}
+
+	defer rows.Close()
+
+	var out []relationships.Relationship
+
+	for rows.Next() {
+		var (
+			rel             relationships.Relationship
+			subjectRelation string
+		)
+
+		if err := rows.Scan(&rel.ResourceID, &rel.Relation, &rel.SubjectID, &subjectRelation); err != nil {
+			return nil, fmt.Errorf("error listing relationships: %w", err)
+		}
+
+		if subjectRelation != "" {
+			rel.SubjectID += "#" + subjectRelation
+		}
+
+		out = append(out, rel)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error listing relationships: %w", err)
+	}
+
+	return out, nil
+}
+
+// CreateRelationships creates the given relationships on the resource with the given ID.
+func (s *Store) CreateRelationships(ctx context.Context, resourceID string, rels []*authorization.Relationship) error {
+	return s.Write(ctx, relationships.Updates(relationships.OperationCreate, resourceID, rels))
+}
+
+// DeleteRelationships deletes the given relationships from the resource with the given ID.
+func (s *Store) DeleteRelationships(ctx context.Context, resourceID string, rels []*authorization.Relationship) error {
+	return s.Write(ctx, relationships.Updates(relationships.OperationDelete, resourceID, rels))
+}
+
+// ListRelationships returns every relationship on the resource with the given ID.
+func (s *Store) ListRelationships(ctx context.Context, resourceID string) ([]*authorization.Relationship, error) {
+	rels, err := s.ListByResource(ctx, resourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*authorization.Relationship, 0, len(rels))
+
+	for _, rel := range rels {
+		out = append(out, &authorization.Relationship{
+			Relation:  rel.Relation,
+			SubjectId: rel.SubjectID,
+		})
+	}
+
+	return out, nil
+}
+
+// GetResource returns the registered resource with the given ID.
+func (s *Store) GetResource(ctx context.Context, id string) (relationships.Resource, error) {
+	return getResource(ctx, s.db, id)
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func getResource(ctx context.Context, q queryer, id string) (relationships.Resource, error) {
+	out := relationships.Resource{
+		ID: id,
+	}
+
+	err := q.QueryRowContext(ctx, "SELECT type, parent_id FROM resources WHERE id = ?", id).Scan(&out.Type, &out.ParentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return relationships.Resource{}, fmt.Errorf("%w: %s", relationships.ErrNotFound, id)
+	}
+
+	if err != nil {
+		return relationships.Resource{}, fmt.Errorf("error getting resource %s: %w", id, err)
+	}
+
+	return out, nil
+}
+
+// RegisterResource registers the given resource and creates the relationship to its parent.
+func (s *Store) RegisterResource(ctx context.Context, resource relationships.Resource) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		existing, err := getResource(ctx, tx, resource.ID)
+
+		switch {
+		case err == nil:
+			if existing != resource {
+				return fmt.Errorf("%w: %s", relationships.ErrAlreadyExists, resource.ID)
+			}
+
+			return nil
+		case !errors.Is(err, relationships.ErrNotFound):
+			return err
+		}
+
+		if resource.ParentID != "" {
+			if _, err := getResource(ctx, tx, resource.ParentID); errors.Is(err, relationships.ErrNotFound) {
+				return fmt.Errorf("%w: %s", relationships.ErrParentNotFound, resource.ParentID)
+			} else if err != nil {
+				return err
+			}
+
+			parent := relationships.Update{
+				Operation: relationships.OperationCreate,
+				Relationship: relationships.Relationship{
+					ResourceID: resource.ID,
+					Relation:   relationships.ParentRelation,
+					SubjectID:  resource.ParentID,
+				},
+			}
+
+			if err := write(ctx, tx, []relationships.Update{parent}); err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, "INSERT INTO resources (id, type, parent_id) VALUES (?, ?, ?)", resource.ID, resource.Type, resource.ParentID)
+		if err != nil {
+			return fmt.Errorf("error registering resource %s: %w", resource.ID, err)
+		}
+
+		return nil
+	})
+}
+
+// UnregisterResource removes the registered resource with the given ID and its relationships.
+func (s *Store) UnregisterResource(ctx context.Context, id string) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		if _, err := getResource(ctx, tx, id); err != nil {
+			return err
+		}
+
+		var hasChildren bool
+
+		err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM resources WHERE parent_id = ?)", id).Scan(&hasChildren)
+		if err != nil {
+			return fmt.Errorf("error checking children of resource %s: %w", id, err)
+		}
+
+		if hasChildren {
+			return fmt.Errorf("%w: %s", relationships.ErrHasChildren, id)
+		}
+
+		statements := []string{
+			"DELETE FROM resources WHERE id = ?",
+			"DELETE FROM relationships WHERE resource_id = ?",
+			"DELETE FROM relationships WHERE subject_id = ?",
+		}
+
+		for _, stmt := range statements {
+			if _, err := tx.ExecContext(ctx, stmt, id); err != nil {
+				return fmt.Errorf("error unregistering resource %s: %w", id, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// withTx calls fn in a transaction, which is committed if fn returns nil and rolled back otherwise.
+func withTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,419 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/relationships"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	store, err := Open(context.Background(), filepath.Join(t.TempDir(), "relationships.db"))
+	if err != nil {
+		t.Fatalf("error opening store: %v", err)
+	}
+
+	t.Cleanup(func() {
+		store.Close()
+	})
+
+	return store
+}
+
+func create(tuples ...relationships.Relationship) []relationships.Update {
+	out := make([]relationships.Update, 0, len(tuples))
+
+	for _, rel := range tuples {
+		out = append(out, relationships.Update{
+			Operation:    relationships.OperationCreate,
+			Relationship: rel,
+		})
+	}
+
+	return out
+}
+
+func rel(resourceID, relation, subjectID string) relationships.Relationship {
+	return relationships.Relationship{
+		ResourceID: resourceID,
+		Relation:   relation,
+		SubjectID:  subjectID,
+	}
+}
+
+func schemaVersion(t *testing.T, db *sql.DB) int {
+	t.Helper()
+
+	var version int
+
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		t.Fatalf("error reading schema version: %v", err)
+	}
+
+	return version
+}
+
+func assertRelationships(t *testing.T, got []relationships.Relationship, want ...relationships.Relationship) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got relationships %v, want %v", got, want)
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got relationship %d %s, want %s", i, got[i], want[i])
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name string
+		path string
+		file string
+	}{
+		{"path", filepath.Join(dir, "relationships.db"), "relationships.db"},
+		{"path with query characters", filepath.Join(dir, "relationships?mode=ro#1.db"), "relationships?mode=ro#1.db"},
+		{"URI", "file:" + filepath.Join(dir, "uri.db") + "?mode=rwc", "uri.db"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := Open(context.Background(), tt.path)
+			if err != nil {
+				t.Fatalf("error opening store: %v", err)
+			}
+
+			defer store.Close()
+
+			if err := store.Write(context.Background(), create(rel("doc-1", "viewer", "user-alice"))); err != nil {
+				t.Fatalf("error writing: %v", err)
+			}
+
+			if _, err := os.Stat(filepath.Join(dir, tt.file)); err != nil {
+				t.Errorf("database not created at %s: %v", tt.file, err)
+			}
+		})
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "relationships.db")
+
+	store, err := Open(context.Background(), path)
+	if err != nil {
+		t.Fatalf("error opening store: %v", err)
+	}
+
+	if got := schemaVersion(t, store.db); got != len(migrations) {
+		t.Errorf("got schema version %d, want %d", got, len(migrations))
+	}
+
+	if err := store.Write(context.Background(), create(rel("doc-1", "viewer", "user-alice"))); err != nil {
+		t.Fatalf("error writing: %v", err)
+	}
+
+	store.Close()
+
+	// Opening a migrated database again keeps its contents.
+	store, err = Open(context.Background(), path)
+	if err != nil {
+		t.Fatalf("error reopening store: %v", err)
+	}
+
+	rels, err := store.ListByResource(context.Background(), "doc-1")
+	if err != nil {
+		t.Fatalf("error listing relationships: %v", err)
+	}
+
+	assertRelationships(t, rels, rel("doc-1", "viewer", "user-alice"))
+
+	if _, err := store.db.Exec("PRAGMA user_version = 1000"); err != nil {
+		t.Fatalf("error setting schema version: %v", err)
+	}
+
+	store.Close()
+
+	if _, err := Open(context.Background(), path); err == nil {
+		t.Error("got no error opening database with newer schema")
+	}
+}
+
+func TestMigrateRollsBack(t *testing.T) {
+	dsn, err := dataSourceName(filepath.Join(t.TempDir(), "relationships.db"))
+	if err != nil {
+		t.Fatalf("error building data source name: %v", err)
+	}
+
+	db, err := sql.Open(DriverName, dsn)
+	if err != nil {
+		t.Fatalf("error opening database: %v", err)
+	}
+
+	defer db.Close()
+
+	// The first migration fails part way through, as the table it creates second already exists.
+	if _, err := db.Exec("CREATE TABLE resources (id TEXT)"); err != nil {
+		t.Fatalf("error creating table: %v", err)
+	}
+
+	if _, err := New(context.Background(), db); err == nil {
+		t.Fatal("got no error migrating")
+	}
+
+	if got := schemaVersion(t, db); got != 0 {
+		t.Errorf("got schema version %d after failed migration, want 0", got)
+	}
+
+	var tables int
+
+	if err := db.QueryRow("SELECT count(*) FROM sqlite_schema WHERE name = 'relationships'").Scan(&tables); err != nil {
+		t.Fatalf("error reading schema: %v", err)
+	}
+
+	if tables != 0 {
+		t.Error("table created by failed migration not rolled back")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	store := newTestStore(t)
+
+	ctx := context.Background()
+
+	err := store.Write(ctx, []relationships.Update{
+		{Operation: relationships.OperationCreate, Relationship: rel("doc-1", "viewer", "user-alice")},
+		{Operation: relationships.OperationCreate, Relationship: rel("doc-1", "viewer", "user-alice")},
+		{Operation: relationships.OperationCreate, Relationship: rel("doc-1", "editor", "user-bob")},
+		{Operation: relationships.OperationDelete, Relationship: rel("doc-1", "editor", "user-bob")},
+		{Operation: relationships.OperationDelete, Relationship: rel("doc-1", "owner", "user-carol")},
+	})
+	if err != nil {
+		t.Fatalf("error writing: %v", err)
+	}
+
+	rels, err := store.ListByResource(ctx, "doc-1")
+	if err != nil {
+		t.Fatalf("error listing relationships: %v", err)
+	}
+
+	assertRelationships(t, rels, rel("doc-1", "viewer", "user-alice"))
+
+	// Batches with an invalid update are rolled back entirely, including the updates before it.
+	deleteAlice := relationships.Update{
+		Operation:    relationships.OperationDelete,
+		Relationship: rel("doc-1", "viewer", "user-alice"),
+	}
+
+	tests := []struct {
+		name    string
+		invalid relationships.Update
+	}{
+		{"empty resource ID", relationships.Update{Relationship: rel("", "viewer", "user-bob")}},
+		{"unknown operation", relationships.Update{Operation: relationships.Operation(10), Relationship: rel("doc-1", "viewer", "user-bob")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updates := append([]relationships.Update{deleteAlice}, create(rel("doc-1", "viewer", "user-carol"))...)
+
+			if err := store.Write(ctx, append(updates, tt.invalid)); err == nil {
+				t.Fatal("got no error")
+			}
+
+			rels, err := store.ListByResource(ctx, "doc-1")
+			if err != nil {
+				t.Fatalf("error listing relationships: %v", err)
+			}
+
+			assertRelationships(t, rels, rel("doc-1", "viewer", "user-alice"))
+		})
+	}
+}
+
+func TestListAllRelationships(t *testing.T) {
+	store := newTestStore(t)
+
+	ctx := context.Background()
+
+	err := store.Write(ctx, create(
+		rel("doc-2", "viewer", "group-eng#member"),
+		rel("doc-1", "viewer", "user-alice"),
+		rel("doc-1", "editor", "user-bob"),
+	))
+	if err != nil {
+		t.Fatalf("error writing: %v", err)
+	}
+
+	rels, err := store.ListAllRelationships(ctx)
+	if err != nil {
+		t.Fatalf("error listing relationships: %v", err)
+	}
+
+	assertRelationships(t, rels,
+		rel("doc-1", "editor", "user-bob"),
+		rel("doc-1", "viewer", "user-alice"),
+		rel("doc-2", "viewer", "group-eng#member"),
+	)
+}
+
+func TestListBySubject(t *testing.T) {
+	store := newTestStore(t)
+
+	ctx := context.Background()
+
+	err := store.Write(ctx, create(
+		rel("doc-2", "viewer", "user-alice"),
+		rel("doc-1", "viewer", "user-alice"),
+		rel("doc-1", "editor", "user-alice"),
+		rel("doc-1", "viewer", "group-staff#member"),
+		rel("doc-3", "viewer", "group-staff"),
+		rel("doc-3", "viewer", "user-bob"),
+	))
+	if err != nil {
+		t.Fatalf("error writing: %v", err)
+	}
+
+	tests := []struct {
+		subjectID string
+		want      []relationships.Relationship
+	}{
+		{"user-alice", []relationships.Relationship{
+			rel("doc-1", "editor", "user-alice"),
+			rel("doc-1", "viewer", "user-alice"),
+			rel("doc-2", "viewer", "user-alice"),
+		}},
+		{"group-staff#member", []relationships.Relationship{rel("doc-1", "viewer", "group-staff#member")}},
+		{"group-staff", []relationships.Relationship{rel("doc-3", "viewer", "group-staff")}},
+		{"group-staff#admin", nil},
+		{"user-carol", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.subjectID, func(t *testing.T) {
+			got, err := store.ListBySubject(ctx, tt.subjectID)
+			if err != nil {
+				t.Fatalf("error listing relationships: %v", err)
+			}
+
+			assertRelationships(t, got, tt.want...)
+		})
+	}
+}
+
+func TestRegisterResource(t *testing.T) {
+	store := newTestStore(t)
+
+	ctx := context.Background()
+
+	tenant := relationships.Resource{ID: "tnnt-a", Type: "tenant"}
+	doc := relationships.Resource{ID: "doc-1", Type: "document", ParentID: "tnnt-a"}
+
+	for _, resource := range []relationships.Resource{tenant, doc, doc} {
+		if err := store.RegisterResource(ctx, resource); err != nil {
+			t.Fatalf("error registering %s: %v", resource.ID, err)
+		}
+	}
+
+	got, err := store.GetResource(ctx, "doc-1")
+	if err != nil {
+		t.Fatalf("error getting resource: %v", err)
+	}
+
+	if got != doc {
+		t.Errorf("got resource %v, want %v", got, doc)
+	}
+
+	rels, err := store.ListByResource(ctx, "doc-1")
+	if err != nil {
+		t.Fatalf("error listing relationships: %v", err)
+	}
+
+	assertRelationships(t, rels, rel("doc-1", relationships.ParentRelation, "tnnt-a"))
+
+	tests := []struct {
+		name     string
+		resource relationships.Resource
+		wantErr  error
+	}{
+		{"different type", relationships.Resource{ID: "doc-1", Type: "folder", ParentID: "tnnt-a"}, relationships.ErrAlreadyExists},
+		{"different parent", relationships.Resource{ID: "doc-1", Type: "document"}, relationships.ErrAlreadyExists},
+		{"parent not registered", relationships.Resource{ID: "doc-2", Type: "document", ParentID: "tnnt-b"}, relationships.ErrParentNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := store.RegisterResource(ctx, tt.resource); !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := store.GetResource(ctx, "doc-2"); !errors.Is(err, relationships.ErrNotFound) {
+		t.Errorf("got error %v getting resource whose parent is not registered, want %v", err, relationships.ErrNotFound)
+	}
+}
+
+func TestUnregisterResource(t *testing.T) {
+	store := newTestStore(t)
+
+	ctx := context.Background()
+
+	for _, resource := range []relationships.Resource{
+		{ID: "tnnt-a", Type: "tenant"},
+		{ID: "doc-1", Type: "document", ParentID: "tnnt-a"},
+	} {
+		if err := store.RegisterResource(ctx, resource); err != nil {
+			t.Fatalf("error registering %s: %v", resource.ID, err)
+		}
+	}
+
+	err := store.Write(ctx, create(
+		rel("doc-1", "viewer", "user-alice"),
+		rel("tnnt-a", "member", "user-alice"),
+		rel("doc-2", "viewer", "tnnt-a#member"),
+	))
+	if err != nil {
+		t.Fatalf("error writing: %v", err)
+	}
+
+	if err := store.UnregisterResource(ctx, "tnnt-a"); !errors.Is(err, relationships.ErrHasChildren) {
+		t.Errorf("got error %v unregistering parent, want %v", err, relationships.ErrHasChildren)
+	}
+
+	for _, id := range []string{"doc-1", "tnnt-a"} {
+		if err := store.UnregisterResource(ctx, id); err != nil {
+			t.Fatalf("error unregistering %s: %v", id, err)
+		}
+	}
+
+	if err := store.UnregisterResource(ctx, "tnnt-a"); !errors.Is(err, relationships.ErrNotFound) {
+		t.Errorf("got error %v unregistering twice, want %v", err, relationships.ErrNotFound)
+	}
+
+	// Relationships on the resources, and those naming them as subjects, are removed.
+	for _, id := range []string{"doc-1", "tnnt-a", "doc-2"} {
+		rels, err := store.ListByResource(ctx, id)
+		if err != nil {
+			t.Fatalf("error listing relationships: %v", err)
+		}
+
+		assertRelationships(t, rels)
+	}
+
+	rels, err := store.ListBySubject(ctx, "user-alice")
+	if err != nil {
+		t.Fatalf("error listing relationships: %v", err)
+	}
+
+	assertRelationships(t, rels)
+}
@@ -0,0 +1,240 @@
+// Package revocation provides a persistent store of credential revocations for runtime
+// implementations.
+package revocation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrEmptyRevocation is returned when a revocation does not specify any credentials to revoke.
+var ErrEmptyRevocation = errors.New("revocation must set at least one of token ID, subject ID, or issued before")
+
+// Revocation describes a set of revoked credentials. A credential is revoked if it matches every
+// non-empty field of the revocation.
+type Revocation struct {
+	TokenID      string    `json:"token_id,omitempty"`
+	SubjectID    string    `json:"subject_id,omitempty"`
+	IssuedBefore time.Time `json:"issued_before"`
+	Reason       string    `json:"reason,omitempty"`
+	RevokedAt    time.Time `json:"revoked_at"`
+}
+
+// Credential describes a credential to check against the set of revocations. Fields which are not
+// known for a credential should be left empty.
+type Credential struct {
+	TokenID   string
+	SubjectID string
+	IssuedAt  time.Time
+}
+
+// Matches reports whether the given credential is revoked by r. Credentials with no known issue
+// time are treated as having been issued before any IssuedBefore time.
+func (r Revocation) Matches(cred Credential) bool {
+	if r.TokenID != "" && r.TokenID != cred.TokenID {
+		return false
+	}
+
+	if r.SubjectID != "" && r.SubjectID != cred.SubjectID {
+		return false
+	}
+
+	if !r.IssuedBefore.IsZero() && !cred.IssuedAt.IsZero() && !cred.IssuedAt.Before(r.IssuedBefore) {
+		return false
+	}
+
+	return true
+}
+
+func (r Revocation) empty() bool {
+	return r.TokenID == "" && r.SubjectID == "" && r.IssuedBefore.IsZero()
+}
+
+// expired reports whether every credential r matches has expired at now, given the maximum
+// lifetime of any credential. Credentials revoked by token ID are assumed to have been issued
+// before they were revoked. Revocations of every credential of a subject, regardless of when they
+// were issued, never expire.
+func (r Revocation) expired(now time.Time, maxLifetime time.Duration) bool {
+	issuedBefore := r.IssuedBefore
+
+	if r.TokenID != "" && (issuedBefore.IsZero() || r.RevokedAt.Before(issuedBefore)) {
+		issuedBefore = r.RevokedAt
+	}
+
+	if issuedBefore.IsZero() {
+		return false
+	}
+
+	return !now.Before(issuedBefore.Add(maxLifetime))
+}
+
+// Store is a set of revocations persisted to a JSON file on disk.
+type Store struct {
+	mu          sync.RWMutex
+	path        string
+	maxLifetime time.Duration
+	revocations []Revocation
+}
+
+// NewFileStore creates a new Store backed by the file at the given path, loading any revocations
+// already stored there. The file is created on the first call to Revoke if it does not exist.
+//
+// If maxLifetime is set, it is the longest any credential remains valid for, and revocations are
+// discarded once every credential they match has expired. Otherwise, revocations are kept
+// indefinitely.
+func NewFileStore(path string, maxLifetime time.Duration) (*Store, error) {
+	s := &Store{
+		path:        path,
+		maxLifetime: maxLifetime,
+	}
+
+	contents, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading revocations: %w", err)
+	}
+
+	if err := json.Unmarshal(contents, &s.revocations); err != nil {
+		return nil, fmt.Errorf("error parsing revocations in %s: %w", path, err)
+	}
+
+	// Expired revocations are removed from the file by the next call to Revoke.
+	s.revocations = s.prune(s.revocations, time.Now())
+
+	return s, nil
+}
+
+// Revoke adds the given revocation to the store and persists it, discarding any expired
+// revocations. If r.RevokedAt is not set, it is set to the current time.
+func (s *Store) Revoke(r Revocation) error {
+	if r.empty() {
+		return ErrEmptyRevocation
+	}
+
+	now := time.Now().UTC()
+
+	if r.RevokedAt.IsZero() {
+		r.RevokedAt = now
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revocations := append(s.prune(s.revocations, now), r)
+
+	if err := s.write(revocations); err != nil {
+		return err
+	}
+
+	s.revocations = revocations
+
+	return nil
+}
+
+// IsRevoked reports whether the given credential is revoked, returning the first matching
+// revocation if so.
+func (s *Store) IsRevoked(cred Credential) (Revocation, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, r := range s.revocations {
+		if r.Matches(cred) {
+			return r, true
+		}
+	}
+
+	return Revocation{}, false
+}
+
+// RevokeCredential serves the Authentication service's RevokeCredential RPC, storing a
+// revocation for the credentials described by the request.
+func (s *Store) RevokeCredential(ctx context.Context, req *authentication.RevokeCredentialRequest) (*authentication.RevokeCredentialResponse, error) {
+	r := Revocation{
+		TokenID:   req.GetTokenId(),
+		SubjectID: req.GetSubjectId(),
+		Reason:    req.GetReason(),
+	}
+
+	if req.IssuedBefore != nil {
+		r.IssuedBefore = req.IssuedBefore.AsTime()
+	}
+
+	err := s.Revoke(r)
+	if errors.Is(err, ErrEmptyRevocation) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err != nil {
+		log.Printf("error revoking credential: %v", err)
+
+		return nil, status.Error(codes.Internal, "error revoking credential")
+	}
+
+	return &authentication.RevokeCredentialResponse{}, nil
+}
+
+// prune returns a copy of revocations without those which have expired at now.
+func (s *Store) prune(revocations []Revocation, now time.Time) []Revocation {
+	out := make([]Revocation, 0, len(revocations)+1)
+
+	for _, r := range revocations {
+		if s.maxLifetime > 0 && r.expired(now, s.maxLifetime) {
+			continue
+		}
+
+		out = append(out, r)
+	}
+
+	return out
+}
+
+// write atomically replaces the store's file with the given revocations.
+func (s *Store) write(revocations []Revocation) error {
+	contents, err := json.MarshalIndent(revocations, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding revocations: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("error writing revocations: %w", err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(contents); err != nil {
+		tmp.Close()
+
+		return fmt.Errorf("error writing revocations: %w", err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+
+		return fmt.Errorf("error writing revocations: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing revocations: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("error writing revocations: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,207 @@
+package revocation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authentication"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func newTestStore(t *testing.T, path string, maxLifetime time.Duration) *Store {
+	t.Helper()
+
+	store, err := NewFileStore(path, maxLifetime)
+	if err != nil {
+		t.Fatalf("error creating store: %v", err)
+	}
+
+	return store
+}
+
+func revoke(t *testing.T, store *Store, r Revocation) {
+	t.Helper()
+
+	if err := store.Revoke(r); err != nil {
+		t.Fatalf("error revoking: %v", err)
+	}
+}
+
+func TestIsRevoked(t *testing.T) {
+	store := newTestStore(t, filepath.Join(t.TempDir(), "revocations.json"), 0)
+
+	cutoff := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	revoke(t, store, Revocation{TokenID: "token-leaked", Reason: "leaked"})
+	revoke(t, store, Revocation{SubjectID: "alice", IssuedBefore: cutoff, Reason: "password reset"})
+	revoke(t, store, Revocation{SubjectID: "bob", TokenID: "token-bob"})
+
+	tests := []struct {
+		name       string
+		cred       Credential
+		wantReason string
+		want       bool
+	}{
+		{"token ID", Credential{TokenID: "token-leaked", SubjectID: "carol"}, "leaked", true},
+		{"other token ID", Credential{TokenID: "token-other", SubjectID: "carol"}, "", false},
+		{"subject issued before", Credential{SubjectID: "alice", IssuedAt: cutoff.Add(-time.Second)}, "password reset", true},
+		{"subject issued at", Credential{SubjectID: "alice", IssuedAt: cutoff}, "", false},
+		{"subject issued after", Credential{SubjectID: "alice", IssuedAt: cutoff.Add(time.Second)}, "", false},
+		{"subject with unknown issue time", Credential{SubjectID: "alice"}, "password reset", true},
+		{"other subject issued before", Credential{SubjectID: "dave", IssuedAt: cutoff.Add(-time.Second)}, "", false},
+		{"every field", Credential{SubjectID: "bob", TokenID: "token-bob"}, "", true},
+		{"some fields", Credential{SubjectID: "carol", TokenID: "token-bob"}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, got := store.IsRevoked(tt.cred)
+			if got != tt.want {
+				t.Fatalf("got revoked %t, want %t", got, tt.want)
+			}
+
+			if r.Reason != tt.wantReason {
+				t.Errorf("got reason %q, want %q", r.Reason, tt.wantReason)
+			}
+		})
+	}
+
+	if err := store.Revoke(Revocation{Reason: "everything"}); !errors.Is(err, ErrEmptyRevocation) {
+		t.Errorf("got error %v revoking nothing, want %v", err, ErrEmptyRevocation)
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "revocations.json")
+
+	store := newTestStore(t, path, 0)
+
+	revoke(t, store, Revocation{TokenID: "token-leaked", Reason: "leaked"})
+	revoke(t, store, Revocation{SubjectID: "alice", IssuedBefore: time.Now()})
+
+	reopened := newTestStore(t, path, 0)
+
+	for _, cred := range []Credential{{TokenID: "token-leaked"}, {SubjectID: "alice"}} {
+		if _, revoked := reopened.IsRevoked(cred); !revoked {
+			t.Errorf("credential %v not revoked after reopening store", cred)
+		}
+	}
+
+	if r, _ := reopened.IsRevoked(Credential{TokenID: "token-leaked"}); r.RevokedAt.IsZero() {
+		t.Error("revocation time not set")
+	}
+
+	if err := os.WriteFile(path, []byte("["), 0o600); err != nil {
+		t.Fatalf("error writing revocations: %v", err)
+	}
+
+	if _, err := NewFileStore(path, 0); err == nil {
+		t.Error("got no error loading invalid revocations")
+	}
+
+	// Revocations which cannot be persisted are not applied.
+	readOnly := newTestStore(t, filepath.Join(t.TempDir(), "missing", "revocations.json"), 0)
+
+	if err := readOnly.Revoke(Revocation{TokenID: "token-leaked"}); err == nil {
+		t.Error("got no error revoking without a writable directory")
+	}
+
+	if _, revoked := readOnly.IsRevoked(Credential{TokenID: "token-leaked"}); revoked {
+		t.Error("credential revoked after failing to persist revocation")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "revocations.json")
+
+	const maxLifetime = 24 * time.Hour
+
+	now := time.Now()
+	expired := now.Add(-maxLifetime - time.Minute)
+	recent := now.Add(-maxLifetime + time.Minute)
+
+	revocations := []Revocation{
+		{TokenID: "token-expired", RevokedAt: expired},
+		{TokenID: "token-recent", RevokedAt: recent},
+		{TokenID: "token-issued-before", IssuedBefore: expired, RevokedAt: recent},
+		{SubjectID: "alice", IssuedBefore: expired, RevokedAt: recent},
+		{SubjectID: "bob", IssuedBefore: recent, RevokedAt: recent},
+		{SubjectID: "carol", RevokedAt: expired},
+	}
+
+	// Revocations are written directly, so that they may have been revoked in the past.
+	contents, err := json.Marshal(revocations)
+	if err != nil {
+		t.Fatalf("error encoding revocations: %v", err)
+	}
+
+	if err := os.WriteFile(path, contents, 0o600); err != nil {
+		t.Fatalf("error writing revocations: %v", err)
+	}
+
+	kept := newTestStore(t, path, 0)
+
+	if _, revoked := kept.IsRevoked(Credential{TokenID: "token-expired"}); !revoked {
+		t.Error("revocation discarded without a maximum credential lifetime")
+	}
+
+	store := newTestStore(t, path, maxLifetime)
+
+	revoke(t, store, Revocation{TokenID: "token-new"})
+
+	tests := []struct {
+		cred Credential
+		want bool
+	}{
+		{Credential{TokenID: "token-expired"}, false},
+		{Credential{TokenID: "token-recent"}, true},
+		{Credential{TokenID: "token-issued-before"}, false},
+		{Credential{SubjectID: "alice"}, false},
+		{Credential{SubjectID: "bob"}, true},
+		{Credential{SubjectID: "carol"}, true},
+		{Credential{TokenID: "token-new"}, true},
+	}
+
+	reopened := newTestStore(t, path, 0)
+
+	for _, tt := range tests {
+		for _, s := range []*Store{store, reopened} {
+			if _, got := s.IsRevoked(tt.cred); got != tt.want {
+				t.Errorf("credential %v: got revoked %t, want %t", tt.cred, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestRevokeCredential(t *testing.T) {
+	store := newTestStore(t, filepath.Join(t.TempDir(), "revocations.json"), 0)
+
+	issuedBefore := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	req := &authentication.RevokeCredentialRequest{
+		SubjectId:    "alice",
+		IssuedBefore: timestamppb.New(issuedBefore),
+		Reason:       "password reset",
+	}
+
+	if _, err := store.RevokeCredential(context.Background(), req); err != nil {
+		t.Fatalf("error revoking credential: %v", err)
+	}
+
+	r, revoked := store.IsRevoked(Credential{SubjectID: "alice", IssuedAt: issuedBefore.Add(-time.Second)})
+	if !revoked || r.Reason != "password reset" || !r.IssuedBefore.Equal(issuedBefore) {
+		t.Errorf("got revocation %v, revoked %t, want revocation from request", r, revoked)
+	}
+
+	_, err := store.RevokeCredential(context.Background(), &authentication.RevokeCredentialRequest{Reason: "everything"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("got error %v revoking nothing, want %s", err, codes.InvalidArgument)
+	}
+}
@@ -0,0 +1,268 @@
+// Package roles implements the role operations of the Authorization service on top of
+// relationships, for use by runtime implementations.
+package roles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+
+	authorization "github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RelationshipStore is the storage role assignments are read from and written to. Resources are
+// identified by both their type and ID, such that resources of different types with the same ID
+// have separate relationships.
+type RelationshipStore interface {
+	// CreateRelationships creates the given relationships on the given resource. Creating a
+	// relationship which already exists must succeed.
+	CreateRelationships(ctx context.Context, resource *authorization.ResourceReference, relationships []*authorization.Relationship) error
+
+	// DeleteRelationships deletes the given relationships from the given resource. Deleting a
+	// relationship which does not exist must succeed.
+	DeleteRelationships(ctx context.Context, resource *authorization.ResourceReference, relationships []*authorization.Relationship) error
+
+	// ListRelationships returns every relationship on the given resource.
+	ListRelationships(ctx context.Context, resource *authorization.ResourceReference) ([]*authorization.Relationship, error)
+}
+
+// Definition describes a role which may be assigned on resources of a given type.
+type Definition struct {
+	// ResourceType is the type of resource the role may be assigned on.
+	ResourceType string `yaml:"resource_type"`
+	// Name is the name of the role.
+	Name string `yaml:"name"`
+	// Description is a human-readable description of the role.
+	Description string `yaml:"description"`
+	// Relation is the relation used to represent assignments of the role. If empty, Name is used.
+	Relation string `yaml:"relation"`
+	// Actions is the set of actions the role allows.
+	Actions []string `yaml:"actions"`
+}
+
+func (d Definition) relation() string {
+	if d.Relation != "" {
+		return d.Relation
+	}
+
+	return d.Name
+}
+
+// Roles implements the ListRoles, AssignRole, UnassignRole, and ListRoleBindings operations by
+// writing and reading relationships in a RelationshipStore.
+type Roles struct {
+	store       RelationshipStore
+	definitions map[string][]Definition
+}
+
+// New creates a new Roles for the given role definitions, storing assignments in store. Each role
+// must have a name and relation unique among the roles for its resource type, since assignments of
+// roles sharing a relation could not be told apart.
+func New(store RelationshipStore, definitions ...Definition) (*Roles, error) {
+	r := &Roles{
+		store:       store,
+		definitions: make(map[string][]Definition),
+	}
+
+	for _, def := range definitions {
+		if def.ResourceType == "" || def.Name == "" {
+			return nil, errors.New("resource type and name are required")
+		}
+
+		for _, other := range r.definitions[def.ResourceType] {
+			if other.Name == def.Name {
+				return nil, fmt.Errorf("role %s on %s: duplicate role", def.Name, def.ResourceType)
+			}
+
+			if other.relation() == def.relation() {
+				return nil, fmt.Errorf("role %s on %s: relation %q is already used by role %s", def.Name, def.ResourceType, def.relation(), other.Name)
+			}
+		}
+
+		r.definitions[def.ResourceType] = append(r.definitions[def.ResourceType], def)
+	}
+
+	return r, nil
+}
+
+// ListRoles lists the roles defined for the requested resource type.
+func (r *Roles) ListRoles(ctx context.Context, req *authorization.ListRolesRequest) (*authorization.ListRolesResponse, error) {
+	defs, ok := r.definitions[req.GetResourceType()]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown resource type %q", req.GetResourceType())
+	}
+
+	out := &authorization.ListRolesResponse{}
+
+	for _, def := range defs {
+		role := &authorization.Role{
+			Name:        def.Name,
+			Description: def.Description,
+			Actions:     def.Actions,
+		}
+
+		out.Roles = append(out.Roles, role)
+	}
+
+	return out, nil
+}
+
+// AssignRole creates the relationship representing the requested role assignment.
+func (r *Roles) AssignRole(ctx context.Context, req *authorization.AssignRoleRequest) (*authorization.AssignRoleResponse, error) {
+	rel, err := r.relationship(req.GetResource(), req.GetRole(), req.GetSubjectId())
+	if err != nil {
+		return nil, err
+	}
+
+	rels := []*authorization.Relationship{rel}
+
+	if err := r.store.CreateRelationships(ctx, req.GetResource(), rels); err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &authorization.AssignRoleResponse{}, nil
+}
+
+// UnassignRole deletes the relationship representing the requested role assignment.
+func (r *Roles) UnassignRole(ctx context.Context, req *authorization.UnassignRoleRequest) (*authorization.UnassignRoleResponse, error) {
+	rel, err := r.relationship(req.GetResource(), req.GetRole(), req.GetSubjectId())
+	if err != nil {
+		return nil, err
+	}
+
+	rels := []*authorization.Relationship{rel}
+
+	if err := r.store.DeleteRelationships(ctx, req.GetResource(), rels); err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &authorization.UnassignRoleResponse{}, nil
+}
+
+// ListRoleBindings lists the roles assigned on the requested resource, ignoring any relationships
+// on the resource which do not represent a role.
+func (r *Roles) ListRoleBindings(ctx context.Context, req *authorization.ListRoleBindingsRequest) (*authorization.ListRoleBindingsResponse, error) {
+	resource := req.GetResource()
+
+	defs, ok := r.definitions[resource.GetResourceType()]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown resource type %q", resource.GetResourceType())
+	}
+
+	if resource.GetResourceId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "resource_id is required")
+	}
+
+	roleNames := make(map[string]string, len(defs))
+	for _, def := range defs {
+		roleNames[def.relation()] = def.Name
+	}
+
+	rels, err := r.store.ListRelationships(ctx, resource)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	out := &authorization.ListRoleBindingsResponse{}
+
+	for _, rel := range rels {
+		role, ok := roleNames[rel.GetRelation()]
+		if !ok {
+			continue
+		}
+
+		if req.GetSubjectId() != "" && rel.GetSubjectId() != req.GetSubjectId() {
+			continue
+		}
+
+		binding := &authorization.RoleBinding{
+			Role:      role,
+			SubjectId: rel.GetSubjectId(),
+			Resource:  resource,
+		}
+
+		out.RoleBindings = append(out.RoleBindings, binding)
+	}
+
+	sort.Slice(out.RoleBindings, func(i, j int) bool {
+		a, b := out.RoleBindings[i], out.RoleBindings[j]
+		if a.Role != b.Role {
+			return a.Role < b.Role
+		}
+
+		return a.SubjectId < b.SubjectId
+	})
+
+	return out, nil
+}
+
+// Allows reports whether any role assigned to the subject with the given ID on the given resource
+// allows the given action.
+func (r *Roles) Allows(ctx context.Context, resource *authorization.ResourceReference, subjectID, action string) (bool, error) {
+	defs, ok := r.definitions[resource.GetResourceType()]
+	if !ok {
+		return false, status.Errorf(codes.InvalidArgument, "unknown resource type %q", resource.GetResourceType())
+	}
+
+	rels, err := r.store.ListRelationships(ctx, resource)
+	if err != nil {
+		return false, toStatus(err)
+	}
+
+	for _, rel := range rels {
+		if rel.GetSubjectId() != subjectID {
+			continue
+		}
+
+		for _, def := range defs {
+			if def.relation() == rel.GetRelation() && slices.Contains(def.Actions, action) {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// relationship returns the relationship representing an assignment of the given role.
+func (r *Roles) relationship(resource *authorization.ResourceReference, role, subjectID string) (*authorization.Relationship, error) {
+	defs, ok := r.definitions[resource.GetResourceType()]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown resource type %q", resource.GetResourceType())
+	}
+
+	if resource.GetResourceId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "resource_id is required")
+	}
+
+	if subjectID == "" {
+		return nil, status.Error(codes.InvalidArgument, "subject_id is required")
+	}
+
+	for _, def := range defs {
+		if def.Name == role {
+			rel := &authorization.Relationship{
+				Relation:  def.relation(),
+				SubjectId: subjectID,
+			}
+
+			return rel, nil
+		}
+	}
+
+	return nil, status.Errorf(codes.InvalidArgument, "role %q is not defined for resource type %q", role, resource.GetResourceType())
+}
+
+// toStatus returns err unchanged if it is a gRPC status error, and an INTERNAL status error
+// otherwise.
+func toStatus(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	return status.Error(codes.Internal, err.Error())
+}
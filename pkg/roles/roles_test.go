@@ -0,0 +1,286 @@
+package roles
+
+import (
+	"context"
+	"testing"
+
+	authorization "github.com/metal-toolbox/iam-runtime/pkg/iam/runtime/authorization/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testStore is a RelationshipStore which keeps relationships in memory.
+type testStore struct {
+	relationships map[string][]*authorization.Relationship
+}
+
+func key(resource *authorization.ResourceReference) string {
+	return resource.GetResourceType() + "/" + resource.GetResourceId()
+}
+
+func (s *testStore) CreateRelationships(ctx context.Context, resource *authorization.ResourceReference, rels []*authorization.Relationship) error {
+	for _, rel := range rels {
+		if !contains(s.relationships[key(resource)], rel) {
+			s.relationships[key(resource)] = append(s.relationships[key(resource)], rel)
+		}
+	}
+
+	return nil
+}
+
+func (s *testStore) DeleteRelationships(ctx context.Context, resource *authorization.ResourceReference, rels []*authorization.Relationship) error {
+	var kept []*authorization.Relationship
+
+	for _, rel := range s.relationships[key(resource)] {
+		if !contains(rels, rel) {
+			kept = append(kept, rel)
+		}
+	}
+
+	s.relationships[key(resource)] = kept
+
+	return nil
+}
+
+func (s *testStore) ListRelationships(ctx context.Context, resource *authorization.ResourceReference) ([]*authorization.Relationship, error) {
+	return s.relationships[key(resource)], nil
+}
+
+func contains(rels []*authorization.Relationship, rel *authorization.Relationship) bool {
+	for _, r := range rels {
+		if r.GetRelation() == rel.GetRelation() && r.GetSubjectId() == rel.GetSubjectId() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func newTestRoles(t *testing.T) (*Roles, *testStore) {
+	t.Helper()
+
+	store := &testStore{
+		relationships: make(map[string][]*authorization.Relationship),
+	}
+
+	r, err := New(store,
+		Definition{ResourceType: "tenant", Name: "admin", Description: "Administers the tenant", Actions: []string{"read", "write"}},
+		Definition{ResourceType: "tenant", Name: "viewer", Relation: "view_role", Actions: []string{"read"}},
+		Definition{ResourceType: "project", Name: "admin", Actions: []string{"deploy"}},
+	)
+	if err != nil {
+		t.Fatalf("error creating roles: %v", err)
+	}
+
+	return r, store
+}
+
+func resource(typ, id string) *authorization.ResourceReference {
+	return &authorization.ResourceReference{
+		ResourceType: typ,
+		ResourceId:   id,
+	}
+}
+
+func assign(t *testing.T, r *Roles, res *authorization.ResourceReference, role, subjectID string) {
+	t.Helper()
+
+	req := &authorization.AssignRoleRequest{
+		Resource:  res,
+		Role:      role,
+		SubjectId: subjectID,
+	}
+
+	if _, err := r.AssignRole(context.Background(), req); err != nil {
+		t.Fatalf("error assigning %s to %s: %v", role, subjectID, err)
+	}
+}
+
+func bindings(t *testing.T, r *Roles, res *authorization.ResourceReference, subjectID string) []string {
+	t.Helper()
+
+	req := &authorization.ListRoleBindingsRequest{
+		Resource:  res,
+		SubjectId: subjectID,
+	}
+
+	resp, err := r.ListRoleBindings(context.Background(), req)
+	if err != nil {
+		t.Fatalf("error listing role bindings: %v", err)
+	}
+
+	var out []string
+
+	for _, binding := range resp.GetRoleBindings() {
+		if key(binding.GetResource()) != key(res) {
+			t.Errorf("got binding on %s, want %s", key(binding.GetResource()), key(res))
+		}
+
+		out = append(out, binding.GetRole()+"@"+binding.GetSubjectId())
+	}
+
+	return out
+}
+
+func assertStrings(t *testing.T, got []string, want ...string) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got %v, want %v", got, want)
+
+			return
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name        string
+		definitions []Definition
+	}{
+		{"missing type", []Definition{{Name: "admin"}}},
+		{"missing name", []Definition{{ResourceType: "tenant"}}},
+		{"duplicate name", []Definition{{ResourceType: "tenant", Name: "admin"}, {ResourceType: "tenant", Name: "admin", Relation: "other"}}},
+		{"duplicate relation", []Definition{{ResourceType: "tenant", Name: "admin"}, {ResourceType: "tenant", Name: "owner", Relation: "admin"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(&testStore{}, tt.definitions...); err == nil {
+				t.Error("got no error")
+			}
+		})
+	}
+
+	// Roles on different types may share names and relations.
+	if _, err := New(&testStore{}, Definition{ResourceType: "tenant", Name: "admin"}, Definition{ResourceType: "project", Name: "admin"}); err != nil {
+		t.Errorf("error creating roles: %v", err)
+	}
+}
+
+func TestListRoles(t *testing.T) {
+	r, _ := newTestRoles(t)
+
+	resp, err := r.ListRoles(context.Background(), &authorization.ListRolesRequest{ResourceType: "tenant"})
+	if err != nil {
+		t.Fatalf("error listing roles: %v", err)
+	}
+
+	var names []string
+	for _, role := range resp.GetRoles() {
+		names = append(names, role.GetName())
+	}
+
+	assertStrings(t, names, "admin", "viewer")
+	assertStrings(t, resp.GetRoles()[0].GetActions(), "read", "write")
+
+	if resp.GetRoles()[0].GetDescription() != "Administers the tenant" {
+		t.Errorf("got description %q", resp.GetRoles()[0].GetDescription())
+	}
+
+	if _, err := r.ListRoles(context.Background(), &authorization.ListRolesRequest{ResourceType: "cluster"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("got error %v for unknown type, want %s", err, codes.InvalidArgument)
+	}
+}
+
+func TestAssignRole(t *testing.T) {
+	r, store := newTestRoles(t)
+
+	tenant := resource("tenant", "shared-id")
+	project := resource("project", "shared-id")
+
+	assign(t, r, tenant, "admin", "user-alice")
+	assign(t, r, tenant, "admin", "user-alice")
+	assign(t, r, tenant, "viewer", "user-bob")
+	assign(t, r, project, "admin", "user-carol")
+
+	// Relationships which do not represent roles are not role bindings.
+	if err := store.CreateRelationships(context.Background(), tenant, []*authorization.Relationship{{Relation: "parent", SubjectId: "org-a"}}); err != nil {
+		t.Fatalf("error creating relationship: %v", err)
+	}
+
+	assertStrings(t, bindings(t, r, tenant, ""), "admin@user-alice", "viewer@user-bob")
+	assertStrings(t, bindings(t, r, tenant, "user-bob"), "viewer@user-bob")
+	assertStrings(t, bindings(t, r, project, ""), "admin@user-carol")
+
+	if got := store.relationships[key(tenant)][1]; got.GetRelation() != "view_role" {
+		t.Errorf("got relation %s for viewer role, want view_role", got.GetRelation())
+	}
+
+	req := &authorization.UnassignRoleRequest{
+		Resource:  tenant,
+		Role:      "admin",
+		SubjectId: "user-alice",
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := r.UnassignRole(context.Background(), req); err != nil {
+			t.Fatalf("error unassigning role: %v", err)
+		}
+	}
+
+	assertStrings(t, bindings(t, r, tenant, ""), "viewer@user-bob")
+	assertStrings(t, bindings(t, r, project, ""), "admin@user-carol")
+}
+
+func TestAssignRoleInvalid(t *testing.T) {
+	r, _ := newTestRoles(t)
+
+	tests := []struct {
+		name string
+		req  *authorization.AssignRoleRequest
+	}{
+		{"unknown type", &authorization.AssignRoleRequest{Resource: resource("cluster", "a"), Role: "admin", SubjectId: "user-alice"}},
+		{"unknown role", &authorization.AssignRoleRequest{Resource: resource("project", "a"), Role: "viewer", SubjectId: "user-alice"}},
+		{"missing resource ID", &authorization.AssignRoleRequest{Resource: resource("tenant", ""), Role: "admin", SubjectId: "user-alice"}},
+		{"missing subject", &authorization.AssignRoleRequest{Resource: resource("tenant", "a"), Role: "admin"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := r.AssignRole(context.Background(), tt.req); status.Code(err) != codes.InvalidArgument {
+				t.Errorf("got error %v, want %s", err, codes.InvalidArgument)
+			}
+		})
+	}
+}
+
+func TestAllows(t *testing.T) {
+	r, _ := newTestRoles(t)
+
+	tenant := resource("tenant", "shared-id")
+
+	assign(t, r, tenant, "viewer", "user-bob")
+	assign(t, r, resource("project", "shared-id"), "admin", "user-carol")
+
+	tests := []struct {
+		name      string
+		resource  *authorization.ResourceReference
+		subjectID string
+		action    string
+		want      bool
+	}{
+		{"role allows action", tenant, "user-bob", "read", true},
+		{"role does not allow action", tenant, "user-bob", "write", false},
+		{"no role", tenant, "user-alice", "read", false},
+		{"role on resource of another type with the same ID", tenant, "user-carol", "read", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.Allows(context.Background(), tt.resource, tt.subjectID, tt.action)
+			if err != nil {
+				t.Fatalf("error checking access: %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+}